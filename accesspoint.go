@@ -0,0 +1,174 @@
+package wifi
+
+import (
+	"strings"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+)
+
+// AccessPoint provides the properties of a wifi access point as it was
+// seen by a scan.
+type AccessPoint struct {
+	SSID     string `json:"ssid"`
+	BSSID    string `json:"bssid"`
+	Strength uint8  `json:"strength"`
+
+	// Frequency of the access point's radio channel in MHz.
+	Frequency uint32 `json:"frequency"`
+
+	// Channel derived from Frequency; zero if unknown.
+	Channel uint32 `json:"channel"`
+
+	// Band derived from Frequency, i.e. "2.4 GHz", "5 GHz" or "6 GHz";
+	// zero if unknown.
+	Band string `json:"band"`
+
+	// Security summarizes the access point's WPA and RSN flags, e.g.
+	// "WPA2 WPA3", or is "open" for unsecured access points.
+	Security string `json:"security"`
+
+	// Mode is either "adhoc", "infrastructure", "ap" or "unknown".
+	Mode string `json:"mode"`
+
+	// MaxBitrate the access point is capable of in Kb/s.
+	MaxBitrate uint32 `json:"max_bitrate"`
+
+	// LastSeen is the CLOCK_BOOTTIME timestamp in seconds of the scan
+	// which found the access point last; -1 if it was never found.
+	LastSeen int32 `json:"last_seen"`
+}
+
+// accessPointOf reads the properties of given NetworkManager access
+// point ap.
+func accessPointOf(ap nm.AccessPoint) (AccessPoint, error) {
+	ssid, err := ap.GetPropertySSID()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	strength, err := ap.GetPropertyStrength()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	bssid, err := ap.GetPropertyHWAddress()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	frequency, err := ap.GetPropertyFrequency()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	flags, err := ap.GetPropertyFlags()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	wpa, err := ap.GetPropertyWPAFlags()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	rsn, err := ap.GetPropertyRSNFlags()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	mode, err := ap.GetPropertyMode()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	bitrate, err := ap.GetPropertyMaxBitrate()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	lastSeen, err := ap.GetPropertyLastSeen()
+	if err != nil {
+		return AccessPoint{}, err
+	}
+	return AccessPoint{
+		SSID:      ssid,
+		BSSID:     bssid,
+		Strength:  strength,
+		Frequency: frequency,
+		Channel:   channel(frequency),
+		Band:      band(frequency),
+		Security: securitySummary(nm.Nm80211APFlags(flags),
+			nm.Nm80211APSec(wpa), nm.Nm80211APSec(rsn)),
+		Mode:       modeName(mode),
+		MaxBitrate: bitrate,
+		LastSeen:   lastSeen,
+	}, nil
+}
+
+// channel returns the IEEE 802.11 channel number of given frequency in
+// MHz or zero if frequency is not in the 2.4, 5 or 6 GHz band.
+func channel(frequency uint32) uint32 {
+	switch {
+	case frequency == 2484:
+		return 14
+	case 2412 <= frequency && frequency < 2484:
+		return (frequency - 2407) / 5
+	case frequency == 5935:
+		return 2
+	case 5000 <= frequency && frequency < 5925:
+		return (frequency - 5000) / 5
+	case 5950 < frequency && frequency <= 7115:
+		return (frequency - 5950) / 5
+	}
+	return 0
+}
+
+// band returns the band of given frequency in MHz or the zero string if
+// frequency is not in the 2.4, 5 or 6 GHz band.
+func band(frequency uint32) string {
+	switch {
+	case 2400 <= frequency && frequency < 2500:
+		return "2.4 GHz"
+	case 5000 <= frequency && frequency < 5925:
+		return "5 GHz"
+	case 5925 <= frequency && frequency <= 7125:
+		return "6 GHz"
+	}
+	return ""
+}
+
+func modeName(mode nm.Nm80211Mode) string {
+	switch mode {
+	case nm.Nm80211ModeAdhoc:
+		return "adhoc"
+	case nm.Nm80211ModeInfra:
+		return "infrastructure"
+	case nm.Nm80211ModeAp:
+		return "ap"
+	}
+	return "unknown"
+}
+
+// securitySummary decodes given access point flags into a space
+// separated list of the supported security protocols, e.g. "WPA1 WPA2",
+// "WPA3 802.1X", "OWE" or "WEP".  It is "open" for access points without
+// any security.
+func securitySummary(
+	flags nm.Nm80211APFlags, wpa, rsn nm.Nm80211APSec,
+) string {
+	ss := []string{}
+	if flags&nm.Nm80211APFlagsPrivacy != 0 &&
+		wpa == nm.Nm80211APSecNone && rsn == nm.Nm80211APSecNone {
+		ss = append(ss, "WEP")
+	}
+	if wpa != nm.Nm80211APSecNone {
+		ss = append(ss, "WPA1")
+	}
+	if rsn&(nm.Nm80211APSecKeyMgmtPSK|nm.Nm80211APSecKeyMgmt8021X) != 0 {
+		ss = append(ss, "WPA2")
+	}
+	if rsn&nm.Nm80211APSecKeyMgmtSAE != 0 {
+		ss = append(ss, "WPA3")
+	}
+	if rsn&(nm.Nm80211APSecKeyMgmtOWE|nm.Nm80211APSecKeyMgmtOWETM) != 0 {
+		ss = append(ss, "OWE")
+	}
+	if (wpa|rsn)&nm.Nm80211APSecKeyMgmt8021X != 0 {
+		ss = append(ss, "802.1X")
+	}
+	if len(ss) == 0 {
+		return string(SecurityOpen)
+	}
+	return strings.Join(ss, " ")
+}
@@ -0,0 +1,104 @@
+package wifi
+
+import (
+	"testing"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	. "github.com/slukits/gounit"
+)
+
+type AnAccessPoint struct{ Suite }
+
+func (s *AnAccessPoint) SetUp(t *T) { t.Parallel() }
+
+func (s *AnAccessPoint) Has_its_channel_derived_from_its_frequency(t *T) {
+	for frequency, channel_ := range map[uint32]uint32{
+		2412: 1, 2472: 13, 2484: 14, 5180: 36, 5825: 165,
+		5935: 2, 5955: 1, 7115: 233, 900: 0,
+	} {
+		t.Eq(channel_, channel(frequency))
+	}
+}
+
+func (s *AnAccessPoint) Has_its_band_derived_from_its_frequency(t *T) {
+	for frequency, band_ := range map[uint32]string{
+		2412: "2.4 GHz", 2484: "2.4 GHz", 5180: "5 GHz", 5825: "5 GHz",
+		5935: "6 GHz", 7115: "6 GHz", 900: "",
+	} {
+		t.Eq(band_, band(frequency))
+	}
+}
+
+func (s *AnAccessPoint) Summarizes_its_security_flags(t *T) {
+	t.Eq("open", securitySummary(nm.Nm80211APFlagsNone,
+		nm.Nm80211APSecNone, nm.Nm80211APSecNone))
+	t.Eq("WEP", securitySummary(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone, nm.Nm80211APSecNone))
+	t.Eq("WPA1 WPA2", securitySummary(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecKeyMgmtPSK, nm.Nm80211APSecKeyMgmtPSK))
+	t.Eq("WPA2 WPA3", securitySummary(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone,
+		nm.Nm80211APSecKeyMgmtPSK|nm.Nm80211APSecKeyMgmtSAE))
+	t.Eq("OWE", securitySummary(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone, nm.Nm80211APSecKeyMgmtOWE))
+	t.Eq("WPA2 802.1X", securitySummary(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone, nm.Nm80211APSecKeyMgmt8021X))
+}
+
+func (s *AnAccessPoint) Is_read_from_a_network_manager_access_point(
+	t *T,
+) {
+	ap, err := accessPointOf(&MckRichAP{})
+	t.FatalOn(err)
+	t.Eq(AccessPoint{
+		SSID:       "rich",
+		BSSID:      "00:11:22:33:44:55",
+		Strength:   70,
+		Frequency:  5180,
+		Channel:    36,
+		Band:       "5 GHz",
+		Security:   "WPA3",
+		Mode:       "infrastructure",
+		MaxBitrate: 540000,
+		LastSeen:   42,
+	}, ap)
+}
+
+func TestAnAccessPoint(t *testing.T) {
+	t.Parallel()
+	Run(&AnAccessPoint{}, t)
+}
+
+type MckRichAP struct{ nm.AccessPoint }
+
+func (m *MckRichAP) GetPropertySSID() (string, error) { return "rich", nil }
+
+func (m *MckRichAP) GetPropertyStrength() (uint8, error) { return 70, nil }
+
+func (m *MckRichAP) GetPropertyHWAddress() (string, error) {
+	return "00:11:22:33:44:55", nil
+}
+
+func (m *MckRichAP) GetPropertyFrequency() (uint32, error) {
+	return 5180, nil
+}
+
+func (m *MckRichAP) GetPropertyFlags() (uint32, error) {
+	return uint32(nm.Nm80211APFlagsPrivacy), nil
+}
+
+func (m *MckRichAP) GetPropertyWPAFlags() (uint32, error) { return 0, nil }
+
+func (m *MckRichAP) GetPropertyRSNFlags() (uint32, error) {
+	return uint32(nm.Nm80211APSecKeyMgmtSAE), nil
+}
+
+func (m *MckRichAP) GetPropertyMode() (nm.Nm80211Mode, error) {
+	return nm.Nm80211ModeInfra, nil
+}
+
+func (m *MckRichAP) GetPropertyMaxBitrate() (uint32, error) {
+	return 540000, nil
+}
+
+func (m *MckRichAP) GetPropertyLastSeen() (int32, error) { return 42, nil }
@@ -1,22 +1,23 @@
-package main
+package wifi
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	nm "github.com/Wifx/gonetworkmanager/v2"
 	"github.com/godbus/dbus/v5"
 	"github.com/google/uuid"
-	"golang.org/x/term"
 )
 
 // BusConnection reduces the dbus.Conn-API to the needs of WifiAdapter
 // and makes these features mockable
 type BusConnection interface {
 	AddMatchSignal(...dbus.MatchOption) error
+	RemoveMatchSignal(...dbus.MatchOption) error
 	Signal(chan<- *dbus.Signal)
 	RemoveSignal(chan<- *dbus.Signal)
 	Close() error
@@ -35,24 +36,30 @@ type WifiAdapter struct {
 
 	name    string
 	dev     nm.DeviceWireless
-	env     *Env
+	client  *Client
 	libInit bool
+
+	// secretRequests counts the secret agent's pending secret requests
+	// which suspend the timeout while waiting for a state change.
+	secretRequests int32
 }
 
 func (a *WifiAdapter) lib() AdapterLib {
 	if !a.libInit {
 		a.libInit = true
-		if a.Lib.SystemBus == nil {
-			a.Lib.SystemBus = func() (BusConnection, error) {
-				return dbus.ConnectSystemBus()
-			}
-		}
 		if a.Lib.WaitForPropertyChange == nil {
 			a.Lib.WaitForPropertyChange = a.waitForPropertyChange
 		}
 		if a.Lib.Disconnect == nil {
 			a.Lib.Disconnect = a.dev.Disconnect
 		}
+		if a.Lib.Password == nil {
+			a.Lib.Password = a.client.password
+		}
+		if a.Lib.RegisterSecretAgent == nil {
+			a.Lib.RegisterSecretAgent =
+				a.client.lib().RegisterSecretAgent
+		}
 	}
 	return a.Lib
 }
@@ -69,26 +76,28 @@ func (a *WifiAdapter) IsActivated() bool {
 
 var ErrAdapterScan = errors.New("adapter: scan")
 
-// AccessPoint provides the SSID and signal strength of an wifi access
-// point.
-type AccessPoint struct {
-	SSID     string
-	Strength uint8
-}
-
 // Scan for all available access points of given wifi-adapter a and
 // return found access points sorted descending by signal strength and
-// ascending by SSID.
-func (a *WifiAdapter) Scan() (_ []AccessPoint, err error) {
+// ascending by SSID and BSSID.  Given hidden SSIDs are probed by the
+// scan, i.e. hidden access points answering the probe are reported with
+// their SSID; other hidden access points have the zero SSID.
+func (a *WifiAdapter) Scan(ctx context.Context, hidden ...string) (
+	_ []AccessPoint, err error,
+) {
 	c, dfr, err := a.setupSignalMatcher()
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrAdapterScan, err)
 	}
 	defer func() { err = dfr(err, ErrAdapterScan) }()
-	if err := a.dev.RequestScan(); err != nil {
+	if len(hidden) == 0 {
+		err = a.dev.RequestScan()
+	} else {
+		err = a.client.lib().RequestScan(a.dev.GetPath(), hidden)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrAdapterScan, err)
 	}
-	if err := a.lib().WaitForPropertyChange(c, "LastScan"); err != nil {
+	if err := a.lib().WaitForPropertyChange(ctx, c, "LastScan"); err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrAdapterScan, err)
 	}
 	aa, err := a.dev.GetPropertyAccessPoints()
@@ -97,215 +106,529 @@ func (a *WifiAdapter) Scan() (_ []AccessPoint, err error) {
 	}
 	accessPoints := []AccessPoint{}
 	for _, ap := range aa {
-		ssid, err := ap.GetPropertySSID()
-		if err != nil {
-			return nil, fmt.Errorf("%w: %w", ErrAdapterScan, err)
-		}
-		strength, err := ap.GetPropertyStrength()
+		accessPoint, err := accessPointOf(ap)
 		if err != nil {
 			return nil, fmt.Errorf("%w: %w", ErrAdapterScan, err)
 		}
-		accessPoints = append(accessPoints, AccessPoint{
-			SSID: ssid, Strength: strength})
+		accessPoints = append(accessPoints, accessPoint)
 	}
-	sort.Slice(accessPoints, func(i, j int) bool {
-		return accessPoints[i].SSID < accessPoints[j].SSID
-	})
-	sort.SliceStable(accessPoints, func(i, j int) bool {
-		return accessPoints[i].Strength > accessPoints[j].Strength
+	sortAccessPoints(accessPoints, func(i int) *AccessPoint {
+		return &accessPoints[i]
 	})
 	return accessPoints, nil
 }
 
+// sortAccessPoints sorts given slice of access points descending by
+// signal strength and ascending by SSID and BSSID.  Given function ap
+// provides the access point at given index of the slice.
+func sortAccessPoints(slice interface{}, ap func(int) *AccessPoint) {
+	sort.Slice(slice, func(i, j int) bool {
+		if ap(i).SSID == ap(j).SSID {
+			return ap(i).BSSID < ap(j).BSSID
+		}
+		return ap(i).SSID < ap(j).SSID
+	})
+	sort.SliceStable(slice, func(i, j int) bool {
+		return ap(i).Strength > ap(j).Strength
+	})
+}
+
+var ErrAdapterDisconnect = errors.New("adapter: disconnect")
+var ErrNotConnected = errors.New("not connected")
+
 // Disconnect currently active access point of given wife-adapter a.
-func (a *WifiAdapter) Disconnect() (err error) {
+func (a *WifiAdapter) Disconnect(ctx context.Context) (err error) {
 	if !a.IsActivated() {
-		return errors.New("not connected")
+		return fmt.Errorf("%w: %w", ErrAdapterDisconnect, ErrNotConnected)
 	}
 	c, dfr, err := a.setupSignalMatcher()
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrAdapterScan, err)
+		return fmt.Errorf("%w: %w", ErrAdapterDisconnect, err)
 	}
-	defer func() { err = dfr(err, ErrAdapterScan) }()
+	defer func() { err = dfr(err, ErrAdapterDisconnect) }()
 	if err := a.lib().Disconnect(); err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrAdapterDisconnect, err)
+	}
+	err = a.waitForStateChange(ctx, c, nm.NmDeviceStateDisconnected)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrAdapterDisconnect, err)
 	}
-	return a.waitForStateChange(c, nm.NmDeviceStateDisconnected)
+	return nil
 }
 
 var ErrAdapterActive = errors.New("adapter: get active access point")
 
 // Active returns the SSID of given wifi adapter a's connected access
 // point.
-func (a *WifiAdapter) Active() (string, error) {
-	ap, err := a.dev.GetPropertyActiveAccessPoint()
-	if err != nil {
+func (a *WifiAdapter) Active(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
 		return "", fmt.Errorf("%w: %w", ErrAdapterActive, err)
 	}
-	ssid, err := ap.GetPropertySSID()
+	ssid, err := a.activeSSID()
 	if err != nil {
 		return "", fmt.Errorf("%w: %w", ErrAdapterActive, err)
 	}
+	if ssid == "" {
+		return "", fmt.Errorf("%w: %w", ErrAdapterActive, ErrNotConnected)
+	}
 	return ssid, nil
 }
 
+// activeSSID returns the SSID of given wifi adapter a's active access
+// point or the zero string if there is none.
+func (a *WifiAdapter) activeSSID() (string, error) {
+	ap, err := a.dev.GetPropertyActiveAccessPoint()
+	if err != nil {
+		return "", err
+	}
+	if ap == nil {
+		return "", nil
+	}
+	return ap.GetPropertySSID()
+}
+
+// AdapterInfo describes a wifi adapter and its current state.
+type AdapterInfo struct {
+	Name        string `json:"name"`
+	MAC         string `json:"mac"`
+	Driver      string `json:"driver"`
+	State       string `json:"state"`
+	Managed     bool   `json:"managed"`
+	Autoconnect bool   `json:"autoconnect"`
+
+	// SSID of the active access point; the zero string if there is
+	// none.
+	SSID string `json:"ssid"`
+}
+
+var ErrAdapterInfo = errors.New("adapter: info")
+
+// Info returns the description of given wifi adapter a.
+func (a *WifiAdapter) Info(ctx context.Context) (AdapterInfo, error) {
+	info := AdapterInfo{Name: a.name}
+	if err := ctx.Err(); err != nil {
+		return info, fmt.Errorf("%w: %w", ErrAdapterInfo, err)
+	}
+	var err error
+	if info.MAC, err = a.dev.GetPropertyHwAddress(); err != nil {
+		return info, fmt.Errorf("%w: mac: %w", ErrAdapterInfo, err)
+	}
+	if info.Driver, err = a.dev.GetPropertyDriver(); err != nil {
+		return info, fmt.Errorf("%w: driver: %w", ErrAdapterInfo, err)
+	}
+	state, err := a.dev.GetPropertyState()
+	if err != nil {
+		return info, fmt.Errorf("%w: state: %w", ErrAdapterInfo, err)
+	}
+	info.State = stateName(state)
+	if info.Managed, err = a.dev.GetPropertyManaged(); err != nil {
+		return info, fmt.Errorf("%w: managed: %w", ErrAdapterInfo, err)
+	}
+	if info.Autoconnect, err = a.dev.GetPropertyAutoConnect(); err != nil {
+		return info, fmt.Errorf("%w: autoconnect: %w",
+			ErrAdapterInfo, err)
+	}
+	if info.SSID, err = a.activeSSID(); err != nil {
+		return info, fmt.Errorf("%w: ssid: %w", ErrAdapterInfo, err)
+	}
+	return info, nil
+}
+
 var ErrAdapterConnect = errors.New("adapter: connecting")
 
 // Connect to the given wifi-adapter a to the access-point with given
 // SSID.  If no configuration settings for SSID found query a password,
-// create new configuration settings for SSID and connect.
-func (a *WifiAdapter) Connect(SSID string) error {
-	cnn, err := a.settingsConnectionOf(SSID)
+// create new configuration settings for SSID and connect.  New
+// configuration settings are added unsaved and only saved once the
+// device is activated; they are deleted if the connect fails.  During
+// the connect a secret agent answers NetworkManager's secret requests,
+// e.g. if the password of known configuration settings has changed.  If
+// given context ctx is canceled before the connect completes the
+// pending activation is deactivated as well.  Connect fails with
+// ErrAmbiguousProfile if several configurations for SSID exist, see
+// ConnectProfile.
+func (a *WifiAdapter) Connect(ctx context.Context, SSID string) error {
+	return a.ConnectProfile(ctx, ProfileSelector{SSID: SSID})
+}
+
+// ConnectProfile connects given wifi-adapter a like Connect using the
+// configuration settings selected by given selector sel.  Only if sel
+// selects nothing but an SSID new configuration settings may be
+// created; otherwise ConnectProfile fails with ErrNoProfile if sel
+// selects no configuration.
+func (a *WifiAdapter) ConnectProfile(
+	ctx context.Context, sel ProfileSelector,
+) error {
+	return a.connect(ctx, sel, ConnectOptions{})
+}
+
+// ConnectHidden connects given wifi-adapter a like ConnectProfile to an
+// access point which doesn't broadcast its SSID.  The SSID is probed by
+// a directed scan and NetworkManager chooses the access point to
+// activate.  New configuration settings are flagged hidden; their
+// security is taken from the access point if it answers the probe and
+// defaults to WPA-PSK otherwise.
+func (a *WifiAdapter) ConnectHidden(
+	ctx context.Context, sel ProfileSelector,
+) error {
+	return a.connect(ctx, sel, ConnectOptions{Hidden: true})
+}
+
+// ConnectOptions configure a connect, see WifiAdapter.ConnectWith.
+type ConnectOptions struct {
+
+	// Hidden lets a connect probe for an access point which doesn't
+	// broadcast its SSID, see WifiAdapter.ConnectHidden.
+	Hidden bool
+
+	// Enterprise provides the 802.1X authentication of new
+	// configuration settings for a WPA2/WPA3-Enterprise access point.
+	Enterprise *Enterprise
+
+	// IP provides the addressing, DNS and proxy settings of new
+	// configuration settings.
+	IP *IPConfig
+
+	// Credentials provide the password of new configuration settings
+	// which is queried otherwise, e.g. from a wifi QR code.  Their
+	// security applies if a hidden access point doesn't answer the
+	// probe.
+	Credentials *Credentials
+}
+
+// ConnectWith connects given wifi-adapter a like ConnectProfile
+// according to given options opts.  Options concerning new
+// configuration settings are ignored if sel selects existing settings;
+// they are validated before new settings are added.
+func (a *WifiAdapter) ConnectWith(
+	ctx context.Context, sel ProfileSelector, opts ConnectOptions,
+) error {
+	return a.connect(ctx, sel, opts)
+}
+
+// connect implements ConnectProfile, ConnectHidden and ConnectWith.
+func (a *WifiAdapter) connect(
+	ctx context.Context, sel ProfileSelector, opts ConnectOptions,
+) (err error) {
+	cnn, profile, err := a.client.profileConnection(sel)
 	if err != nil {
-		return fmt.Errorf("%w: '%s': %w", ErrAdapterConnect, SSID, err)
+		return fmt.Errorf("%w: %w", ErrAdapterConnect, err)
+	}
+	SSID := sel.SSID
+	if cnn != nil {
+		SSID = profile.SSID
+	}
+	if cnn == nil && (SSID == "" || sel.UUID != "" || sel.ID != "") {
+		return fmt.Errorf("%w: %w for %s",
+			ErrAdapterConnect, ErrNoProfile, sel)
+	}
+	if cnn == nil && opts.Enterprise != nil {
+		if err := opts.Enterprise.Validate(); err != nil {
+			return fmt.Errorf("%w: '%s': %w",
+				ErrAdapterConnect, SSID, err)
+		}
+	}
+	if cnn == nil && opts.IP != nil {
+		if err := opts.IP.Validate(); err != nil {
+			return fmt.Errorf("%w: '%s': %w",
+				ErrAdapterConnect, SSID, err)
+		}
 	}
 	c, dfr, err := a.setupSignalMatcher()
 	if err != nil {
 		return fmt.Errorf("%w: '%s': %w", ErrAdapterConnect, SSID, err)
 	}
 	defer func() { err = dfr(err, ErrAdapterConnect) }()
+	unregister, err := a.lib().RegisterSecretAgent(&SecretAgent{
+		password: a.lib().Password,
+		pending:  &a.secretRequests,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: '%s': %w", ErrAdapterConnect, SSID, err)
+	}
+	defer func() {
+		if e := unregister(); e != nil {
+			if err != nil {
+				err = fmt.Errorf("%w: %w", err, e)
+				return
+			}
+			err = fmt.Errorf("%w: '%s': %w", ErrAdapterConnect, SSID, e)
+		}
+	}()
+	pending := &activation{}
+	defer func() {
+		if err == nil || (ctx.Err() == nil && pending.created == nil) {
+			return
+		}
+		if errors.As(err, new(*StateError)) {
+			pending.active = nil // the activation has ended already
+		}
+		if e := a.rollback(pending); e != nil {
+			err = fmt.Errorf("%w: %w", err, e)
+		}
+	}()
 	if cnn != nil {
-		if err := a.activateKnownAccessPoint(cnn, SSID); err != nil {
+		err := a.activateKnownAccessPoint(
+			ctx, cnn, SSID, opts.Hidden, pending)
+		if err != nil {
 			return fmt.Errorf("%w: '%s': %w", ErrAdapterConnect, SSID, err)
 		}
-		err := a.waitForStateChange(c, nm.NmDeviceStateActivated)
+		err = a.waitForStateChange(ctx, c, nm.NmDeviceStateActivated)
 		if err != nil {
 			return fmt.Errorf("%w: '%s': %w", ErrAdapterConnect, SSID, err)
 		}
 		return nil
 	}
-	if err := a.configureNewConnection(SSID); err != nil {
+	err = a.configureNewConnection(ctx, SSID, opts, pending)
+	if err != nil {
 		return fmt.Errorf("%w: '%s': %w", ErrAdapterConnect, SSID, err)
 	}
-	err = a.waitForStateChange(c, nm.NmDeviceStateActivated)
+	err = a.waitForStateChange(ctx, c, nm.NmDeviceStateActivated)
 	if err != nil {
 		return fmt.Errorf("%w: '%s': %w", ErrAdapterConnect, SSID, err)
 	}
+	if err := pending.created.Save(); err != nil {
+		return fmt.Errorf("%w: '%s': save: %w",
+			ErrAdapterConnect, SSID, err)
+	}
 	return nil
 }
 
-func (a *WifiAdapter) configureNewConnection(SSID string) error {
-	fmt.Fprintf(os.Stdin, "password for '%s' (leave blank if open):", SSID)
-	pwd, err := term.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Fprintln(os.Stdin, "")
+var ErrUnsupportedSecurity = errors.New("unsupported security")
+
+func (a *WifiAdapter) configureNewConnection(
+	ctx context.Context, SSID string, opts ConnectOptions,
+	pending *activation,
+) (err error) {
+	var ap nm.AccessPoint
+	if opts.Hidden {
+		ap, err = a.probe(ctx, SSID)
+	} else {
+		ap, err = a.accessPoint(ctx, SSID)
+	}
 	if err != nil {
 		return err
 	}
-	ss, err := nm.NewSettings()
+	sec := SecurityWPAPSK
+	switch {
+	case opts.Enterprise != nil:
+		sec = SecurityEnterprise
+	case opts.Credentials != nil:
+		sec = opts.Credentials.Security
+	}
+	if ap != nil {
+		if sec, err = securityOf(ap); err != nil {
+			return err
+		}
+	}
+	switch {
+	case sec == SecurityEnterprise && opts.Enterprise == nil:
+		return fmt.Errorf("%w: %s: missing 802.1X settings",
+			ErrUnsupportedSecurity, sec)
+	case sec != SecurityEnterprise && opts.Enterprise != nil:
+		return fmt.Errorf("%w: access point is secured by %s",
+			ErrEnterprise, sec)
+	}
+	pwd := ""
+	if opts.Credentials != nil && sec.NeedsPassword() {
+		pwd = opts.Credentials.Password
+	}
+	if pwd == "" && (sec.NeedsPassword() ||
+		opts.Enterprise != nil && opts.Enterprise.needsPassword()) {
+		if pwd, err = a.lib().Password(SSID); err != nil {
+			return err
+		}
+	}
+	ss, err := a.client.lib().NewSettings()
 	if err != nil {
 		return err
 	}
-	cnn, err := ss.AddConnection(
-		newConnectionSettings(SSID, string(pwd)))
+	settings := newConnectionSettings(SSID, pwd, sec)
+	if opts.Enterprise != nil {
+		settings[enterpriseSettings] = opts.Enterprise.settings(pwd)
+	}
+	if opts.IP != nil {
+		opts.IP.apply(settings)
+	}
+	if opts.Hidden {
+		settings[wirelessSettings]["hidden"] = true
+		ap = nil
+	}
+	cnn, err := ss.AddConnectionUnsaved(settings)
 	if err != nil {
 		return err
 	}
-	return a.activateKnownAccessPoint(cnn, SSID)
+	pending.created = cnn
+	return a.activate(cnn, ap, pending)
 }
 
 func (a *WifiAdapter) activateKnownAccessPoint(
-	c nm.Connection, SSID string,
+	ctx context.Context, c nm.Connection, SSID string, hidden bool,
+	pending *activation,
 ) error {
-	ap, err := a.accessPoint(SSID)
+	if hidden {
+		if _, err := a.probe(ctx, SSID); err != nil {
+			return err
+		}
+		return a.activate(c, nil, pending)
+	}
+	ap, err := a.accessPoint(ctx, SSID)
 	if err != nil {
 		return err
 	}
-	m, err := a.env.nm()
+	return a.activate(c, ap, pending)
+}
+
+// activate requests the activation of given connection c at given
+// access point ap; NetworkManager chooses the access point if ap is
+// nil.
+func (a *WifiAdapter) activate(
+	c nm.Connection, ap nm.AccessPoint, pending *activation,
+) error {
+	m, err := a.client.nm()
 	if err != nil {
 		return err
 	}
-	_, err = m.ActivateWirelessConnection(c, a.dev, ap)
-	return err
+	var active nm.ActiveConnection
+	if ap == nil {
+		active, err = m.ActivateConnection(c, a.dev, nil)
+	} else {
+		active, err = m.ActivateWirelessConnection(c, a.dev, ap)
+	}
+	if err != nil {
+		return err
+	}
+	pending.active = active
+	return nil
 }
 
-var ErrAdapterConfigDel = errors.New(
-	"adapter: delete access-point configuration")
+// activation records what a connect attempt has changed so far.
+type activation struct {
 
-func (a *WifiAdapter) Delete(SSID string) error {
-	cnn, err := a.settingsConnectionOf(SSID)
-	if err != nil {
-		return fmt.Errorf("%w: '%s': %w", ErrAdapterConfigDel, SSID, err)
+	// active is the active connection of the requested activation.
+	active nm.ActiveConnection
+
+	// created is the unsaved connection profile created by the
+	// attempt.
+	created nm.Connection
+}
+
+var ErrRollback = errors.New("rollback")
+
+// rollback deactivates given pending activation's active connection
+// and deletes its created connection profile.  NOTE the profile is
+// deleted even if the deactivation fails.
+func (a *WifiAdapter) rollback(pending *activation) (err error) {
+	if pending.active != nil {
+		err = a.deactivate(pending.active)
 	}
-	if cnn == nil {
-		return fmt.Errorf("%w: no configuration for '%s'",
-			ErrAdapterConfigDel, SSID)
+	if pending.created == nil {
+		return err
 	}
-	if err := cnn.Delete(); err != nil {
-		return fmt.Errorf("%w: '%s': %w", ErrAdapterConfigDel, SSID, err)
+	if e := pending.created.Delete(); e != nil {
+		if err != nil {
+			return fmt.Errorf("%w: delete: %w", err, e)
+		}
+		return fmt.Errorf("%w: delete: %w", ErrRollback, e)
+	}
+	return err
+}
+
+func (a *WifiAdapter) deactivate(active nm.ActiveConnection) error {
+	m, err := a.client.nm()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRollback, err)
+	}
+	if err := m.DeactivateConnection(active); err != nil {
+		return fmt.Errorf("%w: deactivate: %w", ErrRollback, err)
 	}
 	return nil
 }
 
 var ErrGetAccessPoint = errors.New("get access point")
 
-func (a *WifiAdapter) accessPoint(SSID string) (nm.AccessPoint, error) {
+func (a *WifiAdapter) accessPoint(
+	ctx context.Context, SSID string,
+) (nm.AccessPoint, error) {
 	aa, err := a.dev.GetPropertyAccessPoints()
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrGetAccessPoint, err)
 	}
 	if len(aa) == 0 {
-		if _, err = a.Scan(); err != nil {
+		if _, err = a.Scan(ctx); err != nil {
 			return nil, fmt.Errorf("%w: %w", ErrGetAccessPoint, err)
 		}
 		if aa, err = a.dev.GetPropertyAccessPoints(); err != nil {
 			return nil, fmt.Errorf("%w: %w", ErrGetAccessPoint, err)
 		}
 	}
-	for _, ap := range aa {
-		ssid, err := ap.GetPropertySSID()
-		if err != nil {
-			return nil, fmt.Errorf("%w: %w", ErrGetAccessPoint, err)
-		}
-		if ssid != SSID {
-			continue
-		}
-		return ap, nil
+	ap, err := findAccessPoint(aa, SSID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGetAccessPoint, err)
+	}
+	if ap == nil {
+		return nil, fmt.Errorf("%w: %s", ErrGetAccessPoint, "not found")
 	}
-	return nil, fmt.Errorf("%w: %s", ErrGetAccessPoint, "not found")
+	return ap, nil
 }
 
-// wirelessSettings key identifying connection settings for wifi access
-// points.  NOTE NO research was done to evaluate if this is the only
-// possible key for wifi connection settings.
-const wirelessSettings = "802-11-wireless"
-
-func (a *WifiAdapter) settingsConnectionOf(SSID string) (
-	nm.Connection, error,
-) {
-	ss, err := nm.NewSettings()
+// probe scans for the hidden access point with given SSID and returns
+// it if it answered the probe; otherwise nil is returned.
+func (a *WifiAdapter) probe(
+	ctx context.Context, SSID string,
+) (nm.AccessPoint, error) {
+	if _, err := a.Scan(ctx, SSID); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGetAccessPoint, err)
+	}
+	aa, err := a.dev.GetPropertyAccessPoints()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrGetAccessPoint, err)
 	}
-	cc, err := ss.ListConnections()
+	ap, err := findAccessPoint(aa, SSID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrGetAccessPoint, err)
 	}
-	for _, c := range cc {
-		ss, err := c.GetSettings()
+	return ap, nil
+}
+
+// findAccessPoint returns the first of given access points aa with
+// given SSID or nil if there is none.
+func findAccessPoint(
+	aa []nm.AccessPoint, SSID string,
+) (nm.AccessPoint, error) {
+	for _, ap := range aa {
+		ssid, err := ap.GetPropertySSID()
 		if err != nil {
 			return nil, err
 		}
-		if _, ok := ss[wirelessSettings]; !ok {
-			continue
+		if ssid == SSID {
+			return ap, nil
 		}
-		ssid := string(ss[wirelessSettings]["ssid"].([]uint8))
-		if ssid != SSID {
-			continue
-		}
-		return c, nil
 	}
 	return nil, nil
 }
 
+// wirelessSettings key identifying connection settings for wifi access
+// points.  NOTE NO research was done to evaluate if this is the only
+// possible key for wifi connection settings.
+const wirelessSettings = "802-11-wireless"
+
+// wirelessSecurity key identifying the security section of wifi
+// connection settings.
+const wirelessSecurity = "802-11-wireless-security"
+
 var ErrAdapterPropertyChangeTimeout = errors.New(
 	"property change timeout")
 
 func (a *WifiAdapter) waitForPropertyChange(
-	c chan *dbus.Signal, property string,
+	ctx context.Context, c chan *dbus.Signal, property string,
 ) error {
 	for {
 		select {
-		case s := <-c:
+		case s, ok := <-c:
+			if !ok {
+				return ErrHubClosed
+			}
 			if len(s.Body) < 2 {
 				continue
 			}
@@ -318,18 +641,27 @@ func (a *WifiAdapter) waitForPropertyChange(
 				continue
 			}
 			return nil
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-time.After(a.Timeout):
 			return ErrAdapterPropertyChangeTimeout
 		}
 	}
 }
 
+// waitForStateChange waits for given adapter a's device to report given
+// state.  If given state is the activated state waitForStateChange
+// fails fast with a StateError if the device reports a state ending the
+// activation attempt instead of waiting for the timeout.
 func (a *WifiAdapter) waitForStateChange(
-	c chan *dbus.Signal, state nm.NmDeviceState,
+	ctx context.Context, c chan *dbus.Signal, state nm.NmDeviceState,
 ) error {
 	for {
 		select {
-		case s := <-c:
+		case s, ok := <-c:
+			if !ok {
+				return ErrHubClosed
+			}
 			if len(s.Body) < 2 {
 				continue
 			}
@@ -345,11 +677,23 @@ func (a *WifiAdapter) waitForStateChange(
 			if !ok {
 				continue
 			}
-			if nm.NmDeviceState(st) != state {
+			if nm.NmDeviceState(st) == state {
+				return nil
+			}
+			if state != nm.NmDeviceStateActivated {
 				continue
 			}
-			return nil
+			err := activationErr(
+				nm.NmDeviceState(st), stateReasonOf(bodyMap))
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-time.After(a.Timeout):
+			if atomic.LoadInt32(&a.secretRequests) > 0 {
+				continue
+			}
 			return ErrAdapterPropertyChangeTimeout
 		}
 	}
@@ -358,37 +702,31 @@ func (a *WifiAdapter) waitForStateChange(
 const DBusProperties = "org.freedesktop.DBus.Properties"
 const PropertiesChanged = "PropertiesChanged"
 
-func (a *WifiAdapter) setupSignalMatcher() (
+// setupSignalMatcher subscribes at given adapter a's client's signal
+// hub to the PropertiesChanged signals of a's device and the signals
+// matching given additional rules.  The returned deferer ends the
+// subscription.
+func (a *WifiAdapter) setupSignalMatcher(rules ...signalRule) (
 	_ chan *dbus.Signal, deferer func(e, w error) error, _ error,
 ) {
-	cnn, err := a.lib().SystemBus()
+	rules = append([]signalRule{{
+		path:   a.dev.GetPath(),
+		iface:  DBusProperties,
+		member: PropertiesChanged,
+	}}, rules...)
+	c, unsubscribe, err := a.client.hub.subscribe(
+		a.client.lib().SystemBus, rules...)
 	if err != nil {
 		return nil, nil, err
 	}
-	err = cnn.AddMatchSignal(
-		dbus.WithMatchObjectPath(a.dev.GetPath()),
-		dbus.WithMatchInterface(DBusProperties),
-		dbus.WithMatchMember(PropertiesChanged),
-	)
-	if err != nil {
-		if e := cnn.Close(); e != nil {
-			return nil, nil, fmt.Errorf("%w: %w", err, e)
-		}
-		return nil, nil, err
-	}
-	c := make(chan *dbus.Signal, 100)
-	cnn.Signal(c)
 	return c, func(err error, wrapper error) error {
-		cnn.RemoveSignal(c)
-		close(c)
-		if e := cnn.Close(); e != nil {
+		if e := unsubscribe(); e != nil {
 			if err != nil {
 				return fmt.Errorf("%w: %w", err, e)
 			}
 			return fmt.Errorf("%w: %w", wrapper, e)
 		}
 		return err
-
 	}, nil
 }
 
@@ -397,15 +735,26 @@ func (a *WifiAdapter) Name() string { return a.name }
 
 // AdapterLib provides mockable library features.
 type AdapterLib struct {
-	SystemBus             func() (BusConnection, error)
-	WaitForPropertyChange func(chan *dbus.Signal, string) error
-	Disconnect            func() error
+	WaitForPropertyChange func(
+		context.Context, chan *dbus.Signal, string) error
+	Disconnect func() error
+
+	// Password defaults to Client.Password
+	Password func(SSID string) (string, error)
+
+	// RegisterSecretAgent defaults to ClientLib.RegisterSecretAgent
+	RegisterSecretAgent func(*SecretAgent) (
+		unregister func() error, err error)
 }
 
-// newConnectionSettings NOTE no research was done if this basic setup
-// covers all possible configuration-use-cases.
-func newConnectionSettings(SSID string, pwd string) nm.ConnectionSettings {
-	return nm.ConnectionSettings{
+// newConnectionSettings creates the settings of a new connection profile
+// for the access point with given SSID and security sec using given
+// password pwd if sec needs one.  NOTE no research was done if this
+// basic setup covers all possible configuration-use-cases.
+func newConnectionSettings(
+	SSID string, pwd string, sec Security,
+) nm.ConnectionSettings {
+	ss := nm.ConnectionSettings{
 		"ipv4": map[string]interface{}{
 			"method": "auto",
 		},
@@ -424,12 +773,13 @@ func newConnectionSettings(SSID string, pwd string) nm.ConnectionSettings {
 			"ssid":                  []byte(SSID),
 			"mac-address-blacklist": []string{},
 			"mode":                  "infrastructure",
-			"security":              "802-11-wireless-security",
-		},
-		"802-11-wireless-security": map[string]interface{}{
-			"key-mgmt": "wpa-psk",
-			"auth-alg": "open",
-			"psk":      pwd,
 		},
 	}
+	security := wirelessSecuritySettings(sec, pwd)
+	if security == nil {
+		return ss
+	}
+	ss[wirelessSettings]["security"] = wirelessSecurity
+	ss[wirelessSecurity] = security
+	return ss
 }
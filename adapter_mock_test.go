@@ -1,9 +1,10 @@
-package main
+package wifi
 
 import (
+	"context"
 	"errors"
-	"sync"
-	"time"
+
+	"example.com/wifi/nmfake"
 
 	nm "github.com/Wifx/gonetworkmanager/v2"
 	"github.com/godbus/dbus/v5"
@@ -15,22 +16,11 @@ type MckAdapterIsNotActive struct {
 	secondCall bool
 }
 
-func mockAdapterIsNotActive(t *gounit.T, env *Env) *Env {
-	env.Lib.NewNM = func() (nm.NetworkManager, error) {
-		nm_ := mockedNM(t)
-		nm_.(*NMMock).allDevices = failIAllDevicesIfNoWifi(
-			t, nm_.(*NMMock).NetworkManager)
-		return nm_, nil
-	}
-	env.Lib.NewWifiDevice =
-		func(op dbus.ObjectPath) (nm.DeviceWireless, error) {
-			wd, err := nm.NewDeviceWireless(op)
-			if err != nil {
-				t.Fatalf("mock: nm: wifi-device: state err: %v", err)
-			}
-			return &MckAdapterIsNotActive{DeviceWireless: wd}, nil
-		}
-	return env
+func mockAdapterIsNotActive(c *Client) *Client {
+	return mckNMWifiDevice(c, func(
+		wd nm.DeviceWireless) nm.DeviceWireless {
+		return &MckAdapterIsNotActive{DeviceWireless: wd}
+	})
 }
 
 func (m *MckAdapterIsNotActive) GetPropertyState() (
@@ -43,100 +33,36 @@ func (m *MckAdapterIsNotActive) GetPropertyState() (
 	return 0, errors.New("mocked state error")
 }
 
-func mckAdapterEnv(t *gounit.T) *Env {
-	// fail test if no wifi adapter available
-	env := &Env{}
-	env.Lib.NewNM = func() (nm.NetworkManager, error) {
-		nm_ := mockedNM(t)
-		nm_.(*NMMock).allDevices = failIAllDevicesIfNoWifi(
-			t, nm_.(*NMMock).NetworkManager)
-		return nm_, nil
-	}
-	return env
-}
-
 var ErrMckAdapterBusConnectionFailure = errors.New(
 	"bus connection error mock")
 
 func mckAdapterBusFailure(t *gounit.T) *WifiAdapter {
-	adapter, err := mckAdapterEnv(t).Device()
-	t.FatalOn(err)
-	adapter.Lib.SystemBus = func() (BusConnection, error) {
+	adapter := fakeAdapter(t, "")
+	adapter.client.Lib.SystemBus = func() (BusConnection, error) {
 		return nil, ErrMckAdapterBusConnectionFailure
 	}
 	return adapter
 }
 
-/*
-Understandably the NetworkManager is not very happy being flooded with
-scan requests.  Hence we need to mock-up the DeviceWireless method,
-RequestScan and the the library function WaitForPropertyChange to be run
-only once per test run.
-*/
-
-var scanner = &sync.Mutex{}
-var hasScanned = false
-var hasWaited = false
-
+// MckDevice wraps a fake wifi device whose RequestScan and
+// GetPropertyAccessPoints methods may be replaced.
 type MckDevice struct {
 	nm.DeviceWireless
 	requestScan             func() error
 	getPropertyAccessPoints func() ([]nm.AccessPoint, error)
 }
 
-var mckAccessPoints []nm.AccessPoint
-
-func mckDevices(t *gounit.T) *Env {
-	env := mckAdapterEnv(t)
-	env.Lib.NewWifiDevice =
-		func(op dbus.ObjectPath) (nm.DeviceWireless, error) {
-			wd, err := nm.NewDeviceWireless(op)
-			if err != nil {
-				t.Fatalf("mock: wifi-device: %v", err)
-			}
-			return &MckDevice{DeviceWireless: wd}, nil
-		}
-	env.Lib.NewWifiAdapter =
-		func(d nm.DeviceWireless, n string) *WifiAdapter {
-			adapter := &WifiAdapter{
-				Timeout: 5 * time.Second,
-				name:    n,
-				dev:     d,
-				env:     env,
-			}
-			lib := adapter.lib()
-			defaultWaitForPropertyChange := lib.WaitForPropertyChange
-			adapter.Lib.WaitForPropertyChange =
-				func(c chan *dbus.Signal, s string) error {
-					scanner.Lock()
-					defer scanner.Unlock()
-					if hasWaited {
-						return nil
-					}
-					hasWaited = true
-					err := defaultWaitForPropertyChange(c, s)
-					t.FatalOn(err)
-					aa, err := adapter.dev.(*MckDevice).DeviceWireless.
-						GetPropertyAccessPoints()
-					t.FatalOn(err)
-					mckAccessPoints = aa
-					return nil
-				}
-			return adapter
-		}
-	return env
+func mckDevices() *Client {
+	return mckNMWifiDevice(mckFakeNM(&Client{}, nmfake.Default()), func(
+		wd nm.DeviceWireless) nm.DeviceWireless {
+		return &MckDevice{DeviceWireless: wd}
+	})
 }
 
 func (m *MckDevice) RequestScan() error {
 	if m.requestScan != nil {
 		return m.requestScan()
 	}
-	scanner.Lock()
-	defer scanner.Unlock()
-	if hasScanned {
-		return nil
-	}
-	hasScanned = true
 	return m.DeviceWireless.RequestScan()
 }
 
@@ -144,26 +70,25 @@ func (m *MckDevice) GetPropertyAccessPoints() ([]nm.AccessPoint, error) {
 	if m.getPropertyAccessPoints != nil {
 		return m.getPropertyAccessPoints()
 	}
-	scanner.Lock()
-	defer scanner.Unlock()
-	return mckAccessPoints, nil
+	return m.DeviceWireless.GetPropertyAccessPoints()
 }
 
-func mockedDevice(t *gounit.T) (*Env, *WifiAdapter) {
-	env := mckDevices(t)
-	adapter, err := env.Device()
+func mockedDevice(t *gounit.T) (*Client, *WifiAdapter) {
+	client := mckDevices()
+	adapter, err := client.Adapter(context.Background(), "")
 	t.FatalOn(err)
-	return env, adapter
+	return client, adapter
 }
 
-type MckAdapterCnnCloseErr struct{ *dbus.Conn }
+type MckAdapterCnnCloseErr struct{ BusConnection }
 
 func mckAdapterCnnCloseFailure(t *gounit.T) *WifiAdapter {
 	_, adapter := mockedDevice(t)
-	adapter.Lib.SystemBus = func() (BusConnection, error) {
-		cnn, err := dbus.ConnectSystemBus()
+	systemBus := adapter.client.lib().SystemBus
+	adapter.client.Lib.SystemBus = func() (BusConnection, error) {
+		cnn, err := systemBus()
 		t.FatalOn(err)
-		return &MckAdapterCnnCloseErr{Conn: cnn}, nil
+		return &MckAdapterCnnCloseErr{BusConnection: cnn}, nil
 	}
 	return adapter
 }
@@ -171,17 +96,20 @@ func mckAdapterCnnCloseFailure(t *gounit.T) *WifiAdapter {
 var ErrMckAdapterCnnCloseFailure = errors.New(
 	"bus connection close error mock")
 
-func (m *MckAdapterCnnCloseErr) Close() error { return ErrMckAdapterCnnCloseFailure }
+func (m *MckAdapterCnnCloseErr) Close() error {
+	m.BusConnection.Close()
+	return ErrMckAdapterCnnCloseFailure
+}
 
-type MckAdapterSignalMatcherFailure struct{ *dbus.Conn }
+type MckAdapterSignalMatcherFailure struct{ BusConnection }
 
 func mckAdapterSignalMatcherFailure(t *gounit.T) *WifiAdapter {
-	adapter, err := mckAdapterEnv(t).Device()
-	t.FatalOn(err)
-	adapter.Lib.SystemBus = func() (BusConnection, error) {
-		cnn, err := dbus.ConnectSystemBus()
+	adapter := fakeAdapter(t, "")
+	systemBus := adapter.client.lib().SystemBus
+	adapter.client.Lib.SystemBus = func() (BusConnection, error) {
+		cnn, err := systemBus()
 		t.FatalOn(err)
-		return &MckAdapterSignalMatcherFailure{Conn: cnn}, nil
+		return &MckAdapterSignalMatcherFailure{BusConnection: cnn}, nil
 	}
 	return adapter
 }
@@ -205,7 +133,13 @@ func mckAdapterScanFails(t *gounit.T) *WifiAdapter {
 	return adapter
 }
 
-type MckAdapterAccessPointsFailure struct{ nm.DeviceWireless }
+// mckAdapterScanNoChange mocks given adapter's scan request to not
+// trigger a LastScan change.
+func mckAdapterScanNoChange(t *gounit.T) *WifiAdapter {
+	_, adapter := mockedDevice(t)
+	adapter.dev.(*MckDevice).requestScan = func() error { return nil }
+	return adapter
+}
 
 var ErrMckAdapterAccessPointsFailure = errors.New(
 	"adapter scan fails mock")
@@ -219,27 +153,39 @@ func mckAdapterAccessPointsFailure(t *gounit.T) *WifiAdapter {
 	return adapter
 }
 
+// mckAdapterAccessPoints wraps the access points of given adapter's
+// device by given factory.
+func mckAdapterAccessPoints(
+	adapter *WifiAdapter, factory func(nm.AccessPoint) nm.AccessPoint,
+) *WifiAdapter {
+	dev := adapter.dev.(*MckDevice)
+	dev.getPropertyAccessPoints = func() ([]nm.AccessPoint, error) {
+		aa, err := dev.DeviceWireless.GetPropertyAccessPoints()
+		if err != nil {
+			return nil, err
+		}
+		mck := []nm.AccessPoint{}
+		for _, ap := range aa {
+			mck = append(mck, factory(ap))
+		}
+		return mck, nil
+	}
+	return adapter
+}
+
 var ErrMckAdapterSSIDFailure = errors.New("adapter SSID failure mock")
 
 func mckAdapterSSIDFailure(t *gounit.T) *WifiAdapter {
 	_, adapter := mockedDevice(t)
-	adapter.dev.(*MckDevice).getPropertyAccessPoints =
-		func() ([]nm.AccessPoint, error) {
-			scanner.Lock()
-			aa := mckAccessPoints
-			scanner.Unlock()
-			mck := []nm.AccessPoint{}
-			for _, ap := range aa {
-				mck = append(mck, &MckAccessPoint{
-					AccessPoint: ap,
-					getPropertySSID: func() (string, error) {
-						return "", ErrMckAdapterSSIDFailure
-					},
-				})
-			}
-			return mck, nil
+	return mckAdapterAccessPoints(adapter, func(
+		ap nm.AccessPoint) nm.AccessPoint {
+		return &MckAccessPoint{
+			AccessPoint: ap,
+			getPropertySSID: func() (string, error) {
+				return "", ErrMckAdapterSSIDFailure
+			},
 		}
-	return adapter
+	})
 }
 
 var ErrMckAdapterSignalStrengthFailure = errors.New(
@@ -247,23 +193,15 @@ var ErrMckAdapterSignalStrengthFailure = errors.New(
 
 func mckAdapterSignalStrengthFailure(t *gounit.T) *WifiAdapter {
 	_, adapter := mockedDevice(t)
-	adapter.dev.(*MckDevice).getPropertyAccessPoints =
-		func() ([]nm.AccessPoint, error) {
-			scanner.Lock()
-			aa := mckAccessPoints
-			scanner.Unlock()
-			mck := []nm.AccessPoint{}
-			for _, ap := range aa {
-				mck = append(mck, &MckAccessPoint{
-					AccessPoint: ap,
-					getPropertyStrength: func() (uint8, error) {
-						return 0, ErrMckAdapterSignalStrengthFailure
-					},
-				})
-			}
-			return mck, nil
+	return mckAdapterAccessPoints(adapter, func(
+		ap nm.AccessPoint) nm.AccessPoint {
+		return &MckAccessPoint{
+			AccessPoint: ap,
+			getPropertyStrength: func() (uint8, error) {
+				return 0, ErrMckAdapterSignalStrengthFailure
+			},
 		}
-	return adapter
+	})
 }
 
 type MckAccessPoint struct {
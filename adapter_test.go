@@ -1,12 +1,14 @@
-package main
+package wifi
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"math"
-	"os"
 	"testing"
 	"time"
 
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
 	. "github.com/slukits/gounit"
 )
 
@@ -15,58 +17,50 @@ type AnAdapter struct{ Suite }
 func (s *AnAdapter) SetUp(t *T) { t.Parallel() }
 
 func (s *AnAdapter) Is_not_activated_if_state_retrieval_fails(t *T) {
-	dev, err := mockAdapterIsNotActive(t, &Env{}).Device()
+	dev, err := mockAdapterIsNotActive(&Client{}).Adapter(bg, "")
 	t.FatalOn(err)
 	t.Not.True(dev.IsActivated())
 }
 
 func (s *AnAdapter) Scan_fails_on_system_bus_connection_failure(t *T) {
 	adapter := mckAdapterBusFailure(t)
-	_, err := adapter.Scan()
+	_, err := adapter.Scan(bg)
 	t.ErrIs(err, ErrAdapterScan)
 	t.ErrIs(err, ErrMckAdapterBusConnectionFailure)
 }
 
-func (s *AnAdapter) Scan_fails_on_connection_close_failure(t *T) {
-	adapter := mckAdapterCnnCloseFailure(t)
-	_, err := adapter.Scan()
-	t.ErrIs(err, ErrAdapterScan)
-	t.ErrIs(err, ErrMckAdapterCnnCloseFailure)
-}
-
 func (s *AnAdapter) Scan_fails_on_signal_matcher_setup_failure(t *T) {
 	adapter := mckAdapterSignalMatcherFailure(t)
-	_, err := adapter.Scan()
+	_, err := adapter.Scan(bg)
 	t.ErrIs(err, ErrAdapterScan)
 	t.ErrIs(err, ErrMckAdapterSignalMatcherFailure)
 }
 
 func (s *AnAdapter) Scan_fails_on_device_scan_failure(t *T) {
 	adapter := mckAdapterScanFails(t)
-	_, err := adapter.Scan()
+	_, err := adapter.Scan(bg)
 	t.ErrIs(err, ErrAdapterScan)
 	t.ErrIs(err, ErrMckAdapterScanFails)
 }
 
 func (s *AnAdapter) Scan_fails_on_last_scan_change_timeout(t *T) {
-	adapter, err := (&Env{}).Device()
-	t.FatalOn(err)
+	adapter := mckAdapterScanNoChange(t)
 	adapter.Timeout = 0 * time.Second
-	_, err = adapter.Scan()
+	_, err := adapter.Scan(bg)
 	t.ErrIs(err, ErrAdapterScan)
 	t.ErrIs(err, ErrAdapterPropertyChangeTimeout)
 }
 
 func (s *AnAdapter) Scan_fails_on_access_points_retrieval_failure(t *T) {
 	adapter := mckAdapterAccessPointsFailure(t)
-	_, err := adapter.Scan()
+	_, err := adapter.Scan(bg)
 	t.ErrIs(err, ErrAdapterScan)
 	t.ErrIs(err, ErrMckAdapterAccessPointsFailure)
 }
 
 func (s *AnAdapter) Scan_fails_on_SSID_retrieval_failure(t *T) {
 	adapter := mckAdapterSSIDFailure(t)
-	_, err := adapter.Scan()
+	_, err := adapter.Scan(bg)
 	t.ErrIs(err, ErrAdapterScan)
 	t.ErrIs(err, ErrMckAdapterSSIDFailure)
 }
@@ -75,7 +69,7 @@ func (s *AnAdapter) Scan_fails_on_signal_strength_retrieval_failure(
 	t *T,
 ) {
 	adapter := mckAdapterSignalStrengthFailure(t)
-	_, err := adapter.Scan()
+	_, err := adapter.Scan(bg)
 	t.ErrIs(err, ErrAdapterScan)
 	t.ErrIs(err, ErrMckAdapterSignalStrengthFailure)
 }
@@ -84,7 +78,7 @@ func (s *AnAdapter) Scan_provides_access_points_descending_by_strength(
 	t *T,
 ) {
 	_, adapter := mockedDevice(t)
-	aa, err := adapter.Scan()
+	aa, err := adapter.Scan(bg)
 	t.FatalOn(err)
 	last := uint8(math.MaxInt8)
 	for _, a := range aa {
@@ -94,23 +88,209 @@ func (s *AnAdapter) Scan_provides_access_points_descending_by_strength(
 	t.True(len(aa) > 0)
 }
 
+func (s *AnAdapter) Connect_asks_secret_agent_for_changed_password(
+	t *T,
+) {
+	fake := nmfake.Default()
+	fake.Device("wlan0").APs[0].Password = "changed-secret"
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "")
+	t.FatalOn(err)
+	adapter.Lib.Password = func(SSID string) (string, error) {
+		return "changed-secret", nil
+	}
+	t.FatalOn(adapter.Connect(bg, "home"))
+	t.True(adapter.IsActivated())
+	ss, err := fake.Profiles()[0].GetSecrets(wirelessSecurity)
+	t.FatalOn(err)
+	t.Eq("changed-secret", ss[wirelessSecurity]["psk"])
+}
+
+// stallingActivation lets an activation never complete.
+func stallingActivation(
+	*nmfake.Device, *nmfake.Connection, *nmfake.AP,
+) []nmfake.Transition {
+	return []nmfake.Transition{
+		{State: nm.NmDeviceStatePrepare},
+		{State: nm.NmDeviceStateConfig},
+	}
+}
+
+func (s *AnAdapter) Connect_rolls_back_a_canceled_activation(t *T) {
+	fake := nmfake.Default()
+	fake.Device("wlan0").Activate = stallingActivation
+	client := &Client{Password: func(string) (string, error) {
+		return "office-secret", nil
+	}}
+	adapter, err := mckFakeNM(client, fake).Adapter(bg, "")
+	t.FatalOn(err)
+	ctx, cancel := context.WithTimeout(bg, 20*time.Millisecond)
+	defer cancel()
+	err = adapter.Connect(ctx, "office")
+	t.ErrIs(err, ErrAdapterConnect)
+	t.ErrIs(err, context.DeadlineExceeded)
+	t.Eq(1, len(fake.Profiles()))
+	t.Eq(nm.NmDeviceStateDisconnected, fake.Device("wlan0").State)
+}
+
+func (s *AnAdapter) Connect_keeps_known_profile_if_canceled(t *T) {
+	fake := nmfake.Default()
+	fake.Device("wlan0").Activate = stallingActivation
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "")
+	t.FatalOn(err)
+	ctx, cancel := context.WithTimeout(bg, 20*time.Millisecond)
+	defer cancel()
+	t.ErrIs(adapter.Connect(ctx, "home"), context.DeadlineExceeded)
+	t.Eq(1, len(fake.Profiles()))
+	t.Eq(nm.NmDeviceStateDisconnected, fake.Device("wlan0").State)
+}
+
+func (s *AnAdapter) Connect_saves_new_profile_once_activated(t *T) {
+	fake := nmfake.Default()
+	client := &Client{Password: func(string) (string, error) {
+		return "office-secret", nil
+	}}
+	adapter, err := mckFakeNM(client, fake).Adapter(bg, "")
+	t.FatalOn(err)
+	t.FatalOn(adapter.Connect(bg, "office"))
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	t.Not.True(pp[1].Unsaved)
+}
+
+func (s *AnAdapter) Connect_deletes_new_profile_if_activation_fails(
+	t *T,
+) {
+	fake := nmfake.Default()
+	client := &Client{Password: func(string) (string, error) {
+		return "wrong", nil
+	}}
+	adapter, err := mckFakeNM(client, fake).Adapter(bg, "")
+	t.FatalOn(err)
+	err = adapter.Connect(bg, "office")
+	t.ErrIs(err, ErrWrongSecrets)
+	t.Not.True(errors.Is(err, ErrRollback))
+	t.Eq(1, len(fake.Profiles()))
+}
+
+func (s *AnAdapter) Connects_with_selected_profile(t *T) {
+	client := officeAtHome()
+	adapter, err := client.Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	t.FatalOn(adapter.ConnectProfile(bg,
+		ProfileSelector{UUID: "fake-home-office"}))
+	active, err := adapter.dev.GetPropertyActiveConnection()
+	t.FatalOn(err)
+	cnn, err := active.GetPropertyConnection()
+	t.FatalOn(err)
+	ss, err := cnn.GetSettings()
+	t.FatalOn(err)
+	t.Eq("home-office", ss["connection"]["id"])
+	err = adapter.ConnectProfile(bg, ProfileSelector{ID: "unknown"})
+	t.ErrIs(err, ErrAdapterConnect)
+	t.ErrIs(err, ErrNoProfile)
+}
+
+// withHiddenLab adds the hidden access point "lab" to the device "wlan0"
+// of given fake network manager and returns a client of the fake
+// providing the lab's password.
+func withHiddenLab(fake *nmfake.NM) *Client {
+	fake.Device("wlan0").AddAP(&nmfake.AP{
+		SSID: "lab", BSSID: "00:00:00:00:00:10", Strength: 50,
+		Frequency: 2462, Flags: uint32(nm.Nm80211APFlagsPrivacy),
+		RSNFlags: uint32(nm.Nm80211APSecKeyMgmtPSK),
+		Password: "lab-secret", Hidden: true,
+	})
+	return mckFakeNM(&Client{Password: func(string) (string, error) {
+		return "lab-secret", nil
+	}}, fake)
+}
+
+func (s *AnAdapter) Scan_probes_given_hidden_SSIDs(t *T) {
+	adapter, err := withHiddenLab(nmfake.Default()).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	ssids := func(aa []AccessPoint) (ss []string) {
+		for _, a := range aa {
+			ss = append(ss, a.SSID)
+		}
+		return ss
+	}
+	aa, err := adapter.Scan(bg)
+	t.FatalOn(err)
+	t.Eq([]string{"home", "office", "", "cafe"}, ssids(aa))
+	aa, err = adapter.Scan(bg, "lab")
+	t.FatalOn(err)
+	t.Eq([]string{"home", "office", "lab", "cafe"}, ssids(aa))
+}
+
+func (s *AnAdapter) Connects_to_a_hidden_access_point(t *T) {
+	fake := nmfake.Default()
+	adapter, err := withHiddenLab(fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	t.FatalOn(adapter.ConnectHidden(bg, ProfileSelector{SSID: "lab"}))
+	ssid, err := adapter.Active(bg)
+	t.FatalOn(err)
+	t.Eq("lab", ssid)
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	t.Eq(true, pp[1].Settings()[wirelessSettings]["hidden"])
+	t.Eq("wpa-psk", pp[1].Settings()[wirelessSecurity]["key-mgmt"])
+	t.FatalOn(adapter.Connect(bg, "home"))
+	t.FatalOn(adapter.ConnectHidden(bg, ProfileSelector{SSID: "lab"}))
+	t.Eq(2, len(fake.Profiles()))
+}
+
+func (s *AnAdapter) Connect_to_a_hidden_SSID_fails_if_not_found(t *T) {
+	fake := nmfake.Default()
+	adapter, err := withHiddenLab(fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	err = adapter.ConnectHidden(bg, ProfileSelector{SSID: "attic"})
+	t.ErrIs(err, ErrAdapterConnect)
+	t.ErrIs(err, ErrSSIDNotFound)
+	t.Eq(1, len(fake.Profiles()))
+}
+
+func (s *AnAdapter) Describes_itself(t *T) {
+	info, err := fakeAdapter(t, "").Info(bg)
+	t.FatalOn(err)
+	t.Eq(AdapterInfo{Name: "wlan0", MAC: "02:00:00:00:00:01",
+		Driver: "fake", State: "activated", Managed: true,
+		Autoconnect: true, SSID: "home"}, info)
+}
+
+func (s *AnAdapter) Has_no_active_SSID_if_disconnected(t *T) {
+	adapter := fakeAdapter(t, "")
+	t.FatalOn(adapter.Disconnect(bg))
+	_, err := adapter.Active(bg)
+	t.ErrIs(err, ErrNotConnected)
+	info, err := adapter.Info(bg)
+	t.FatalOn(err)
+	t.Eq("", info.SSID)
+	t.Eq("disconnected", info.State)
+}
+
+func (s *AnAdapter) Scan_ends_if_its_context_is_canceled(t *T) {
+	adapter := mckAdapterScanNoChange(t)
+	adapter.Timeout = time.Minute
+	ctx, cancel := context.WithCancel(bg)
+	cancel()
+	_, err := adapter.Scan(ctx)
+	t.ErrIs(err, ErrAdapterScan)
+	t.ErrIs(err, context.Canceled)
+}
+
 func TestAnAdapter(t *testing.T) {
 	t.Parallel()
 	Run(&AnAdapter{}, t)
 }
 
 func TestDisconnectAndReconnect(t_ *testing.T) {
+	t_.Parallel()
 	t := NewT(t_)
-	if os.Args[len(os.Args)-1] != "all" {
-		fmt.Println("TestDisconnectAndReconnect skipped; " +
-			"call 'go test -args all' to include this test")
-		t_.SkipNow()
-	}
 	_, adapter := mockedDevice(t)
-	ssid, err := adapter.Active()
+	ssid, err := adapter.Active(bg)
 	t.FatalOn(err)
-	t.FatalOn(adapter.Disconnect())
+	t.FatalOn(adapter.Disconnect(bg))
 	t.Not.True(adapter.IsActivated())
-	t.FatalOn(adapter.Connect(ssid))
+	t.FatalOn(adapter.Connect(bg, ssid))
 	t.True(adapter.IsActivated())
 }
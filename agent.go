@@ -0,0 +1,155 @@
+package wifi
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	SecretAgentInterface  = nm.NetworkManagerInterface + ".SecretAgent"
+	SecretAgentPath       = "/org/freedesktop/NetworkManager/SecretAgent"
+	AgentManagerInterface = nm.NetworkManagerInterface + ".AgentManager"
+	AgentManagerPath      = nm.NetworkManagerObjectPath + "/AgentManager"
+
+	// SecretAgentID identifies the wifi secret agent at the
+	// AgentManager.
+	SecretAgentID = "wifi.cli.secret-agent"
+)
+
+// secret agent errors as defined by NetworkManager's SecretAgent API
+const (
+	secretAgentNoSecrets    = SecretAgentInterface + ".NoSecrets"
+	secretAgentUserCanceled = SecretAgentInterface + ".UserCanceled"
+)
+
+// flags of a GetSecrets request
+const (
+	secretsAllowInteraction uint32 = 0x1
+	secretsRequestNew       uint32 = 0x2
+)
+
+// SecretAgent implements NetworkManager's SecretAgent D-Bus interface
+// answering secret requests of wifi connections by querying a password
+// source.  A SecretAgent is created and registered by a connecting
+// WifiAdapter.  NOTE all exported methods of a SecretAgent are exported
+// on the bus.
+type SecretAgent struct {
+
+	// password provides the password for given SSID.
+	password func(SSID string) (string, error)
+
+	// pending counts the secret requests in progress.
+	pending *int32
+}
+
+// GetSecrets answers NetworkManager's request for the secrets of given
+// connection if they belong to the 802-11-wireless-security setting and
+// the request allows for interaction or requests new secrets.
+func (a *SecretAgent) GetSecrets(
+	connection map[string]map[string]dbus.Variant,
+	path dbus.ObjectPath, setting string, hints []string, flags uint32,
+) (map[string]map[string]dbus.Variant, *dbus.Error) {
+	if setting != wirelessSecurity ||
+		flags&(secretsAllowInteraction|secretsRequestNew) == 0 {
+		return nil, noSecretsErr(setting)
+	}
+	ssid, ok := connection[wirelessSettings]["ssid"].Value().([]byte)
+	if !ok {
+		return nil, noSecretsErr(setting)
+	}
+	keyMgmt, _ := connection[wirelessSecurity]["key-mgmt"].Value().(string)
+	key := "psk"
+	switch keyMgmt {
+	case "none":
+		key = "wep-key0"
+	case "wpa-psk", "sae":
+	default:
+		return nil, noSecretsErr(setting)
+	}
+	atomic.AddInt32(a.pending, 1)
+	defer atomic.AddInt32(a.pending, -1)
+	pwd, err := a.password(string(ssid))
+	if err != nil {
+		return nil, dbus.NewError(secretAgentUserCanceled,
+			[]interface{}{err.Error()})
+	}
+	return map[string]map[string]dbus.Variant{
+		wirelessSecurity: {key: dbus.MakeVariant(pwd)},
+	}, nil
+}
+
+// CancelGetSecrets is a no-op since GetSecrets can't be interrupted.
+func (a *SecretAgent) CancelGetSecrets(
+	path dbus.ObjectPath, setting string,
+) *dbus.Error {
+	return nil
+}
+
+// SaveSecrets is a no-op since the agent doesn't store secrets.
+func (a *SecretAgent) SaveSecrets(
+	connection map[string]map[string]dbus.Variant, path dbus.ObjectPath,
+) *dbus.Error {
+	return nil
+}
+
+// DeleteSecrets is a no-op since the agent doesn't store secrets.
+func (a *SecretAgent) DeleteSecrets(
+	connection map[string]map[string]dbus.Variant, path dbus.ObjectPath,
+) *dbus.Error {
+	return nil
+}
+
+func noSecretsErr(setting string) *dbus.Error {
+	return dbus.NewError(secretAgentNoSecrets, []interface{}{
+		fmt.Sprintf("no secrets for setting '%s'", setting)})
+}
+
+var ErrSecretAgent = errors.New("secret agent")
+
+// registerSecretAgent exports given agent on a new connection to the
+// client's bus and registers it at NetworkManager's AgentManager.  The
+// returned function unregisters the agent and closes the connection.
+func (c *Client) registerSecretAgent(
+	agent *SecretAgent,
+) (func() error, error) {
+	cnn, err := c.connectBus()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSecretAgent, err)
+	}
+	err = cnn.Export(agent, SecretAgentPath, SecretAgentInterface)
+	if err != nil {
+		return nil, closeOnErr(cnn, fmt.Errorf("%w: %w",
+			ErrSecretAgent, err))
+	}
+	manager := cnn.Object(nm.NetworkManagerInterface, AgentManagerPath)
+	err = manager.Call(AgentManagerInterface+".Register", 0,
+		SecretAgentID).Err
+	if err != nil {
+		return nil, closeOnErr(cnn, fmt.Errorf("%w: %w",
+			ErrSecretAgent, err))
+	}
+	return func() error {
+		err := manager.Call(AgentManagerInterface+".Unregister", 0).Err
+		if err != nil {
+			return closeOnErr(cnn, fmt.Errorf("%w: %w",
+				ErrSecretAgent, err))
+		}
+		if err := cnn.Close(); err != nil {
+			return fmt.Errorf("%w: %w", ErrSecretAgent, err)
+		}
+		return nil
+	}, nil
+}
+
+// closeOnErr closes given connection cnn and returns given error err
+// joined with a potential close error.
+func closeOnErr(cnn BusConnection, err error) error {
+	if e := cnn.Close(); e != nil {
+		return fmt.Errorf("%w: %w", err, e)
+	}
+	return err
+}
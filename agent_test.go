@@ -0,0 +1,84 @@
+package wifi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	. "github.com/slukits/gounit"
+)
+
+type ASecretAgent struct{ Suite }
+
+func (s *ASecretAgent) SetUp(t *T) { t.Parallel() }
+
+// mckSecretAgent returns a secret agent providing given password pwd
+// for SSID "ssid" and failing with given error err otherwise.
+func mckSecretAgent(pwd string, err error) *SecretAgent {
+	return &SecretAgent{
+		password: func(SSID string) (string, error) {
+			if SSID != "ssid" || err != nil {
+				return "", err
+			}
+			return pwd, nil
+		},
+		pending: new(int32),
+	}
+}
+
+// secretsRequest returns a connection requesting secrets for a wifi
+// access point with given key management keyMgmt.
+func secretsRequest(keyMgmt string) map[string]map[string]dbus.Variant {
+	return map[string]map[string]dbus.Variant{
+		wirelessSettings: {"ssid": dbus.MakeVariant([]byte("ssid"))},
+		wirelessSecurity: {"key-mgmt": dbus.MakeVariant(keyMgmt)},
+	}
+}
+
+func (s *ASecretAgent) Provides_psk_if_interaction_is_allowed(t *T) {
+	ss, err := mckSecretAgent("secret", nil).GetSecrets(
+		secretsRequest("wpa-psk"), "/", wirelessSecurity, nil,
+		secretsAllowInteraction)
+	t.True(err == nil)
+	t.Eq("secret", ss[wirelessSecurity]["psk"].Value())
+}
+
+func (s *ASecretAgent) Provides_wep_key_if_new_secrets_requested(t *T) {
+	ss, err := mckSecretAgent("secret", nil).GetSecrets(
+		secretsRequest("none"), "/", wirelessSecurity, nil,
+		secretsRequestNew)
+	t.True(err == nil)
+	t.Eq("secret", ss[wirelessSecurity]["wep-key0"].Value())
+}
+
+func (s *ASecretAgent) Has_no_secrets_without_interaction(t *T) {
+	_, err := mckSecretAgent("secret", nil).GetSecrets(
+		secretsRequest("wpa-psk"), "/", wirelessSecurity, nil, 0)
+	t.Eq(secretAgentNoSecrets, err.Name)
+}
+
+func (s *ASecretAgent) Has_no_secrets_for_other_settings(t *T) {
+	_, err := mckSecretAgent("secret", nil).GetSecrets(
+		secretsRequest("wpa-psk"), "/", "vpn", nil,
+		secretsAllowInteraction)
+	t.Eq(secretAgentNoSecrets, err.Name)
+	_, err = mckSecretAgent("secret", nil).GetSecrets(
+		secretsRequest("wpa-eap"), "/", wirelessSecurity, nil,
+		secretsAllowInteraction)
+	t.Eq(secretAgentNoSecrets, err.Name)
+}
+
+func (s *ASecretAgent) Reports_canceled_request_on_password_failure(
+	t *T,
+) {
+	agent := mckSecretAgent("", errors.New("password error mock"))
+	_, err := agent.GetSecrets(secretsRequest("sae"), "/",
+		wirelessSecurity, nil, secretsAllowInteraction)
+	t.Eq(secretAgentUserCanceled, err.Name)
+	t.Eq(int32(0), *agent.pending)
+}
+
+func TestASecretAgent(t *testing.T) {
+	t.Parallel()
+	Run(&ASecretAgent{}, t)
+}
@@ -0,0 +1,116 @@
+package wifi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+// SYSTEM_BUS_ADDRESS is the environment variable godbus evaluates to
+// connect to the system bus.
+const SYSTEM_BUS_ADDRESS = "DBUS_SYSTEM_BUS_ADDRESS"
+
+var ErrBus = errors.New("wifi: bus")
+
+// systemBus remembers the bus address godbus's system bus connection
+// was first opened with.  NOTE gonetworkmanager shares this connection
+// which is established on first use from SYSTEM_BUS_ADDRESS and can't
+// be pointed to another bus afterwards.
+type systemBus struct {
+	mutex   sync.Mutex
+	opened  bool
+	address string
+}
+
+// sharedSystemBus is the system bus of all clients of a process.
+var sharedSystemBus = &systemBus{}
+
+// redirectBus points the system bus to given client c's bus address if
+// set.  redirectBus fails with ErrBus if the system bus was opened with
+// another address by a previous client.
+func (c *Client) redirectBus() error {
+	bus := c.bus
+	if bus == nil {
+		bus = sharedSystemBus
+	}
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.opened {
+		if bus.address != c.BusAddress {
+			return fmt.Errorf("%w: system bus is opened at '%s' "+
+				"instead of '%s'", ErrBus, bus.address, c.BusAddress)
+		}
+		return nil
+	}
+	if c.BusAddress != "" {
+		err := c.lib().Setenv(SYSTEM_BUS_ADDRESS, c.BusAddress)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrBus, err)
+		}
+	}
+	bus.opened, bus.address = true, c.BusAddress
+	return nil
+}
+
+// connectBus returns a new private connection to given client c's bus.
+func (c *Client) connectBus() (*dbus.Conn, error) {
+	if c.BusAddress == "" {
+		cnn, err := dbus.ConnectSystemBus()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBus, err)
+		}
+		return cnn, nil
+	}
+	cnn, err := dbus.Connect(c.BusAddress)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s': %w", ErrBus, c.BusAddress, err)
+	}
+	return cnn, nil
+}
+
+func (c *Client) newNM() (nm.NetworkManager, error) {
+	if err := c.redirectBus(); err != nil {
+		return nil, err
+	}
+	return nm.NewNetworkManager()
+}
+
+func (c *Client) newWifiDevice(
+	path dbus.ObjectPath,
+) (nm.DeviceWireless, error) {
+	if err := c.redirectBus(); err != nil {
+		return nil, err
+	}
+	return nm.NewDeviceWireless(path)
+}
+
+func (c *Client) newSettings() (nm.Settings, error) {
+	if err := c.redirectBus(); err != nil {
+		return nil, err
+	}
+	return nm.NewSettings()
+}
+
+// requestScan requests a scan of the wifi device with given path which
+// sends directed probes for given SSIDs to find hidden access points.
+// The request is sent over a private connection to given client c's
+// bus.  NOTE gonetworkmanager's RequestScan doesn't pass scan options.
+func (c *Client) requestScan(
+	path dbus.ObjectPath, SSIDs []string,
+) error {
+	bus, err := c.connectBus()
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+	ssids := [][]byte{}
+	for _, s := range SSIDs {
+		ssids = append(ssids, []byte(s))
+	}
+	return bus.Object(nm.NetworkManagerInterface, path).Call(
+		nm.DeviceWirelessRequestScan, 0,
+		map[string]interface{}{"ssids": ssids}).Err
+}
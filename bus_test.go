@@ -0,0 +1,83 @@
+package wifi
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/slukits/gounit"
+)
+
+type ABus struct{ Suite }
+
+func (s *ABus) SetUp(t *T) { t.Parallel() }
+
+// mckBusClient returns a client with given bus address whose Setenv
+// calls are recorded in given map set.
+func mckBusClient(address string, set map[string]string) *Client {
+	client := &Client{BusAddress: address, bus: &systemBus{}}
+	client.Lib.Setenv = func(key, value string) error {
+		set[key] = value
+		return nil
+	}
+	return client
+}
+
+func (s *ABus) Defaults_to_the_system_bus(t *T) {
+	set := map[string]string{}
+	t.FatalOn(mckBusClient("", set).redirectBus())
+	t.Eq(0, len(set))
+}
+
+func (s *ABus) Redirects_the_system_bus_to_the_selected_address(t *T) {
+	set := map[string]string{}
+	t.FatalOn(mckBusClient("unix:path=/opt", set).redirectBus())
+	t.Eq("unix:path=/opt", set[SYSTEM_BUS_ADDRESS])
+}
+
+func (s *ABus) Fails_if_opened_with_another_address(t *T) {
+	set := map[string]string{}
+	first := mckBusClient("unix:path=/opt", set)
+	t.FatalOn(first.redirectBus())
+	second := mckBusClient("unix:path=/opt", set)
+	second.bus = first.bus
+	t.FatalOn(second.redirectBus())
+	for _, address := range []string{"unix:path=/other", ""} {
+		other := mckBusClient(address, set)
+		other.bus = first.bus
+		t.ErrIs(other.redirectBus(), ErrBus)
+		_, err := other.lib().NewNM()
+		t.ErrIs(err, ErrBus)
+	}
+	t.Eq(map[string]string{SYSTEM_BUS_ADDRESS: "unix:path=/opt"}, set)
+}
+
+func (s *ABus) Fails_network_manager_creation_if_redirect_fails(t *T) {
+	client := mckBusClient("unix:path=/env", map[string]string{})
+	client.Lib.Setenv = func(string, string) error {
+		return errors.New("setenv error mock")
+	}
+	_, err := client.lib().NewNM()
+	t.ErrIs(err, ErrBus)
+	_, err = client.lib().NewSettings()
+	t.ErrIs(err, ErrBus)
+	_, err = client.lib().NewWifiDevice("/")
+	t.ErrIs(err, ErrBus)
+}
+
+func (s *ABus) Connection_fails_on_unreachable_address(t *T) {
+	_, err := mckBusClient("unix:path=/nonexistent/bus",
+		map[string]string{}).connectBus()
+	t.ErrIs(err, ErrBus)
+}
+
+func (s *ABus) Sends_scan_requests_to_the_selected_address(t *T) {
+	err := mckBusClient("unix:path=/nonexistent/bus",
+		map[string]string{}).requestScan("/", []string{"lab"})
+	t.ErrIs(err, ErrBus)
+	t.Contains(err.Error(), "/nonexistent/bus")
+}
+
+func TestABus(t *testing.T) {
+	t.Parallel()
+	Run(&ABus{}, t)
+}
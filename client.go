@@ -0,0 +1,349 @@
+/*
+Package wifi provides a client of NetworkManager's D-Bus API to scan
+for, connect to and disconnect from wifi access points and to manage the
+connection profiles of wifi access points, e.g.:
+
+	client := &wifi.Client{Password: func(SSID string) (string, error) {
+		return "secret", nil
+	}}
+	adapter, err := client.Adapter(ctx, "")
+	if err != nil {
+		return err
+	}
+	return adapter.Connect(ctx, "home")
+
+All errors are wrapped sentinel errors of this package which may be
+evaluated by errors.Is; a failed activation is reported as StateError.
+*/
+package wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+// DefaultTimeout is the default time an adapter operation waits for an
+// expected signal to occur.
+const DefaultTimeout = 10 * time.Second
+
+// Client owns the connection to NetworkManager and provides its wifi
+// adapters (see [Client.Adapter]) and its wifi connection profiles
+// (see [Client.Profiles]).  The zero value is ready to use and reaches
+// NetworkManager on the system bus.
+type Client struct {
+
+	// Lib provides library functions which may fail, e.g.
+	// nm.NewNetworkManager.
+	Lib ClientLib
+
+	// BusAddress is the address of the D-Bus bus NetworkManager is
+	// reached on; it defaults to the system bus.  NOTE the
+	// NetworkManager objects of all clients of a process share one
+	// system bus connection which is opened with the bus address of the
+	// first client using it; the operations of a client with a
+	// different bus address fail with ErrBus.
+	BusAddress string
+
+	// Timeout determines how long an adapter operation waits for an
+	// expected signal to occur; it defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// Password provides the password for the access point with given
+	// SSID if a connect needs one.  Connects needing a password fail
+	// with ErrNoPassword if Password is not set.
+	Password func(SSID string) (string, error)
+
+	// libInit indicates if Lib-property has been set to its defaults
+	// where not mocked.  NOTE the Lib-property is set only once to its
+	// default.
+	libInit bool
+
+	// _nm create only one network-manager instance per Client
+	_nm nm.NetworkManager
+
+	// hub dispatches the signals of the client's bus connection to the
+	// operations of its adapters.
+	hub signalHub
+
+	// bus defaults to the system bus shared by all clients
+	bus *systemBus
+}
+
+// lib set the defaults for library functions.
+func (c *Client) lib() ClientLib {
+	if !c.libInit {
+		c.libInit = true
+		if c.Lib.Setenv == nil {
+			c.Lib.Setenv = os.Setenv
+		}
+		if c.Lib.NewNM == nil {
+			c.Lib.NewNM = c.newNM
+		}
+		if c.Lib.NewWifiDevice == nil {
+			c.Lib.NewWifiDevice = c.newWifiDevice
+		}
+		if c.Lib.NewSettings == nil {
+			c.Lib.NewSettings = c.newSettings
+		}
+		if c.Lib.NewWifiAdapter == nil {
+			c.Lib.NewWifiAdapter = c.newWifiAdapter
+		}
+		if c.Lib.SystemBus == nil {
+			c.Lib.SystemBus = func() (BusConnection, error) {
+				return c.connectBus()
+			}
+		}
+		if c.Lib.RegisterSecretAgent == nil {
+			c.Lib.RegisterSecretAgent = c.registerSecretAgent
+		}
+		if c.Lib.RequestScan == nil {
+			c.Lib.RequestScan = c.requestScan
+		}
+	}
+	return c.Lib
+}
+
+// Close closes the bus connection given client c's adapters share to
+// receive signals.  A closed client reconnects on demand.
+func (c *Client) Close() error {
+	return c.hub.close()
+}
+
+var ErrNoPassword = errors.New("client: no password source")
+
+// password provides the password for given SSID from given client c's
+// Password function.
+func (c *Client) password(SSID string) (string, error) {
+	if c.Password == nil {
+		return "", fmt.Errorf("%w: '%s'", ErrNoPassword, SSID)
+	}
+	return c.Password(SSID)
+}
+
+var ErrClientAdapter = errors.New("client: determine adapter")
+
+// Adapter returns the wifi adapter with given name or the first
+// activated or disconnected wifi adapter if name is the zero string.
+// Adapter fails if the adapter isn't found, isn't a wifi device or is
+// neither activated nor disconnected.
+func (c *Client) Adapter(ctx context.Context, name string) (
+	*WifiAdapter, error,
+) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrClientAdapter, err)
+	}
+	var adapter *WifiAdapter
+	var err error
+	if name == "" {
+		adapter, err = c.defaultDevice()
+	} else {
+		adapter, err = c.namedDevice(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrClientAdapter, err)
+	}
+	return adapter, nil
+}
+
+// Adapters returns all wifi adapters known to NetworkManager regardless
+// of their state.
+func (c *Client) Adapters(ctx context.Context) ([]*WifiAdapter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrClientAdapter, err)
+	}
+	nm_, err := c.nm()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrClientAdapter, err)
+	}
+	dd, err := nm_.GetAllDevices()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: %w",
+			ErrClientAdapter, ErrNMAllDevices, err)
+	}
+	aa := []*WifiAdapter{}
+	for _, d := range dd {
+		type_, err := d.GetPropertyDeviceType()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w: %w",
+				ErrClientAdapter, ErrDeviceType, err)
+		}
+		if type_ != nm.NmDeviceTypeWifi {
+			continue
+		}
+		wd, err := c.lib().NewWifiDevice(d.GetPath())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w: %w",
+				ErrClientAdapter, ErrNewWifiDevice, err)
+		}
+		name, err := wd.GetPropertyInterface()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w: %w",
+				ErrClientAdapter, ErrDeviceName, err)
+		}
+		aa = append(aa, c.lib().NewWifiAdapter(wd, name))
+	}
+	return aa, nil
+}
+
+func (c *Client) namedDevice(name string) (*WifiAdapter, error) {
+	nm_, err := c.nm()
+	if err != nil {
+		return nil, err
+	}
+	dd, err := nm_.GetAllDevices()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNMAllDevices, err)
+	}
+	for _, d := range dd {
+		name_, err := d.GetPropertyInterface()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDeviceName, err)
+		}
+		if name != name_ {
+			continue
+		}
+		type_, err := d.GetPropertyDeviceType()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDeviceType, err)
+		}
+		if type_ != nm.NmDeviceTypeWifi {
+			return nil, fmt.Errorf("%w: '%s' is %w",
+				ErrWifiDevice, name, ErrNoWifi)
+		}
+		wd, err := c.lib().NewWifiDevice(d.GetPath())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNewWifiDevice, err)
+		}
+		state, err := wd.GetPropertyState()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrWifiDeviceState, err)
+		}
+		if state != nm.NmDeviceStateActivated &&
+			state != nm.NmDeviceStateDisconnected {
+			return nil, fmt.Errorf("%w: '%s' %w",
+				ErrWifiDevice, name, ErrNotActivated)
+		}
+		return c.lib().NewWifiAdapter(wd, name), nil
+	}
+	return nil, fmt.Errorf("%w: '%s' %w",
+		ErrWifiDevice, name, ErrDeviceNotFound)
+}
+
+var ErrNoWifi = errors.New("no wifi device")
+var ErrDeviceNotFound = errors.New("device not found")
+var ErrNotActivated = errors.New("not activated")
+
+func (c *Client) defaultDevice() (*WifiAdapter, error) {
+	nm_, err := c.nm()
+	if err != nil {
+		return nil, err
+	}
+	dd, err := nm_.GetAllDevices()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNMAllDevices, err)
+	}
+	for _, d := range dd {
+		type_, err := d.GetPropertyDeviceType()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDeviceType, err)
+		}
+		if type_ != nm.NmDeviceTypeWifi {
+			continue
+		}
+		wd, err := c.lib().NewWifiDevice(d.GetPath())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNewWifiDevice, err)
+		}
+		state, err := wd.GetPropertyState()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrWifiDeviceState, err)
+		}
+		if state != nm.NmDeviceStateActivated &&
+			state != nm.NmDeviceStateDisconnected {
+			continue
+		}
+		name, err := wd.GetPropertyInterface()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDeviceName, err)
+		}
+		return c.lib().NewWifiAdapter(wd, name), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrWifiDevice,
+		"no active wifi adapter")
+}
+
+func (c *Client) newWifiAdapter(
+	d nm.DeviceWireless, n string,
+) *WifiAdapter {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return &WifiAdapter{
+		Timeout: timeout,
+		name:    n,
+		dev:     d,
+		client:  c,
+	}
+}
+
+var ErrDeviceName = errors.New("client: device: name")
+var ErrWifiDevice = errors.New("client: device")
+var ErrWifiDeviceState = errors.New("client: device: state")
+var ErrNewWifiDevice = errors.New("client: new device")
+var ErrDeviceType = errors.New("client: device type")
+var ErrNMAllDevices = errors.New("client: all devices")
+var ErrNewNM = errors.New("client: new network manager")
+
+func (c *Client) nm() (nm.NetworkManager, error) {
+	if c._nm == nil {
+		nm_, err := c.lib().NewNM()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNewNM, err)
+		}
+		c._nm = nm_
+	}
+	return c._nm, nil
+}
+
+// ClientLib provides library functions which should be mockable to
+// simplify testing.
+type ClientLib struct {
+
+	// Setenv defaults to os.Setenv
+	Setenv func(key, value string) error
+
+	// NewNM defaults to gonetworkmanager.NewNetworkManager on the bus
+	// selected by Client.BusAddress
+	NewNM func() (nm.NetworkManager, error)
+
+	// NewWifiDevice defaults to gonetworkmanager.NewDeviceWireless on
+	// the bus selected by Client.BusAddress
+	NewWifiDevice func(dbus.ObjectPath) (nm.DeviceWireless, error)
+
+	// NewSettings defaults to gonetworkmanager.NewSettings on the bus
+	// selected by Client.BusAddress
+	NewSettings func() (nm.Settings, error)
+
+	// NewWifiAdapter defaults to Client.newWifiAdapter
+	NewWifiAdapter func(nm.DeviceWireless, string) *WifiAdapter
+
+	// SystemBus defaults to a new connection to the bus selected by
+	// Client.BusAddress; it is called by the client's signal hub which
+	// keeps the connection until the client is closed
+	SystemBus func() (BusConnection, error)
+
+	// RegisterSecretAgent defaults to Client.registerSecretAgent
+	RegisterSecretAgent func(*SecretAgent) (
+		unregister func() error, err error)
+
+	// RequestScan defaults to Client.requestScan requesting a scan of
+	// the wifi device with given path which probes given SSIDs
+	RequestScan func(path dbus.ObjectPath, SSIDs []string) error
+}
@@ -0,0 +1,207 @@
+package wifi
+
+import (
+	"context"
+	"errors"
+
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+	"github.com/slukits/gounit"
+)
+
+/*
+NOTE this file doesn't contain any tests but mockups for Client tests.
+The _test.go suffix was added to ensure this code doesn't go into
+production and doesn't need to be covered by go test -cover.
+*/
+
+// mckFakeNM mocks given client c's network manager related library
+// functions to operate on given fake network manager.
+func mckFakeNM(c *Client, fake *nmfake.NM) *Client {
+	c.Lib.NewNM = func() (nm.NetworkManager, error) { return fake, nil }
+	c.Lib.NewWifiDevice = fake.NewWifiDevice
+	c.Lib.NewSettings = fake.NewSettings
+	c.Lib.SystemBus = func() (BusConnection, error) {
+		return fake.SystemBus()
+	}
+	c.Lib.RequestScan = fake.RequestScan
+	c.Lib.RegisterSecretAgent = func(
+		agent *SecretAgent,
+	) (func() error, error) {
+		return fake.RegisterSecretAgent(agent)
+	}
+	return c
+}
+
+// fakeClient returns a client operating on the default fake network
+// manager.
+func fakeClient() *Client {
+	return mckFakeNM(&Client{}, nmfake.Default())
+}
+
+// fakeAdapter returns the adapter with given name of a client operating
+// on the default fake network manager.
+func fakeAdapter(t *gounit.T, name string) *WifiAdapter {
+	adapter, err := fakeClient().Adapter(context.Background(), name)
+	t.FatalOn(err)
+	return adapter
+}
+
+var ErrMckNewNM = errors.New("new network manager error mock")
+
+func mckNewNMErr(c *Client) *Client {
+	c.Lib.NewNM = func() (nm.NetworkManager, error) {
+		return nil, ErrMckNewNM
+	}
+	return c
+}
+
+type NMMock struct {
+	nm.NetworkManager
+	allDevices func() ([]nm.Device, error)
+}
+
+// mockedNM mocks given client c's network manager with a NMMock
+// wrapping the default fake network manager which is returned.
+func mockedNM(c *Client) *NMMock {
+	fake := nmfake.Default()
+	mckFakeNM(c, fake)
+	mck := &NMMock{NetworkManager: fake}
+	c.Lib.NewNM = func() (nm.NetworkManager, error) { return mck, nil }
+	return mck
+}
+
+func (m *NMMock) GetAllDevices() ([]nm.Device, error) {
+	if m.allDevices != nil {
+		return m.allDevices()
+	}
+	return m.NetworkManager.GetAllDevices()
+}
+
+var ErrMckNMAllDevices = errors.New("nm: all devices: error mock")
+
+func mckNMAllDevicesErr(c *Client) *Client {
+	mockedNM(c).allDevices = func() ([]nm.Device, error) {
+		return nil, ErrMckNMAllDevices
+	}
+	return c
+}
+
+func mckNMDeviceType(c *Client, factory func(nm.Device) nm.Device) *Client {
+	nm_ := mockedNM(c)
+	nm_.allDevices = func() ([]nm.Device, error) {
+		mck := []nm.Device{}
+		dd, err := nm_.NetworkManager.GetAllDevices()
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range dd {
+			mck = append(mck, factory(d))
+		}
+		return mck, nil
+	}
+	return c
+}
+
+type MckDeviceTypeErr struct{ nm.Device }
+
+var ErrMckDeviceType = errors.New("device type error mock")
+
+func (m *MckDeviceTypeErr) GetPropertyDeviceType() (
+	nm.NmDeviceType, error,
+) {
+	return nm.NmDeviceTypeDummy, ErrMckDeviceType
+}
+
+type MckDeviceTypeNotWifi struct{ nm.Device }
+
+func (m *MckDeviceTypeNotWifi) GetPropertyDeviceType() (
+	nm.NmDeviceType, error,
+) {
+	return nm.NmDeviceTypeDummy, nil
+}
+
+type MckDeviceNameErr struct{ nm.Device }
+
+func mckNMDeviceNameErr(c *Client) *Client {
+	return mckNMDeviceType(c, func(d nm.Device) nm.Device {
+		return &MckDeviceNameErr{Device: d}
+	})
+}
+
+var ErrMckDeviceName = errors.New("device name error mock")
+
+func (m *MckDeviceNameErr) GetPropertyInterface() (string, error) {
+	return "", ErrMckDeviceName
+}
+
+var ErrMckNewWifiDevice = errors.New("new wifi device error mock")
+
+func mckNMNewWifiDeviceErr(c *Client) *Client {
+	mockedNM(c)
+	c.Lib.NewWifiDevice =
+		func(op dbus.ObjectPath) (nm.DeviceWireless, error) {
+			return nil, ErrMckNewWifiDevice
+		}
+	return c
+}
+
+// mckNMWifiDevice mocks given client c's network manager with
+// the default fake network manager whose wifi devices are wrapped by
+// given factory.
+func mckNMWifiDevice(
+	c *Client, factory func(nm.DeviceWireless) nm.DeviceWireless,
+) *Client {
+	fake := mockedNM(c).NetworkManager.(*nmfake.NM)
+	c.Lib.NewWifiDevice =
+		func(op dbus.ObjectPath) (nm.DeviceWireless, error) {
+			wd, err := fake.NewWifiDevice(op)
+			if err != nil {
+				return nil, err
+			}
+			return factory(wd), nil
+		}
+	return c
+}
+
+type WifiDeviceStateErrMck struct{ nm.DeviceWireless }
+
+func mckNMWifiDeviceStateErr(c *Client) *Client {
+	return mckNMWifiDevice(c, func(
+		wd nm.DeviceWireless) nm.DeviceWireless {
+		return &WifiDeviceStateErrMck{DeviceWireless: wd}
+	})
+}
+
+var ErrMckWifiDeviceState = errors.New("wifi device state err mock")
+
+func (m *WifiDeviceStateErrMck) GetPropertyState() (
+	nm.NmDeviceState, error,
+) {
+	return nm.NmDeviceStateUnknown, ErrMckWifiDeviceState
+}
+
+// mckNMInactiveWifiDevice mocks given client c's network manager
+// with the default fake network manager whose wifi device is
+// unavailable.
+func mckNMInactiveWifiDevice(c *Client) *Client {
+	mockedNM(c).NetworkManager.(*nmfake.NM).Device("wlan0").State =
+		nm.NmDeviceStateUnavailable
+	return c
+}
+
+type WifiDeviceNameErrMck struct{ nm.DeviceWireless }
+
+func mckNMWifiDeviceNameErr(c *Client) *Client {
+	return mckNMWifiDevice(c, func(
+		wd nm.DeviceWireless) nm.DeviceWireless {
+		return &WifiDeviceNameErrMck{DeviceWireless: wd}
+	})
+}
+
+var ErrMckWifiDeviceName = errors.New("wifi device name err mock")
+
+func (m *WifiDeviceNameErrMck) GetPropertyInterface() (string, error) {
+	return "", ErrMckWifiDeviceName
+}
@@ -0,0 +1,276 @@
+package wifi
+
+import (
+	"context"
+	"testing"
+
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	. "github.com/slukits/gounit"
+)
+
+type AClient struct{ Suite }
+
+func (s *AClient) SetUp(t *T) { t.Parallel() }
+
+var bg = context.Background()
+
+func (s *AClient) Default_adapter_fails_if_NM_cant_be_obtained(t *T) {
+	_, err := mckNewNMErr(&Client{}).Adapter(bg, "")
+	t.ErrIs(err, ErrClientAdapter)
+	t.ErrIs(err, ErrNewNM)
+	t.ErrIs(err, ErrMckNewNM)
+}
+
+func (s *AClient) Default_adapter_fails_if_devices_cant_be_obtained(
+	t *T,
+) {
+	_, err := mckNMAllDevicesErr(&Client{}).Adapter(bg, "")
+	t.ErrIs(err, ErrNMAllDevices)
+	t.ErrIs(err, ErrMckNMAllDevices)
+}
+
+func (s *AClient) Default_adapter_fails_if_device_type_unobtainable(
+	t *T,
+) {
+	factory := func(d nm.Device) nm.Device {
+		return &MckDeviceTypeErr{Device: d}
+	}
+	_, err := mckNMDeviceType(&Client{}, factory).Adapter(bg, "")
+	t.ErrIs(err, ErrDeviceType)
+	t.ErrIs(err, ErrMckDeviceType)
+}
+
+func (s *AClient) Default_adapter_fails_if_wifi_device_creation_fails(
+	t *T,
+) {
+	_, err := mckNMNewWifiDeviceErr(&Client{}).Adapter(bg, "")
+	t.ErrIs(err, ErrNewWifiDevice)
+	t.ErrIs(err, ErrMckNewWifiDevice)
+}
+
+func (s *AClient) Default_adapter_fails_if_device_state_fails(t *T) {
+	_, err := mckNMWifiDeviceStateErr(&Client{}).Adapter(bg, "")
+	t.ErrIs(err, ErrWifiDeviceState)
+	t.ErrIs(err, ErrMckWifiDeviceState)
+}
+
+func (s *AClient) Default_adapter_fails_if_device_name_fails(t *T) {
+	_, err := mckNMWifiDeviceNameErr(&Client{}).Adapter(bg, "")
+	t.ErrIs(err, ErrDeviceName)
+	t.ErrIs(err, ErrMckWifiDeviceName)
+}
+
+func (s *AClient) Default_adapter_fails_if_no_active_device_found(t *T) {
+	_, err := mckNMInactiveWifiDevice(&Client{}).Adapter(bg, "")
+	t.ErrIs(err, ErrWifiDevice)
+}
+
+func (s *AClient) Provides_an_active_wifi_adapter_by_default(t *T) {
+	t.True(fakeAdapter(t, "").IsActivated())
+}
+
+func (s *AClient) Provides_named_adapter(t *T) {
+	t.Eq("wlan0", fakeAdapter(t, "wlan0").Name())
+}
+
+func (s *AClient) Fails_on_canceled_context(t *T) {
+	ctx, cancel := context.WithCancel(bg)
+	cancel()
+	_, err := fakeClient().Adapter(ctx, "")
+	t.ErrIs(err, ErrClientAdapter)
+	t.ErrIs(err, context.Canceled)
+}
+
+func (s *AClient) Named_adapter_fails_if_NM_unobtainable(t *T) {
+	_, err := mckNewNMErr(&Client{}).Adapter(bg, "wlan0")
+	t.ErrIs(err, ErrNewNM)
+	t.ErrIs(err, ErrMckNewNM)
+}
+
+func (s *AClient) Named_adapter_fails_if_devices_unobtainable(t *T) {
+	_, err := mckNMAllDevicesErr(&Client{}).Adapter(bg, "wlan0")
+	t.ErrIs(err, ErrNMAllDevices)
+	t.ErrIs(err, ErrMckNMAllDevices)
+}
+
+func (s *AClient) Named_adapter_fails_if_device_name_retrieval_fails(
+	t *T,
+) {
+	_, err := mckNMDeviceNameErr(&Client{}).Adapter(bg, "wlan0")
+	t.ErrIs(err, ErrDeviceName)
+	t.ErrIs(err, ErrMckDeviceName)
+}
+
+func (s *AClient) Named_adapter_fails_if_device_type_retrieval_fails(
+	t *T,
+) {
+	factory := func(d nm.Device) nm.Device {
+		return &MckDeviceTypeErr{Device: d}
+	}
+	_, err := mckNMDeviceType(&Client{}, factory).Adapter(bg, "wlan0")
+	t.ErrIs(err, ErrDeviceType)
+	t.ErrIs(err, ErrMckDeviceType)
+}
+
+func (s *AClient) Named_adapter_fails_if_its_type_is_not_wifi(t *T) {
+	factory := func(d nm.Device) nm.Device {
+		return &MckDeviceTypeNotWifi{Device: d}
+	}
+	_, err := mckNMDeviceType(&Client{}, factory).Adapter(bg, "wlan0")
+	t.ErrIs(err, ErrWifiDevice)
+	t.ErrIs(err, ErrNoWifi)
+}
+
+func (s *AClient) Named_adapter_fails_if_wifi_device_creation_fails(
+	t *T,
+) {
+	_, err := mckNMNewWifiDeviceErr(&Client{}).Adapter(bg, "wlan0")
+	t.ErrIs(err, ErrNewWifiDevice)
+	t.ErrIs(err, ErrMckNewWifiDevice)
+}
+
+func (s *AClient) Named_adapter_fails_if_state_retrieval_fails(t *T) {
+	_, err := mckNMWifiDeviceStateErr(&Client{}).Adapter(bg, "wlan0")
+	t.ErrIs(err, ErrWifiDeviceState)
+	t.ErrIs(err, ErrMckWifiDeviceState)
+}
+
+func (s *AClient) Named_adapter_fails_if_state_not_activated(t *T) {
+	_, err := mckNMInactiveWifiDevice(&Client{}).Adapter(bg, "wlan0")
+	t.ErrIs(err, ErrWifiDevice)
+	t.ErrIs(err, ErrNotActivated)
+}
+
+func (s *AClient) Named_adapter_fails_if_device_unknown(t *T) {
+	_, err := fakeClient().Adapter(bg, "unknown")
+	t.ErrIs(err, ErrWifiDevice)
+	t.ErrIs(err, ErrDeviceNotFound)
+}
+
+func (s *AClient) Lists_wifi_adapters_regardless_of_their_state(t *T) {
+	client := mckNMInactiveWifiDevice(&Client{})
+	aa, err := client.Adapters(bg)
+	t.FatalOn(err)
+	t.FatalIfNot(t.Eq(1, len(aa)))
+	t.Eq("wlan0", aa[0].Name())
+}
+
+func (s *AClient) Lists_wifi_profiles(t *T) {
+	pp, err := fakeClient().Profiles(bg)
+	t.FatalOn(err)
+	t.FatalIfNot(t.Eq(1, len(pp)))
+	t.Eq(Profile{ID: "home", UUID: "fake-home", SSID: "home",
+		Security: SecurityWPAPSK, Autoconnect: true}, pp[0])
+}
+
+func (s *AClient) Describes_how_profiles_are_used(t *T) {
+	fake := nmfake.New()
+	ss := nmfake.WifiSettings("lab", "sae", "lab-secret")
+	ss["connection"]["autoconnect"] = false
+	ss["connection"]["autoconnect-priority"] = int32(5)
+	ss["connection"]["timestamp"] = uint64(1700000000)
+	ss["connection"]["interface-name"] = "wlan1"
+	fake.AddProfile(ss)
+	fake.AddProfile(nmfake.WifiSettings("cafe", "", ""))
+	pp, err := mckFakeNM(&Client{}, fake).Profiles(bg)
+	t.FatalOn(err)
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	t.Eq(Profile{ID: "lab", UUID: "fake-lab", SSID: "lab",
+		Security: SecuritySAE, Priority: 5, LastUsed: 1700000000,
+		Interface: "wlan1"}, pp[0])
+	t.Eq(SecurityOpen, pp[1].Security)
+	t.True(pp[1].Autoconnect)
+}
+
+func (s *AClient) Shows_profile_settings_with_masked_secrets(t *T) {
+	client := fakeClient()
+	ss, err := client.ProfileSettings(bg,
+		ProfileSelector{SSID: "home"}, false)
+	t.FatalOn(err)
+	t.Eq("wpa-psk", ss[wirelessSecurity]["key-mgmt"])
+	t.Not.True(ss[wirelessSecurity]["psk"] == "home-secret")
+	ss, err = client.ProfileSettings(bg,
+		ProfileSelector{UUID: "fake-home"}, true)
+	t.FatalOn(err)
+	t.Eq("home-secret", ss[wirelessSecurity]["psk"])
+	_, err = client.ProfileSettings(bg,
+		ProfileSelector{SSID: "unknown"}, false)
+	t.ErrIs(err, ErrProfile)
+	t.ErrIs(err, ErrNoProfile)
+}
+
+func (s *AClient) Deletes_profile_of_given_SSID(t *T) {
+	client := fakeClient()
+	t.FatalOn(client.DeleteProfile(bg, ProfileSelector{SSID: "home"}))
+	pp, err := client.Profiles(bg)
+	t.FatalOn(err)
+	t.Eq(0, len(pp))
+	err = client.DeleteProfile(bg, ProfileSelector{SSID: "home"})
+	t.ErrIs(err, ErrProfileDelete)
+	t.ErrIs(err, ErrNoProfile)
+}
+
+// officeAtHome returns a client of the default fake network manager
+// with a second profile "home-office" for the SSID "home".
+func officeAtHome() *Client {
+	fake := nmfake.Default()
+	ss := nmfake.WifiSettings("home", "wpa-psk", "home-secret")
+	ss["connection"]["id"] = "home-office"
+	ss["connection"]["uuid"] = "fake-home-office"
+	fake.AddProfile(ss)
+	return mckFakeNM(&Client{}, fake)
+}
+
+func (s *AClient) Detects_ambiguous_profiles(t *T) {
+	client := officeAtHome()
+	err := client.DeleteProfile(bg, ProfileSelector{SSID: "home"})
+	t.ErrIs(err, ErrAmbiguousProfile)
+	t.Contains(err.Error(), "'home-office' (fake-home-office)")
+	_, err = client.ProfileSettings(bg,
+		ProfileSelector{SSID: "home"}, false)
+	t.ErrIs(err, ErrAmbiguousProfile)
+	adapter, err := client.Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	err = adapter.Connect(bg, "home")
+	t.ErrIs(err, ErrAdapterConnect)
+	t.ErrIs(err, ErrAmbiguousProfile)
+}
+
+func (s *AClient) Selects_profiles_by_UUID_or_id(t *T) {
+	client := officeAtHome()
+	t.FatalOn(client.DeleteProfile(bg, ProfileSelector{
+		SSID: "home", ID: "home-office"}))
+	pp, err := client.Profiles(bg)
+	t.FatalOn(err)
+	t.FatalIfNot(t.Eq(1, len(pp)))
+	t.Eq("fake-home", pp[0].UUID)
+	ss, err := client.ProfileSettings(bg,
+		ProfileSelector{UUID: "fake-home"}, false)
+	t.FatalOn(err)
+	t.Eq("home", ss["connection"]["id"])
+}
+
+func (s *AClient) Deletes_all_profiles_of_an_SSID(t *T) {
+	client := officeAtHome()
+	deleted, err := client.DeleteProfiles(bg,
+		ProfileSelector{SSID: "home"})
+	t.FatalOn(err)
+	t.Eq(2, len(deleted))
+	pp, err := client.Profiles(bg)
+	t.FatalOn(err)
+	t.Eq(0, len(pp))
+	_, err = client.DeleteProfiles(bg, ProfileSelector{SSID: "home"})
+	t.ErrIs(err, ErrNoProfile)
+}
+
+func (s *AClient) Fails_connect_needing_password_without_source(t *T) {
+	err := fakeAdapter(t, "").Connect(bg, "office")
+	t.ErrIs(err, ErrAdapterConnect)
+	t.ErrIs(err, ErrNoPassword)
+}
+
+func TestAClient(t *testing.T) {
+	t.Parallel()
+	Run(&AClient{}, t)
+}
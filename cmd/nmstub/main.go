@@ -0,0 +1,48 @@
+/*
+nmstub serves the default world of the nmstub package as NetworkManager
+on the bus with given address, e.g.:
+
+	$ dbus-daemon --session --print-address --nofork &
+	unix:path=/tmp/dbus-XXXX,guid=...
+	$ nmstub unix:path=/tmp/dbus-XXXX,guid=... &
+	$ DBUS_SYSTEM_BUS_ADDRESS=unix:path=/tmp/dbus-XXXX,guid=... wifi scan
+
+The address defaults to the DBUS_SYSTEM_BUS_ADDRESS environment
+variable.  nmstub serves until it is interrupted.
+*/
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"example.com/wifi/nmstub"
+	"github.com/godbus/dbus/v5"
+)
+
+func main() {
+	address := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS")
+	if len(os.Args) > 1 {
+		address = os.Args[1]
+	}
+	if address == "" {
+		log.Fatal("nmstub: missing bus address")
+	}
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		log.Fatal(fmt.Errorf("nmstub: %w", err))
+	}
+	defer conn.Close()
+	stub, err := nmstub.Default(conn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stub.Close()
+	fmt.Printf("nmstub: serving NetworkManager on %s\n", address)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+}
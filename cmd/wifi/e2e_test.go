@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/wifi"
+	"example.com/wifi/nmstub"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+	. "github.com/slukits/gounit"
+)
+
+// startBus starts a private dbus-daemon whose address is returned.  The
+// daemon is stopped at the end of given test t which is skipped if no
+// dbus-daemon is available.
+func startBus(t *testing.T) string {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("end-to-end test skipped: no dbus-daemon available")
+	}
+	cmd := exec.Command("dbus-daemon", "--session", "--print-address",
+		"--nofork")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("dbus-daemon: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	address, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon: address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// e2eRan guards the end-to-end test against running twice since
+// gonetworkmanager shares one system bus connection per process which
+// can't be pointed to a new bus; a second run's clients fail with
+// wifi.ErrBus.
+var e2eRan = &sync.Once{}
+
+// e2eRequest runs handleRequest with given arguments aa against the
+// bus with given address and returns its printed output and the message
+// of a fatal error.  The WIFI_PASSWORD is given password pwd.
+func e2eRequest(
+	t *testing.T, address, pwd string, aa ...string,
+) (out, fatal string) {
+	env := mckArgs(&Env{}, append(aa, "--bus-address="+address)...)
+	env.Lib.NewClient = func() *wifi.Client {
+		client := env.newClient()
+		client.Lib.Setenv = func(key, value string) error {
+			t.Setenv(key, value)
+			return nil
+		}
+		return client
+	}
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		out += fmt.Sprintln(vv...)
+		return 0, nil
+	}
+	env.Lib.Fatal = func(vv ...interface{}) {
+		fatal = fmt.Sprint(vv...)
+		panic("e2e fatal")
+	}
+	env.Lib.Exit = func(int) { panic("e2e fatal") }
+	env.Lib.OsEnv = func(key string) string {
+		if key == ENV_PASSWORD {
+			return pwd
+		}
+		return ""
+	}
+	defer func() {
+		if r := recover(); r != nil && r != "e2e fatal" {
+			panic(r)
+		}
+	}()
+	handleRequest(context.Background(), env)
+	return out, fatal
+}
+
+// e2eSettles reports if given device d reaches given state within a
+// second since a failed activation returns before the device settles.
+func e2eSettles(d *nmstub.Device, state nm.NmDeviceState) bool {
+	for i := 0; i < 100; i++ {
+		if d.CurrentState() == state {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestEndToEnd(t_ *testing.T) {
+	ran := true
+	e2eRan.Do(func() { ran = false })
+	if ran {
+		t_.Skip("end-to-end test runs only once per test process")
+	}
+	address := startBus(t_)
+	t := NewT(t_)
+	conn, err := dbus.Connect(address)
+	t.FatalOn(err)
+	defer conn.Close()
+	stub, err := nmstub.Default(conn)
+	t.FatalOn(err)
+	defer stub.Close()
+	wlan := stub.Device("wlan0")
+
+	out, fatal := e2eRequest(t_, address, "", "scan", "--output=json")
+	t.FatalIfNot(t.Eq("", fatal))
+	scan := scanReport{}
+	t.FatalOn(json.Unmarshal([]byte(out), &scan))
+	t.FatalIfNot(t.Eq(3, len(scan.AccessPoints)))
+	t.Eq("home", scan.AccessPoints[0].SSID)
+	t.Eq("WPA2", scan.AccessPoints[0].Security)
+	t.Eq(uint32(5180), scan.AccessPoints[1].Frequency)
+
+	out, fatal = e2eRequest(t_, address, "", "adapters")
+	t.FatalIfNot(t.Eq("", fatal))
+	t.Contains(out, "* wlan0, MAC: ")
+	t.Contains(out, "SSID: 'home'")
+
+	out, _ = e2eRequest(t_, address, "", "active")
+	t.Contains(out, "'home'")
+
+	_, fatal = e2eRequest(t_, address, "", "disconnect")
+	t.FatalIfNot(t.Eq("", fatal))
+	t.Eq(nm.NmDeviceStateDisconnected, wlan.CurrentState())
+
+	_, fatal = e2eRequest(t_, address, "", "connect", "home")
+	t.FatalIfNot(t.Eq("", fatal))
+	t.Eq("home", wlan.ActiveAP().SSID)
+
+	_, fatal = e2eRequest(t_, address, "wrong", "connect", "office")
+	t.Contains(fatal, noSecretsMessage)
+	t.True(e2eSettles(wlan, nm.NmDeviceStateDisconnected))
+	t.Eq(1, len(stub.Profiles())) // the failed profile was rolled back
+
+	_, fatal = e2eRequest(t_, address, "office-secret", "connect", "office")
+	t.FatalIfNot(t.Eq("", fatal))
+	t.Eq("office", wlan.ActiveAP().SSID)
+	t.FatalIfNot(t.Eq(2, len(stub.Profiles())))
+	t.Not.True(stub.Profiles()[1].Unsaved())
+
+	_, fatal = e2eRequest(t_, address, "", "delete", "office")
+	t.FatalIfNot(t.Eq("", fatal))
+	t.Eq(1, len(stub.Profiles()))
+}
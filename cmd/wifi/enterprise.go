@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"example.com/wifi"
+)
+
+// EAP_OPTION is the name of the commandline option selecting the EAP
+// method of a new WPA2/WPA3-Enterprise configuration.
+const EAP_OPTION = "eap"
+
+// EAP_FILE_OPTION is the name of the commandline option providing a
+// json file with the 802.1X settings of a new configuration.
+const EAP_FILE_OPTION = "eap-file"
+
+// names of the commandline options providing single 802.1X settings
+const (
+	IDENTITY_OPTION            = "identity"
+	ANONYMOUS_IDENTITY_OPTION  = "anonymous-identity"
+	CA_CERT_OPTION             = "ca-cert"
+	CLIENT_CERT_OPTION         = "client-cert"
+	PRIVATE_KEY_OPTION         = "private-key"
+	DOMAIN_SUFFIX_MATCH_OPTION = "domain-suffix-match"
+)
+
+var ErrEnterprise = errors.New("env: 802.1X settings")
+
+// Enterprise returns the 802.1X settings of given environment e or nil
+// if none are given.  The settings are read from the json file set by
+// the EAP_FILE_OPTION and superseded by the single setting options.
+// Relative paths of the file are relative to the file's directory;
+// the other paths are relative to the working directory.  The password
+// of an EAP-TLS private key which the file doesn't set is taken from
+// the non-interactive password sources (see Env.GivenPassword).
+// Enterprise fails if the settings are invalid, the password can't be
+// obtained or a certificate or key file can't be read.
+func (e *Env) Enterprise() (*wifi.Enterprise, error) {
+	ent, given := &wifi.Enterprise{}, false
+	if path, ok := e.Option(EAP_FILE_OPTION); ok {
+		if err := e.readEnterprise(path, ent); err != nil {
+			return nil, fmt.Errorf("%w: file: %w", ErrEnterprise, err)
+		}
+		given = true
+	}
+	if method, ok := e.Option(EAP_OPTION); ok {
+		ent.Method, given = wifi.EAPMethod(method), true
+	}
+	for name, value := range map[string]*string{
+		IDENTITY_OPTION:            &ent.Identity,
+		ANONYMOUS_IDENTITY_OPTION:  &ent.AnonymousIdentity,
+		CA_CERT_OPTION:             &ent.CACert,
+		CLIENT_CERT_OPTION:         &ent.ClientCert,
+		PRIVATE_KEY_OPTION:         &ent.PrivateKey,
+		DOMAIN_SUFFIX_MATCH_OPTION: &ent.DomainSuffixMatch,
+	} {
+		if v, ok := e.Option(name); ok {
+			*value, given = v, true
+		}
+	}
+	if !given {
+		return nil, nil
+	}
+	if ent.Method == wifi.EAPTLS && ent.PrivateKeyPassword == "" {
+		pwd, _, err := e.GivenPassword()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEnterprise, err)
+		}
+		ent.PrivateKeyPassword = pwd
+	}
+	for _, path := range []*string{
+		&ent.CACert, &ent.ClientCert, &ent.PrivateKey,
+	} {
+		if *path == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEnterprise, err)
+		}
+		if _, err := e.lib().ReadFile(abs); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEnterprise, err)
+		}
+		*path = abs
+	}
+	if err := ent.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEnterprise, err)
+	}
+	return ent, nil
+}
+
+// readEnterprise decodes the json file at given path into given
+// enterprise settings ent resolving relative paths against the file's
+// directory.  Unknown properties are rejected.
+func (e *Env) readEnterprise(path string, ent *wifi.Enterprise) error {
+	bb, err := e.lib().ReadFile(path)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(bb))
+	d.DisallowUnknownFields()
+	if err := d.Decode(ent); err != nil {
+		return fmt.Errorf("'%s': %w", path, err)
+	}
+	for _, p := range []*string{
+		&ent.CACert, &ent.ClientCert, &ent.PrivateKey,
+	} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(filepath.Dir(path), *p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"example.com/wifi"
+	. "github.com/slukits/gounit"
+)
+
+type AnEnterprise struct{ Suite }
+
+func (s *AnEnterprise) SetUp(t *T) { t.Parallel() }
+
+// mckFilesEnv returns an environment with given arguments aa whose
+// readable files are given files by their path.
+func mckFilesEnv(files map[string]string, aa ...string) *Env {
+	env := mckArgs(&Env{}, aa...)
+	env.Lib.ReadFile = func(path string) ([]byte, error) {
+		content, ok := files[path]
+		if !ok {
+			return nil, errors.New("read file error mock")
+		}
+		return []byte(content), nil
+	}
+	return env
+}
+
+func (s *AnEnterprise) Is_nil_without_802_1x_options(t *T) {
+	ent, err := mckFilesEnv(nil, "connect", "corp").Enterprise()
+	t.FatalOn(err)
+	t.True(ent == nil)
+}
+
+func (s *AnEnterprise) Is_given_by_options(t *T) {
+	ent, err := mckFilesEnv(map[string]string{"/ca.pem": "ca"},
+		"connect", "corp", "--eap=peap", "--identity=me",
+		"--anonymous-identity=anonymous", "--ca-cert=/ca.pem",
+		"--domain-suffix-match=corp.example").Enterprise()
+	t.FatalOn(err)
+	t.Eq(wifi.Enterprise{Method: wifi.EAPPEAP, Identity: "me",
+		AnonymousIdentity: "anonymous", CACert: "/ca.pem",
+		DomainSuffixMatch: "corp.example"}, *ent)
+}
+
+func (s *AnEnterprise) Is_read_from_a_file_superseded_by_options(t *T) {
+	ent, err := mckFilesEnv(map[string]string{
+		"/etc/corp/eap.json": `{"method":"tls","identity":"me",` +
+			`"client_cert":"me.pem","private_key":"/keys/me.key",` +
+			`"private_key_password":"file-secret"}`,
+		"/etc/corp/me.pem": "cert", "/keys/me.key": "key",
+	}, "connect", "corp", "--eap-file=/etc/corp/eap.json",
+		"--identity=you").Enterprise()
+	t.FatalOn(err)
+	t.Eq(wifi.Enterprise{Method: wifi.EAPTLS, Identity: "you",
+		ClientCert: "/etc/corp/me.pem", PrivateKey: "/keys/me.key",
+		PrivateKeyPassword: "file-secret"}, *ent)
+}
+
+func (s *AnEnterprise) Takes_the_key_password_from_password_sources(t *T) {
+	env := mckFilesEnv(map[string]string{
+		"/me.pem": "cert", "/me.key": "key", "/key-pwd": "key-secret\n",
+	}, "connect", "corp", "--eap=tls", "--identity=me",
+		"--client-cert=/me.pem", "--private-key=/me.key",
+		"--password-file=/key-pwd")
+	ent, err := env.Enterprise()
+	t.FatalOn(err)
+	t.Eq("key-secret", ent.PrivateKeyPassword)
+	ent, err = mckFakePassword(mckFilesEnv(map[string]string{
+		"/me.pem": "cert", "/me.key": "key",
+	}, "connect", "corp", "--eap=tls", "--identity=me",
+		"--client-cert=/me.pem", "--private-key=/me.key"),
+		"env-secret").Enterprise()
+	t.FatalOn(err)
+	t.Eq("env-secret", ent.PrivateKeyPassword)
+	_, err = mckFilesEnv(map[string]string{
+		"/me.pem": "cert", "/me.key": "key",
+	}, "connect", "corp", "--eap=tls", "--identity=me",
+		"--client-cert=/me.pem", "--private-key=/me.key",
+		"--password-file=/missing").Enterprise()
+	t.ErrIs(err, ErrEnterprise)
+	t.ErrIs(err, ErrPassword)
+}
+
+func (s *AnEnterprise) Fails_on_unknown_file_properties(t *T) {
+	_, err := mckFilesEnv(map[string]string{
+		"eap.json": `{"method":"peap","identty":"me"}`,
+	}, "connect", "corp", "--eap-file=eap.json").Enterprise()
+	t.ErrIs(err, ErrEnterprise)
+	t.Contains(err.Error(), "identty")
+}
+
+func (s *AnEnterprise) Fails_on_unreadable_certificate(t *T) {
+	_, err := mckFilesEnv(nil, "connect", "corp", "--eap=ttls",
+		"--identity=me", "--ca-cert=/missing.pem").Enterprise()
+	t.ErrIs(err, ErrEnterprise)
+}
+
+func (s *AnEnterprise) Fails_on_invalid_settings(t *T) {
+	_, err := mckFilesEnv(nil, "connect", "corp",
+		"--eap=tls", "--identity=me").Enterprise()
+	t.ErrIs(err, ErrEnterprise)
+	t.ErrIs(err, wifi.ErrEnterprise)
+}
+
+func TestAnEnterprise(t *testing.T) {
+	t.Parallel()
+	Run(&AnEnterprise{}, t)
+}
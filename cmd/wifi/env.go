@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"example.com/wifi"
+)
+
+// Env main purpose is to provide a mockable system environment and a
+// wifi device (see [Env.Device]) from the wifi client according to an
+// optional given commandline argument or an optional set environment
+// variable.
+type Env struct {
+
+	// Lib provides library functions which may fail or exit execution,
+	// e.g. fmt.Println or log.Fatal.
+	Lib EnvLib
+
+	// libInit indicates if Lib-property has been set to its defaults
+	// where not mocked.  NOTE the Lib-property is set only once to its
+	// default.
+	libInit bool
+
+	// client is the only wifi client of an Env
+	client *wifi.Client
+
+	// stdinPassword remembers a password read from a piped stdin.
+	stdinPassword *string
+}
+
+// lib set the defaults for library functions and system environment
+// evaluation.
+func (e *Env) lib() EnvLib {
+	if !e.libInit {
+		e.libInit = true
+		if e.Lib.Println == nil {
+			e.Lib.Println = fmt.Println
+		}
+		if e.Lib.Fatal == nil {
+			e.Lib.Fatal = log.Fatal
+		}
+		if e.Lib.Exit == nil {
+			e.Lib.Exit = os.Exit
+		}
+		if e.Lib.Args == nil {
+			e.Lib.Args = e.args
+		}
+		if e.Lib.OsEnv == nil {
+			e.Lib.OsEnv = os.Getenv
+		}
+		if e.Lib.NewClient == nil {
+			e.Lib.NewClient = e.newClient
+		}
+		if e.Lib.ReadFile == nil {
+			e.Lib.ReadFile = os.ReadFile
+		}
+		if e.Lib.WriteFile == nil {
+			e.Lib.WriteFile = os.WriteFile
+		}
+		if e.Lib.Command == nil {
+			e.Lib.Command = e.command
+		}
+		if e.Lib.IsTerminal == nil {
+			e.Lib.IsTerminal = isTerminal
+		}
+		if e.Lib.ReadPassword == nil {
+			e.Lib.ReadPassword = readPassword
+		}
+		if e.Lib.Stdin == nil {
+			e.Lib.Stdin = os.Stdin
+		}
+		if e.Lib.Stderr == nil {
+			e.Lib.Stderr = os.Stderr
+		}
+	}
+	return e.Lib
+}
+
+func (e *Env) args() []string { return os.Args }
+
+// Println prints given values vv to given environment e's standard
+// library printer.
+func (e *Env) Println(vv ...interface{}) {
+	if _, err := e.lib().Println(vv...); err != nil {
+		e.lib().Fatal(err)
+	}
+}
+
+// Fatal closes given environment e's client and passes given values vv
+// to e's standard library fatal-er.  NOTE the client is closed first
+// since ending execution skips deferred calls.
+func (e *Env) Fatal(vv ...interface{}) {
+	e.Close()
+	e.lib().Fatal(vv...)
+	panic("env: expected execution to end")
+}
+
+// Sub returns potentially given sub-command, that is the first
+// argument which is not an option, if one exists otherwise the
+// zero-string.
+func (e *Env) Sub() SubCommand {
+	args := e.positionals()
+	if len(args) < 1 {
+		return ZeroSub
+	}
+	return SubCommand(args[0])
+}
+
+// OPTION_PREFIX is the prefix of commandline options.
+const OPTION_PREFIX = "--"
+
+// Option returns the value of given environment e's commandline option
+// with given name and true if it is set; otherwise the zero string and
+// false is returned.  An option is given either as --name, --name=value
+// or --name='value'.
+func (e *Env) Option(name string) (string, bool) {
+	for _, arg := range e.lib().Args()[1:] {
+		if !strings.HasPrefix(arg, OPTION_PREFIX+name) {
+			continue
+		}
+		value := strings.TrimPrefix(arg, OPTION_PREFIX+name)
+		if value == "" {
+			return "", true
+		}
+		if !strings.HasPrefix(value, "=") {
+			continue
+		}
+		value = strings.TrimPrefix(value, "=")
+		if len(value) > 1 && strings.HasPrefix(value, "'") &&
+			strings.HasSuffix(value, "'") {
+			value = value[1 : len(value)-1]
+		}
+		return value, true
+	}
+	return "", false
+}
+
+// positionals returns the commandline arguments following the program
+// name which are not options.
+func (e *Env) positionals() []string {
+	pp := []string{}
+	for _, arg := range e.lib().Args()[1:] {
+		if strings.HasPrefix(arg, OPTION_PREFIX) {
+			continue
+		}
+		pp = append(pp, arg)
+	}
+	return pp
+}
+
+// ADAPTER_PREFIX is the prefix of the adapter commandline argument
+const ADAPTER_PREFIX = "--wifi-adapter='"
+
+// ADAPTER_OPTION is the name of the adapter commandline option
+const ADAPTER_OPTION = "wifi-adapter"
+
+// ALL_ADAPTERS_OPTION is the name of the commandline option letting
+// scan use all wifi adapters.
+const ALL_ADAPTERS_OPTION = "all-adapters"
+
+// UUID_OPTION is the name of the commandline option selecting a wifi
+// profile by its UUID.
+const UUID_OPTION = "uuid"
+
+// PROFILE_ID_OPTION is the name of the commandline option selecting a
+// wifi profile by its id.
+const PROFILE_ID_OPTION = "profile-id"
+
+// ALL_OPTION is the name of the commandline option letting delete
+// remove all selected wifi profiles.
+const ALL_OPTION = "all"
+
+// SHOW_SECRETS_OPTION is the name of the commandline option letting
+// show report a profile's secrets.
+const SHOW_SECRETS_OPTION = "show-secrets"
+
+// HIDDEN_OPTION is the name of the commandline option letting connect
+// probe for a hidden access point and scan probe given hidden SSIDs.
+const HIDDEN_OPTION = "hidden"
+
+// ENV_ADAPTER is the name of the adapter environment variable
+const ENV_ADAPTER = "WIFI_ADAPTER"
+
+// BUS_ADDRESS_OPTION is the name of the commandline option providing
+// the address of the D-Bus bus NetworkManager is reached on.
+const BUS_ADDRESS_OPTION = "bus-address"
+
+// ENV_BUS_ADDRESS is the name of the bus address environment variable
+const ENV_BUS_ADDRESS = "WIFI_DBUS_ADDRESS"
+
+// BusAddress returns the address of the bus all D-Bus connections go
+// to.  A set BUS_ADDRESS_OPTION supersedes the ENV_BUS_ADDRESS
+// environment variable.  The zero string denotes the system bus.
+func (e *Env) BusAddress() string {
+	if address, ok := e.Option(BUS_ADDRESS_OPTION); ok && address != "" {
+		return address
+	}
+	return e.lib().OsEnv(ENV_BUS_ADDRESS)
+}
+
+// Client returns given environment e's wifi client which reaches
+// NetworkManager on the bus selected by Env.BusAddress and queries
+// passwords by Env.Password.  All sub-commands of e share the client's
+// bus connection receiving NetworkManager's signals.
+func (e *Env) Client() *wifi.Client {
+	if e.client == nil {
+		e.client = e.lib().NewClient()
+	}
+	return e.client
+}
+
+// Close closes given environment e's client if it was created.
+func (e *Env) Close() error {
+	if e.client == nil {
+		return nil
+	}
+	return e.client.Close()
+}
+
+func (e *Env) newClient() *wifi.Client {
+	return &wifi.Client{BusAddress: e.BusAddress(), Password: e.Password}
+}
+
+var ErrEnvDevice = errors.New("env: determine device")
+
+// Device evaluates the program arguments and environment variables to
+// determine a wifi-adapter of the environment's client and returns it;
+// Device fails if no active or disconnected wifi-adapter is found.
+// Device evaluates all possible options in the following order:
+//   - if the ADAPTER_OPTION is given Env tries to use this adapter and
+//     fails if something goes wrong
+//   - is no commandline argument given Env checks for the ENV_ADAPTER os
+//     environment variable and tries to use set value failing if given
+//     name is not an active wifi device
+//   - is also no environment variable given WifiAdapter defaults to the
+//     first active or disconnected wifi-adapter which can be obtained
+//     from the NetworkManager
+func (e *Env) Device(ctx context.Context) (*wifi.WifiAdapter, error) {
+	name, ok := e.Option(ADAPTER_OPTION)
+	if !ok || name == "" {
+		name = e.lib().OsEnv(ENV_ADAPTER)
+	}
+	adapter, err := e.Client().Adapter(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEnvDevice, err)
+	}
+	return adapter, nil
+}
+
+// SSID returns given environment e's SSID commandline argument which is
+// the second argument which is not an option if set or the zero string
+// otherwise.
+func (e *Env) SSID() string {
+	args := e.positionals()
+	if len(args) < 2 {
+		return ""
+	}
+	return args[1]
+}
+
+// ProfileSelector returns the selector of the wifi profiles given
+// environment e's SSID argument, UUID_OPTION and PROFILE_ID_OPTION
+// select.
+func (e *Env) ProfileSelector() wifi.ProfileSelector {
+	uuid, _ := e.Option(UUID_OPTION)
+	id, _ := e.Option(PROFILE_ID_OPTION)
+	return wifi.ProfileSelector{SSID: e.SSID(), UUID: uuid, ID: id}
+}
+
+// HiddenSSIDs returns the comma separated SSIDs of given environment
+// e's HIDDEN_OPTION.
+func (e *Env) HiddenSSIDs() []string {
+	return e.ListOption(HIDDEN_OPTION)
+}
+
+// ListOption returns the comma separated values of given environment
+// e's commandline option with given name without empty values.
+func (e *Env) ListOption(name string) []string {
+	value, _ := e.Option(name)
+	ss := []string{}
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// EnvLib provides standard library functions and system-properties which
+// should be mockable to simplify testing.
+type EnvLib struct {
+
+	// Println defaults to fmt.Println
+	Println func(vv ...interface{}) (int, error)
+
+	// Fatal defaults to log.Fatal
+	Fatal func(vv ...interface{})
+
+	// Exit defaults to os.Exit
+	Exit func(int)
+
+	// Args defaults to func() []string { return os.Args }
+	Args func() []string
+
+	// OsEnv defaults to os.Getenv
+	OsEnv func(string) string
+
+	// NewClient defaults to Env.newClient
+	NewClient func() *wifi.Client
+
+	// ReadFile defaults to os.ReadFile
+	ReadFile func(string) ([]byte, error)
+
+	// WriteFile defaults to os.WriteFile
+	WriteFile func(string, []byte, os.FileMode) error
+
+	// Command defaults to Env.command running given command by sh -c
+	Command func(string) ([]byte, error)
+
+	// IsTerminal defaults to term.IsTerminal of os.Stdin
+	IsTerminal func() bool
+
+	// ReadPassword defaults to term.ReadPassword of os.Stdin
+	ReadPassword func() ([]byte, error)
+
+	// Stdin defaults to os.Stdin
+	Stdin io.Reader
+
+	// Stderr defaults to os.Stderr
+	Stderr io.Writer
+}
+
+type SubCommand string
+
+const (
+	ZeroSub       SubCommand = ""
+	ScanSub       SubCommand = "scan"
+	ConnectSub    SubCommand = "connect"
+	DisconnectSub SubCommand = "disconnect"
+	ActiveSub     SubCommand = "active"
+	DeleteSub     SubCommand = "delete"
+	AdaptersSub   SubCommand = "adapters"
+	WatchSub      SubCommand = "watch"
+	ProfilesSub   SubCommand = "profiles"
+	ShowSub       SubCommand = "show"
+	HotspotSub    SubCommand = "hotspot"
+	ShareSub      SubCommand = "share"
+	ExportSub     SubCommand = "export"
+	ImportSub     SubCommand = "import"
+)
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"example.com/wifi"
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+	"github.com/slukits/gounit"
+)
+
+/*
+NOTE this file doesn't contain any tests but mockups for Env tests.  The
+_test.go suffix was added to ensure this code doesn't go into
+production and doesn't need to be covered by go test -cover.
+*/
+
+const MCK_PRINT_ERR = "mocked printing error"
+
+// mckPrintErr mocks given environment env's Lib.Println function to
+// return and error.
+func mckPrintErr(env *Env) *Env {
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		return 0, errors.New(MCK_PRINT_ERR)
+	}
+	return env
+}
+
+// mckPrint mocks given environment env's Lib.Println function and
+// records Println calls into given string-pointers.  mckPrint fails
+// given test-instance t iff more Println calls then available string
+// pointers.
+func mckPrint(t *gounit.T, env *Env, pp ...*string) *Env {
+	calls := 0
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		if len(pp) == calls {
+			t.Fatal("mock print: more Println-calls then print-pointers")
+			return 0, nil
+		}
+		*(pp[calls]) = fmt.Sprintln(vv...)
+		calls++
+		return 0, nil
+	}
+	return env
+}
+
+// mckFatal mocks given environment env's Lib.Fatal function recording
+// the fatal-message to given string-pointer print and panics with given
+// panic message pnc.
+func mckFatal(t *gounit.T, env *Env, pnc string, prnt *string) *Env {
+	env.Lib.Fatal = func(vv ...interface{}) {
+		*prnt = fmt.Sprint(vv...)
+		panic(pnc)
+	}
+	return env
+}
+
+// mckExit mocks given environment env's Lib.Exit function recording
+// the exit code to given int-pointer code and panics with given panic
+// message pnc.
+func mckExit(env *Env, pnc string, code *int) *Env {
+	env.Lib.Exit = func(c int) {
+		*code = c
+		panic(pnc)
+	}
+	return env
+}
+
+// mckArgs mocks up the os.Args retrieval by keeping only the first
+// argument of os.Args and replacing the remaining args with given
+// arguments aa.  Note if no args given the testing arguments are
+// removed.
+func mckArgs(env *Env, aa ...string) *Env {
+	env.Lib.Args = func() []string {
+		return append([]string{os.Args[0]}, aa...)
+	}
+	return env
+}
+
+func mckEnvVar(env *Env, name string) *Env {
+	env.Lib.OsEnv = func(key string) string {
+		if key == ENV_ADAPTER {
+			return name
+		}
+		return os.Getenv(key)
+	}
+	return env
+}
+
+var ErrMckNewNM = errors.New("new network manager error mock")
+
+// mckNewNMErr mocks given environment env's client to fail creating
+// its network manager.
+func mckNewNMErr(env *Env) *Env {
+	env.Lib.NewClient = func() *wifi.Client {
+		client := env.newClient()
+		client.Lib.NewNM = func() (nm.NetworkManager, error) {
+			return nil, ErrMckNewNM
+		}
+		return client
+	}
+	return env
+}
+
+// mckFakeNM mocks given environment env's client to operate on given
+// fake network manager.
+func mckFakeNM(env *Env, fake *nmfake.NM) *Env {
+	env.Lib.NewClient = func() *wifi.Client {
+		return fakeClient(env.newClient(), fake)
+	}
+	return env
+}
+
+// fakeClient wires given client's network manager related library
+// functions to given fake network manager.
+func fakeClient(client *wifi.Client, fake *nmfake.NM) *wifi.Client {
+	client.Lib.NewNM = func() (nm.NetworkManager, error) {
+		return fake, nil
+	}
+	client.Lib.NewWifiDevice = fake.NewWifiDevice
+	client.Lib.NewSettings = fake.NewSettings
+	client.Lib.SystemBus = func() (wifi.BusConnection, error) {
+		return fake.SystemBus()
+	}
+	client.Lib.RequestScan = fake.RequestScan
+	client.Lib.RegisterSecretAgent = func(
+		agent *wifi.SecretAgent,
+	) (func() error, error) {
+		return fake.RegisterSecretAgent(agent)
+	}
+	return client
+}
+
+// fakeEnv returns an environment with given arguments aa operating on
+// the default fake network manager.
+func fakeEnv(aa ...string) *Env {
+	return mckFakeNM(mckArgs(&Env{}, aa...), nmfake.Default())
+}
+
+// mckNMWifiDevice mocks given environment env's client with the default
+// fake network manager whose wifi devices are wrapped by given factory.
+func mckNMWifiDevice(
+	env *Env, factory func(nm.DeviceWireless) nm.DeviceWireless,
+) *Env {
+	fake := nmfake.Default()
+	env.Lib.NewClient = func() *wifi.Client {
+		client := fakeClient(env.newClient(), fake)
+		client.Lib.NewWifiDevice = func(
+			op dbus.ObjectPath,
+		) (nm.DeviceWireless, error) {
+			wd, err := fake.NewWifiDevice(op)
+			if err != nil {
+				return nil, err
+			}
+			return factory(wd), nil
+		}
+		return client
+	}
+	return env
+}
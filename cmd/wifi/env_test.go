@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"example.com/wifi"
+	. "github.com/slukits/gounit"
+)
+
+type AnEnv struct{ Suite }
+
+func (s *AnEnv) SetUp(t *T) { t.Parallel() }
+
+var bg = context.Background()
+
+func (s *AnEnv) Panics_if_lib_s_fatal_call_doesnt_end_execution(t *T) {
+	env := &Env{}
+	env.Lib.Fatal = func(vv ...interface{}) {}
+	t.Panics(func() { env.Fatal("") })
+}
+
+func (s *AnEnv) Dies_if_lib_s_print_line_fails(t *T) {
+	died, exitMock := false, "execution end mock"
+	env := &Env{}
+	env.Lib.Fatal = func(vv ...interface{}) {
+		died = true
+		t.Eq(vv[0].(error).Error(), MCK_PRINT_ERR)
+		panic(exitMock)
+	}
+	mckPrintErr(env)
+	defer func() {
+		t.Eq(recover().(string), exitMock)
+		t.True(died)
+	}()
+	env.Println("failing print")
+}
+
+func (s *AnEnv) Returns_the_zero_sub_command_on_no_cmd_args(t *T) {
+	t.Eq(mckArgs(&Env{}).Sub(), ZeroSub)
+}
+
+func (s *AnEnv) Device_fails_if_NM_cant_be_obtained(t *T) {
+	_, err := mckNewNMErr(mckArgs(&Env{})).Device(bg)
+	t.ErrIs(err, ErrEnvDevice)
+	t.ErrIs(err, wifi.ErrNewNM)
+	t.ErrIs(err, ErrMckNewNM)
+}
+
+func (s *AnEnv) Provides_an_active_wifi_device_by_default(t *T) {
+	wd, err := fakeEnv().Device(bg)
+	t.FatalOn(err)
+	t.True(wd.IsActivated())
+}
+
+func (s *AnEnv) Provides_named_device_from_command_line_argument(t *T) {
+	arg := fmt.Sprintf("%s%s'", ADAPTER_PREFIX, "wlan0")
+	wd, err := fakeEnv(arg).Device(bg)
+	t.FatalOn(err)
+	t.Eq("wlan0", wd.Name())
+	_, err = mckEnvVar(fakeEnv(arg), "unknown").Device(bg)
+	t.FatalOn(err)
+}
+
+func (s *AnEnv) Provides_named_device_from_env_variable(t *T) {
+	wd, err := mckEnvVar(fakeEnv(), "wlan0").Device(bg)
+	t.FatalOn(err)
+	t.Eq("wlan0", wd.Name())
+	_, err = mckEnvVar(fakeEnv(), "unknown").Device(bg)
+	t.ErrIs(err, wifi.ErrDeviceNotFound)
+}
+
+// mckBusEnv returns an environment with given arguments aa whose
+// WIFI_DBUS_ADDRESS is given address.
+func mckBusEnv(address string, aa ...string) *Env {
+	env := mckArgs(&Env{}, aa...)
+	env.Lib.OsEnv = func(key string) string {
+		if key == ENV_BUS_ADDRESS {
+			return address
+		}
+		return ""
+	}
+	return env
+}
+
+func (s *AnEnv) Bus_address_option_supersedes_environment_variable(
+	t *T,
+) {
+	t.Eq("", mckBusEnv("", "scan").Client().BusAddress)
+	t.Eq("unix:path=/env", mckBusEnv("unix:path=/env",
+		"scan").Client().BusAddress)
+	t.Eq("unix:path=/opt", mckBusEnv("unix:path=/env", "scan",
+		"--bus-address=unix:path=/opt").Client().BusAddress)
+}
+
+func (s *AnEnv) Provides_second_cmd_line_arg_as_SSID(t *T) {
+	env := mckArgs(&Env{}, "first", "second")
+	t.Eq("second", env.SSID())
+}
+
+func (s *AnEnv) Has_zero_SSID_on_less_than_two_cmd_line_args(t *T) {
+	env := mckArgs(&Env{}, "first")
+	t.Eq("", env.SSID())
+}
+
+func (s *AnEnv) Provides_sub_command_and_SSID_ignoring_options(t *T) {
+	env := mckArgs(&Env{}, "--output=json", "first", "--x", "second")
+	t.Eq(SubCommand("first"), env.Sub())
+	t.Eq("second", env.SSID())
+}
+
+func (s *AnEnv) Provides_set_option_values(t *T) {
+	env := mckArgs(&Env{}, "scan", "--flag", "--plain=value",
+		"--quoted='quoted value'")
+	value, ok := env.Option("flag")
+	t.True(ok)
+	t.Eq("", value)
+	value, ok = env.Option("plain")
+	t.True(ok)
+	t.Eq("value", value)
+	value, ok = env.Option("quoted")
+	t.True(ok)
+	t.Eq("quoted value", value)
+}
+
+func (s *AnEnv) Has_no_option_which_is_only_a_prefix_of_given(t *T) {
+	env := mckArgs(&Env{}, "scan", "--flagged=value")
+	_, ok := env.Option("flag")
+	t.Not.True(ok)
+	_, ok = mckArgs(&Env{}, "scan").Option("flag")
+	t.Not.True(ok)
+}
+
+func (s *AnEnv) Defaults_to_text_output(t *T) {
+	t.Eq(TextOutput, mckArgs(&Env{}, "scan").Output())
+	t.Eq(JSONOutput, mckArgs(&Env{}, "scan", "--output=json").Output())
+}
+
+func TestAnEnv(t *testing.T) {
+	t.Parallel()
+	Run(&AnEnv{}, t)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"example.com/wifi"
+)
+
+// names of the commandline options of the export and import
+// sub-commands
+const (
+	WITH_SECRETS_OPTION = "with-secrets"
+	DIR_OPTION          = "dir"
+	OVERWRITE_OPTION    = "overwrite"
+	RENAME_OPTION       = "rename"
+	FORMAT_OPTION       = "format"
+)
+
+// formats of the files the import sub-command reads
+const (
+	KeyfileFormat    = "keyfile"
+	SupplicantFormat = "wpa_supplicant"
+)
+
+var ErrImportOptions = errors.New("env: import options")
+
+// ImportFiles returns the files given environment e's import
+// sub-command reads, i.e. all arguments which are not options following
+// the sub-command.
+func (e *Env) ImportFiles() []string {
+	args := e.positionals()
+	if len(args) < 2 {
+		return nil
+	}
+	return args[1:]
+}
+
+// ImportFormat returns the format of the files given environment e's
+// import sub-command reads as set by the FORMAT_OPTION defaulting to
+// KeyfileFormat.
+func (e *Env) ImportFormat() (string, error) {
+	format, ok := e.Option(FORMAT_OPTION)
+	switch {
+	case !ok:
+		return KeyfileFormat, nil
+	case format == KeyfileFormat || format == SupplicantFormat:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w: unknown format '%s'",
+		ErrImportOptions, format)
+}
+
+// ImportOptions returns the options resolving conflicts of the profiles
+// imported by given environment e: the OVERWRITE_OPTION lets them
+// replace existing profiles and the RENAME_OPTION gives a single
+// imported keyfile a new id and UUID.
+func (e *Env) ImportOptions() (wifi.ImportOptions, error) {
+	opts := wifi.ImportOptions{}
+	_, opts.Overwrite = e.Option(OVERWRITE_OPTION)
+	rename, ok := e.Option(RENAME_OPTION)
+	if !ok {
+		return opts, nil
+	}
+	switch {
+	case rename == "":
+		return opts, fmt.Errorf("%w: missing id to rename to",
+			ErrImportOptions)
+	case opts.Overwrite:
+		return opts, fmt.Errorf("%w: --%s excludes --%s",
+			ErrImportOptions, RENAME_OPTION, OVERWRITE_OPTION)
+	case len(e.ImportFiles()) > 1:
+		return opts, fmt.Errorf("%w: --%s needs a single file",
+			ErrImportOptions, RENAME_OPTION)
+	case e.isSupplicantImport():
+		return opts, fmt.Errorf("%w: --%s needs a keyfile",
+			ErrImportOptions, RENAME_OPTION)
+	}
+	opts.Rename = rename
+	return opts, nil
+}
+
+func (e *Env) isSupplicantImport() bool {
+	format, _ := e.Option(FORMAT_OPTION)
+	return format == SupplicantFormat
+}
+
+// keyfileNames returns the file names of given keyfiles kk.  A
+// keyfile is named after its profile's id unless several profiles
+// share the id in which case the UUID is appended.
+func keyfileNames(kk []wifi.Keyfile) []string {
+	ids := map[string]int{}
+	for _, kf := range kk {
+		ids[kf.Profile.ID]++
+	}
+	nn := []string{}
+	for _, kf := range kk {
+		name := strings.ReplaceAll(kf.Profile.ID,
+			string(filepath.Separator), "_")
+		if name == "" || ids[kf.Profile.ID] > 1 {
+			name = strings.TrimPrefix(name+"-"+kf.Profile.UUID, "-")
+		}
+		nn = append(nn, name+wifi.KeyfileExt)
+	}
+	return nn
+}
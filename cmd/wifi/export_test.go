@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"example.com/wifi"
+	. "github.com/slukits/gounit"
+)
+
+type SomeImportOptions struct{ Suite }
+
+func (s *SomeImportOptions) SetUp(t *T) { t.Parallel() }
+
+func (s *SomeImportOptions) Are_given_by_options(t *T) {
+	opts, err := mckArgs(&Env{}, "import", "a.nmconnection",
+		"--overwrite").ImportOptions()
+	t.FatalOn(err)
+	t.Eq(wifi.ImportOptions{Overwrite: true}, opts)
+	opts, err = mckArgs(&Env{}, "import", "a.nmconnection",
+		"--rename=home-2").ImportOptions()
+	t.FatalOn(err)
+	t.Eq(wifi.ImportOptions{Rename: "home-2"}, opts)
+}
+
+func (s *SomeImportOptions) Fail_if_conflicting(t *T) {
+	for _, args := range [][]string{
+		{"import", "a.nmconnection", "--rename"},
+		{"import", "a.nmconnection", "--rename=b", "--overwrite"},
+		{"import", "a.nmconnection", "b.nmconnection", "--rename=c"},
+	} {
+		_, err := mckArgs(&Env{}, args...).ImportOptions()
+		t.ErrIs(err, ErrImportOptions)
+	}
+}
+
+func (s *SomeImportOptions) Set_the_format_of_imported_files(t *T) {
+	format, err := mckArgs(&Env{}, "import", "a").ImportFormat()
+	t.FatalOn(err)
+	t.Eq(KeyfileFormat, format)
+	format, err = mckArgs(&Env{}, "import", "a",
+		"--format=wpa_supplicant").ImportFormat()
+	t.FatalOn(err)
+	t.Eq(SupplicantFormat, format)
+	_, err = mckArgs(&Env{}, "import", "a",
+		"--format=ini").ImportFormat()
+	t.ErrIs(err, ErrImportOptions)
+	_, err = mckArgs(&Env{}, "import", "a", "--format=wpa_supplicant",
+		"--rename=b").ImportOptions()
+	t.ErrIs(err, ErrImportOptions)
+}
+
+func (s *SomeImportOptions) Name_keyfiles_after_their_ids(t *T) {
+	t.Eq([]string{"home.nmconnection", "lab-u1.nmconnection",
+		"lab-u2.nmconnection", "a_b.nmconnection"},
+		keyfileNames([]wifi.Keyfile{
+			{Profile: wifi.Profile{ID: "home", UUID: "u0"}},
+			{Profile: wifi.Profile{ID: "lab", UUID: "u1"}},
+			{Profile: wifi.Profile{ID: "lab", UUID: "u2"}},
+			{Profile: wifi.Profile{ID: "a/b", UUID: "u3"}},
+		}))
+}
+
+func TestSomeImportOptions(t *testing.T) {
+	t.Parallel()
+	Run(&SomeImportOptions{}, t)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"example.com/wifi"
+)
+
+// actions of the hotspot sub-command
+const (
+	HotspotStart  = "start"
+	HotspotStop   = "stop"
+	HotspotStatus = "status"
+)
+
+// names of the commandline options configuring a hotspot
+const (
+	BAND_OPTION    = "band"
+	CHANNEL_OPTION = "channel"
+	WPA3_OPTION    = "wpa3"
+)
+
+var ErrHotspotConfig = errors.New("env: hotspot settings")
+
+// HotspotAction returns given environment e's hotspot action which is
+// the second argument which is not an option if set or the zero string
+// otherwise.
+func (e *Env) HotspotAction() string { return e.SSID() }
+
+// HotspotConfig returns the hotspot configuration of given environment
+// e.  Its SSID is the third argument which is not an option, its band
+// and channel are set by the BAND_OPTION and CHANNEL_OPTION and the
+// WPA3_OPTION secures it by SAE instead of WPA-PSK.  Its password is
+// only taken from the sources which aren't interactive (see
+// Env.GivenPassword); without one the hotspot gets a random password.
+// NOTE the configuration is validated by wifi.WifiAdapter.StartHotspot.
+func (e *Env) HotspotConfig() (wifi.HotspotConfig, error) {
+	c := wifi.HotspotConfig{Security: wifi.SecurityWPAPSK}
+	if args := e.positionals(); len(args) > 2 {
+		c.SSID = args[2]
+	}
+	c.Band, _ = e.Option(BAND_OPTION)
+	if v, ok := e.Option(CHANNEL_OPTION); ok {
+		channel, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return c, fmt.Errorf("%w: channel: %w", ErrHotspotConfig, err)
+		}
+		c.Channel = uint32(channel)
+	}
+	if _, ok := e.Option(WPA3_OPTION); ok {
+		c.Security = wifi.SecuritySAE
+	}
+	pwd, _, err := e.GivenPassword()
+	if err != nil {
+		return c, fmt.Errorf("%w: %w", ErrHotspotConfig, err)
+	}
+	c.Password = pwd
+	return c, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"example.com/wifi"
+	. "github.com/slukits/gounit"
+)
+
+type AHotspotConfig struct{ Suite }
+
+func (s *AHotspotConfig) SetUp(t *T) { t.Parallel() }
+
+func (s *AHotspotConfig) Defaults_to_wpa2_with_a_random_password(t *T) {
+	c, err := mckFakePassword(mckArgs(&Env{}, "hotspot", "start"), "").
+		HotspotConfig()
+	t.FatalOn(err)
+	t.Eq(wifi.HotspotConfig{Security: wifi.SecurityWPAPSK}, c)
+}
+
+func (s *AHotspotConfig) Is_given_by_arguments_and_options(t *T) {
+	c, err := mckFakePassword(mckArgs(&Env{}, "hotspot", "start", "spot",
+		"--band=a", "--channel=36", "--wpa3"), "spot-secret").
+		HotspotConfig()
+	t.FatalOn(err)
+	t.Eq(wifi.HotspotConfig{SSID: "spot", Password: "spot-secret",
+		Security: wifi.SecuritySAE, Band: "a", Channel: 36}, c)
+}
+
+func (s *AHotspotConfig) Fails_on_an_invalid_channel(t *T) {
+	_, err := mckArgs(&Env{}, "hotspot", "start", "--band=bg",
+		"--channel=six").HotspotConfig()
+	t.ErrIs(err, ErrHotspotConfig)
+}
+
+func TestAHotspotConfig(t *testing.T) {
+	t.Parallel()
+	Run(&AHotspotConfig{}, t)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"example.com/wifi"
+)
+
+// IP_FILE_OPTION is the name of the commandline option providing a json
+// file with the ip, DNS and proxy settings of a new configuration.
+const IP_FILE_OPTION = "ip-file"
+
+// names of the commandline options providing single ip, DNS and proxy
+// settings
+const (
+	IP4_OPTION             = "ip4"
+	GATEWAY4_OPTION        = "gateway4"
+	IP6_OPTION             = "ip6"
+	GATEWAY6_OPTION        = "gateway6"
+	DNS_OPTION             = "dns"
+	DNS_SEARCH_OPTION      = "dns-search"
+	IGNORE_AUTO_DNS_OPTION = "ignore-auto-dns"
+	ROUTE_METRIC_OPTION    = "route-metric"
+	PROXY_OPTION           = "proxy"
+	PAC_URL_OPTION         = "pac-url"
+)
+
+var ErrIPConfig = errors.New("env: ip settings")
+
+// IPConfig returns the ip, DNS and proxy settings of given environment
+// e or nil if none are given.  The settings are read from the json file
+// set by the IP_FILE_OPTION and superseded by the single setting
+// options.  Name servers of the DNS_OPTION are assigned to their
+// family while the DNS_SEARCH_OPTION, the IGNORE_AUTO_DNS_OPTION and
+// the ROUTE_METRIC_OPTION apply to both families.  A PAC_URL_OPTION
+// implies the auto proxy method.  IPConfig fails if the settings are
+// invalid.
+func (e *Env) IPConfig() (*wifi.IPConfig, error) {
+	c, given := &wifi.IPConfig{}, false
+	if path, ok := e.Option(IP_FILE_OPTION); ok {
+		if err := e.readIPConfig(path, c); err != nil {
+			return nil, fmt.Errorf("%w: file: %w", ErrIPConfig, err)
+		}
+		given = true
+	}
+	for name, value := range map[string]*[]string{
+		IP4_OPTION: &c.IPv4.Addresses,
+		IP6_OPTION: &c.IPv6.Addresses,
+	} {
+		if _, ok := e.Option(name); ok {
+			*value, given = e.ListOption(name), true
+		}
+	}
+	for name, value := range map[string]*string{
+		GATEWAY4_OPTION: &c.IPv4.Gateway,
+		GATEWAY6_OPTION: &c.IPv6.Gateway,
+		PAC_URL_OPTION:  &c.Proxy.PACURL,
+	} {
+		if v, ok := e.Option(name); ok {
+			*value, given = v, true
+		}
+	}
+	if _, ok := e.Option(DNS_OPTION); ok {
+		c.IPv4.DNS, c.IPv6.DNS, given = nil, nil, true
+		for _, a := range e.ListOption(DNS_OPTION) {
+			if strings.Contains(a, ":") {
+				c.IPv6.DNS = append(c.IPv6.DNS, a)
+				continue
+			}
+			c.IPv4.DNS = append(c.IPv4.DNS, a)
+		}
+	}
+	if _, ok := e.Option(DNS_SEARCH_OPTION); ok {
+		search := e.ListOption(DNS_SEARCH_OPTION)
+		c.IPv4.DNSSearch, c.IPv6.DNSSearch, given = search, search, true
+	}
+	if _, ok := e.Option(IGNORE_AUTO_DNS_OPTION); ok {
+		c.IPv4.IgnoreAutoDNS, c.IPv6.IgnoreAutoDNS = true, true
+		given = true
+	}
+	if v, ok := e.Option(ROUTE_METRIC_OPTION); ok {
+		metric, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: route metric: %w",
+				ErrIPConfig, err)
+		}
+		c.IPv4.RouteMetric, c.IPv6.RouteMetric = &metric, &metric
+		given = true
+	}
+	if v, ok := e.Option(PROXY_OPTION); ok {
+		c.Proxy.Method, given = wifi.ProxyMethod(v), true
+	}
+	if !given {
+		return nil, nil
+	}
+	if c.Proxy.PACURL != "" && c.Proxy.Method == "" {
+		c.Proxy.Method = wifi.ProxyAuto
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrIPConfig, err)
+	}
+	return c, nil
+}
+
+// readIPConfig decodes the json file at given path into given IP
+// configuration c.  Unknown properties are rejected.
+func (e *Env) readIPConfig(path string, c *wifi.IPConfig) error {
+	bb, err := e.lib().ReadFile(path)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(bb))
+	d.DisallowUnknownFields()
+	if err := d.Decode(c); err != nil {
+		return fmt.Errorf("'%s': %w", path, err)
+	}
+	return nil
+}
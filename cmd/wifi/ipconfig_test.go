@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"example.com/wifi"
+	. "github.com/slukits/gounit"
+)
+
+type AnIPConfig struct{ Suite }
+
+func (s *AnIPConfig) SetUp(t *T) { t.Parallel() }
+
+func (s *AnIPConfig) Is_nil_without_ip_options(t *T) {
+	c, err := mckArgs(&Env{}, "connect", "lab").IPConfig()
+	t.FatalOn(err)
+	t.True(c == nil)
+}
+
+func (s *AnIPConfig) Is_given_by_options(t *T) {
+	c, err := mckArgs(&Env{}, "connect", "lab",
+		"--ip4=10.0.0.10/8,10.1.0.10/16", "--gateway4=10.0.0.1",
+		"--ip6=fd00::10/64", "--dns=10.0.0.2, fd00::2",
+		"--dns-search=lab.example", "--ignore-auto-dns",
+		"--route-metric=50", "--pac-url=http://wpad/wpad.dat",
+	).IPConfig()
+	t.FatalOn(err)
+	t.FatalIfNot(t.True(c.IPv4.RouteMetric == c.IPv6.RouteMetric))
+	t.Eq(int64(50), *c.IPv4.RouteMetric)
+	c.IPv4.RouteMetric, c.IPv6.RouteMetric = nil, nil
+	t.Eq(wifi.IPConfig{
+		IPv4: wifi.IPSettings{
+			Addresses: []string{"10.0.0.10/8", "10.1.0.10/16"},
+			Gateway:   "10.0.0.1", DNS: []string{"10.0.0.2"},
+			DNSSearch:     []string{"lab.example"},
+			IgnoreAutoDNS: true,
+		},
+		IPv6: wifi.IPSettings{
+			Addresses:     []string{"fd00::10/64"},
+			DNS:           []string{"fd00::2"},
+			DNSSearch:     []string{"lab.example"},
+			IgnoreAutoDNS: true,
+		},
+		Proxy: wifi.Proxy{Method: wifi.ProxyAuto,
+			PACURL: "http://wpad/wpad.dat"},
+	}, *c)
+}
+
+func (s *AnIPConfig) Is_read_from_a_file_superseded_by_options(t *T) {
+	c, err := mckFilesEnv(map[string]string{
+		"ip.json": `{"ipv4":{"addresses":["10.0.0.10/8"],` +
+			`"gateway":"10.0.0.1"},"proxy":{"method":"none"}}`,
+	}, "connect", "lab", "--ip-file=ip.json",
+		"--gateway4=10.0.0.254").IPConfig()
+	t.FatalOn(err)
+	t.Eq(wifi.IPConfig{
+		IPv4: wifi.IPSettings{Addresses: []string{"10.0.0.10/8"},
+			Gateway: "10.0.0.254"},
+		Proxy: wifi.Proxy{Method: wifi.ProxyNone},
+	}, *c)
+}
+
+func (s *AnIPConfig) Fails_on_unknown_file_properties(t *T) {
+	_, err := mckFilesEnv(map[string]string{
+		"ip.json": `{"ipv4":{"adresses":["10.0.0.10/8"]}}`,
+	}, "connect", "lab", "--ip-file=ip.json").IPConfig()
+	t.ErrIs(err, ErrIPConfig)
+	t.Contains(err.Error(), "adresses")
+}
+
+func (s *AnIPConfig) Fails_on_invalid_settings(t *T) {
+	_, err := mckArgs(&Env{}, "connect", "lab",
+		"--route-metric=low").IPConfig()
+	t.ErrIs(err, ErrIPConfig)
+	_, err = mckArgs(&Env{}, "connect", "lab",
+		"--gateway4=10.0.0.1").IPConfig()
+	t.ErrIs(err, ErrIPConfig)
+	t.ErrIs(err, wifi.ErrIPConfig)
+}
+
+func TestAnIPConfig(t *testing.T) {
+	t.Parallel()
+	Run(&AnIPConfig{}, t)
+}
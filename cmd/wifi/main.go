@@ -0,0 +1,1164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"example.com/wifi"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+)
+
+const help = `
+NAME
+
+wifi - a simple commandline client to scan for, connect to and 
+       disconnect from wifi access points reachable by a given
+       wifi-adapter.
+
+SYNOPSIS
+
+	wifi active|scan|adapters|watch|profiles|disconnect|connect SSID
+		|delete SSID|show SSID|UUID|share SSID
+		|hotspot start [SSID]|stop|status|export SSID|import FILE...
+		[--wifi-adapter='DEVICE-NAME'] [--output=text|json]
+		[--password-file=FILE] [--password-command='COMMAND']
+		[--bus-address=ADDRESS] [--all-adapters] [--show-secrets]
+		[--uuid=UUID] [--profile-id=ID] [--all]
+		[--hidden[=SSID,...]] [--eap=peap|ttls|tls] [--eap-file=FILE]
+		[--identity=ID] [--anonymous-identity=ID] [--ca-cert=FILE]
+		[--client-cert=FILE] [--private-key=FILE]
+		[--domain-suffix-match=DOMAIN] [--ip-file=FILE]
+		[--ip4=ADDRESS/PREFIX,...] [--gateway4=ADDRESS]
+		[--ip6=ADDRESS/PREFIX,...] [--gateway6=ADDRESS]
+		[--dns=ADDRESS,...] [--dns-search=DOMAIN,...]
+		[--ignore-auto-dns] [--route-metric=METRIC]
+		[--proxy=none|auto] [--pac-url=URL]
+		[--band=bg|a] [--channel=CHANNEL] [--wpa3]
+		[--qr=FILE|STRING] [--png=FILE] [--with-secrets]
+		[--dir=DIR] [--overwrite] [--rename=ID]
+		[--format=keyfile|wpa_supplicant]
+
+
+DESCRIPTION
+
+	If wifi is called without any arguments this help is shown.
+	Only one subcommand at a time may be used.  The used wifi adapter 
+	for the subcommands defaults to the first found active wifi adapter
+	if neither an environment variable is set nor an adapter option is
+	given.  If an according environment variable is set, e.g.:
+
+		$ WIFI_ADAPTER=wlan0 wifi scan
+
+	then *wifi* tries to use this adapter.  A set adapter commandline 
+	option (see below) supersedes an environment variable.
+
+
+SUBCOMMANDS
+
+	active	provides the SSID of the active wifi connection.
+
+	scan	provides all access points which can be reached by a given
+		wifi-adapter with their SSID, BSSID, signal strength,
+		channel, band, frequency, security, mode, maximal bitrate
+		and the boot-time in seconds they were last seen.
+		With the --all-adapters option all wifi adapters scan in
+		parallel and each access point is reported once together
+		with the adapters seeing it and their signal strength.
+		Failing adapters are reported without ending the scan of
+		the others.
+		Access points hiding their SSID are reported with an empty
+		SSID unless their SSID is probed by the --hidden option.
+
+	adapters
+		lists all wifi adapters with their name, MAC address,
+		driver, state, managed and autoconnect flags and the SSID
+		of their active access point.  The adapter which is used by
+		the other subcommands is marked by a '*'.
+
+	watch	reports the events of given adapter with a timestamp until
+		wifi is interrupted, e.g. by Ctrl-C.  Reported are state
+		changes with their reasons, changes of the active access
+		point, access points appearing and disappearing and changes
+		of NetworkManager's connectivity, e.g.:
+
+			$ wifi watch --output=json
+			{"time":"...","adapter":"wlan0","kind":"state",...}
+
+		With json output each event is printed as a json document
+		on its own line.
+
+	disconnect
+		closes the current connection at given adapter.
+
+	connect SSID
+		connects to given SSID at given adapter querying a password
+		if the access point with given SSID is not configured and
+		secured by WEP, WPA-PSK or SAE.  Open and OWE access points
+		are configured without a password.
+		The password is taken from the first of the following
+		sources which is given: the --password-file option, the
+		--password-command option, the WIFI_PASSWORD environment
+		variable, a piped standard input, e.g.:
+
+			$ pass show wifi/home | wifi connect home
+
+		or finally it is queried on the terminal.
+		If NetworkManager asks for secrets during the activation,
+		e.g. because the password of a configured access point has
+		changed, the password is queried as well.  If the activation
+		fails, e.g. due to a wrong password, the reason reported by
+		NetworkManager is shown.  A new configuration is only kept
+		if the connect succeeds, i.e. a failed connect may simply be
+		repeated with the right password.
+		If connect is interrupted, e.g. by Ctrl-C, the pending
+		activation is deactivated and a configuration created by
+		connect is deleted.  A second interrupt ends wifi at once.
+		If several configurations exist for SSID connect fails
+		unless one of them is selected by the --uuid or the
+		--profile-id option; with these options SSID may be
+		omitted.
+		An access point hiding its SSID is connected with the
+		--hidden option, e.g.:
+
+			$ wifi connect lab --hidden
+
+		A new configuration of a hidden access point is flagged
+		hidden and secured by WPA-PSK unless the access point
+		answers the probe with a different security.
+		A WPA2/WPA3-Enterprise access point is configured by the
+		802.1X options below or an --eap-file, e.g.:
+
+			$ wifi connect corp --eap=peap --identity=me \
+				--ca-cert=/etc/ssl/corp-ca.pem
+
+		The password of a PEAP or TTLS identity is taken from the
+		password sources above.  The 802.1X settings are validated
+		before the configuration is added and are ignored if SSID
+		is configured already.
+		A new configuration uses automatic addressing unless the
+		ip options below or an --ip-file configure it, e.g.:
+
+			$ wifi connect lab --ip4=10.0.0.10/8 --gateway4=10.0.0.1 \
+				--dns=10.0.0.2 --ignore-auto-dns
+
+		Like the 802.1X settings they are validated before and
+		ignored for an existing configuration.
+		The SSID, password and hidden flag of a new configuration
+		may be taken from a wifi QR code's string, e.g. as printed
+		by share:
+
+			$ wifi connect --qr='WIFI:T:WPA;S:guest;P:secret;;'
+
+	delete SSID
+		deletes the configuration of the wifi access point with
+		given SSID.  Like connect delete fails if several
+		configurations exist for SSID unless one is selected by
+		the --uuid or the --profile-id option.  With the --all
+		option all configurations of SSID are deleted, e.g.:
+
+			$ wifi delete home --all
+
+	profiles
+		lists all wifi configurations with their id, UUID, SSID,
+		security, autoconnect flag and priority, the time they were
+		last used and the interface they are bound to.
+
+	show SSID|UUID
+		shows all settings of the wifi configuration with given
+		UUID or SSID.  Secrets are masked unless the --show-secrets
+		option is given.  Like connect show accepts the --uuid and
+		the --profile-id option.
+
+	share SSID
+		prints the credentials of the wifi configuration of given
+		SSID as wifi QR code string, e.g.:
+
+			WIFI:T:WPA;S:guest;P:guest-secret;;
+
+		followed by the QR code drawn by block characters for a
+		terminal with dark background; phone cameras join the
+		access point by scanning it.  The password is requested
+		from NetworkManager.  With the --png option the QR code is
+		also written to given file.  Like connect share accepts
+		the --uuid and the --profile-id option.
+
+	export SSID
+		prints the configuration of given SSID in NetworkManager's
+		keyfile format, e.g.:
+
+			$ wifi export home --with-secrets > home.nmconnection
+
+		Secrets are left out unless the --with-secrets option is
+		given.  With the --all option all configurations of SSID or
+		without SSID all wifi configurations are exported.  With
+		the --dir option each configuration is written to the file
+		ID.nmconnection in given directory instead.  Like connect
+		export accepts the --uuid and the --profile-id option.
+
+	import FILE...
+		adds the configurations of given keyfiles, e.g. written by
+		export.  A configuration whose UUID or SSID exists already
+		isn't imported unless the --overwrite option replaces the
+		existing configuration or the --rename option adds it with
+		a new id and UUID.  A failing file is reported and the
+		remaining files are imported anyway.
+		With the --format=wpa_supplicant option the files are
+		wpa_supplicant.conf files whose network blocks are added
+		like the configurations connect creates, e.g.:
+
+			$ wifi import --format=wpa_supplicant \
+				/etc/wpa_supplicant/wpa_supplicant.conf
+
+		Supported are the fields ssid, psk, key_mgmt, scan_ssid,
+		priority, disabled, id_str and wep_key0 and the EAP fields
+		of PEAP, TTLS and TLS.  A block which can't be converted is
+		reported by its file and line.
+
+	hotspot start [SSID]|stop|status
+		lets given adapter provide an access point sharing the
+		host's connectivity.  start creates and activates the
+		hotspot with given SSID defaulting to hotspot-ADAPTER and
+		prints its credentials, e.g.:
+
+			$ wifi hotspot start lab-spot --band=a --wpa3
+			hotspot on 'wlan0': SSID: 'lab-spot', security: sae, ...
+
+		The hotspot is secured by WPA2 or with the --wpa3 option by
+		WPA3.  Its password is taken from the --password-file
+		option, the --password-command option or the WIFI_PASSWORD
+		environment variable; if none is given a random password
+		is generated.  start fails if the adapter's hotspot is
+		active already.  stop deactivates the hotspot and deletes
+		its configuration.  status prints the credentials of the
+		adapter's hotspot.
+
+
+COMMAND LINE OPTIONS
+
+	--wifi-adapter='DEVICE-NAME'
+		lets you set the used wifi-adapter e.g.:
+
+			$ wifi scan --wifi-adapter='wlan0'
+
+		Note the --wifi-adapter option overwrites a set WIFI_ADAPTER 
+		environment variable.
+
+	--password-file=FILE
+		reads the password for connect from the first line of given
+		file.
+
+	--password-command='COMMAND'
+		executes given command by sh -c and uses the first line of
+		its output as password for connect, e.g.:
+
+			$ wifi connect home --password-command='pass show home'
+
+	--output=text|json
+		lets you choose how results and errors are reported.  It
+		defaults to text.  With json each sub-command but watch
+		prints exactly one json document to standard output, e.g.:
+
+			$ wifi scan --output=json
+			{"adapter":"wlan0","access_points":[...]}
+
+		Errors are reported as json object with an "error" property
+		and execution ends with exit code 1.
+
+	--all-adapters
+		lets scan use all wifi adapters instead of one, e.g.:
+
+			$ wifi scan --all-adapters
+
+	--uuid=UUID
+		selects the configuration with given UUID for connect,
+		delete and show, e.g.:
+
+			$ wifi connect --uuid=0b9c...
+
+	--profile-id=ID
+		selects the configuration with given id for connect, delete
+		and show, e.g.:
+
+			$ wifi connect home --profile-id=home-office
+
+	--all
+		lets delete remove and export report all selected
+		configurations.
+
+	--hidden[=SSID,...]
+		lets connect probe for an access point hiding its SSID and
+		lets scan probe the given comma separated SSIDs, e.g.:
+
+			$ wifi scan --hidden=lab,attic
+
+	--eap=peap|ttls|tls
+		selects the EAP method of a new enterprise configuration:
+		PEAP with MSCHAPv2, TTLS with PAP or EAP-TLS with a client
+		certificate.
+
+	--eap-file=FILE
+		reads the 802.1X settings of a new enterprise configuration
+		from given json file, e.g.:
+
+			{"method":"tls","identity":"me",
+			 "ca_cert":"ca.pem","client_cert":"me.pem",
+			 "private_key":"me.key","private_key_password":"..."}
+
+		Further properties are "anonymous_identity", "password"
+		and "domain_suffix_match".  Relative paths are relative to
+		the file's directory.  The single 802.1X options supersede
+		the file's settings.
+
+	--identity=ID, --anonymous-identity=ID
+		set the (anonymous outer) identity of an enterprise
+		configuration.
+
+	--ca-cert=FILE
+		sets the CA certificate verifying the authentication
+		server.
+
+	--client-cert=FILE, --private-key=FILE
+		set the client certificate and its private key of an EAP-TLS
+		configuration.  The key's password is taken from the
+		--eap-file or else from the --password-file option, the
+		--password-command option or the WIFI_PASSWORD environment
+		variable.
+
+	--domain-suffix-match=DOMAIN
+		constrains the domain of the authentication server's
+		certificate.
+
+	--ip-file=FILE
+		reads the ip, DNS and proxy settings of a new configuration
+		from given json file, e.g.:
+
+			{"ipv4":{"addresses":["10.0.0.10/8"],
+			  "gateway":"10.0.0.1","dns":["10.0.0.2"],
+			  "dns_search":["lab.example"],"ignore_auto_dns":true,
+			  "route_metric":50},
+			 "ipv6":{"addresses":["fd00::10/64"]},
+			 "proxy":{"method":"auto","pac_url":"http://wpad/wpad.dat"}}
+
+		The single ip options supersede the file's settings.
+
+	--ip4=ADDRESS/PREFIX,..., --gateway4=ADDRESS
+	--ip6=ADDRESS/PREFIX,..., --gateway6=ADDRESS
+		set static addresses and the default gateway of ipv4 or
+		ipv6 respectively.
+
+	--dns=ADDRESS,..., --dns-search=DOMAIN,...
+		set the name servers of both families and the DNS search
+		domains of ipv4 and ipv6.
+
+	--ignore-auto-dns
+		ignores automatically configured name servers and search
+		domains.
+
+	--route-metric=METRIC
+		sets the metric of the default routes; -1 leaves it to
+		NetworkManager.
+
+	--proxy=none|auto, --pac-url=URL
+		sets the proxy method; auto discovers the proxy by WPAD
+		unless the URL of a proxy auto-config script is given.
+
+	--band=bg|a, --channel=CHANNEL
+		set the band, i.e. 2.4 GHz or 5 GHz, and the channel of a
+		hotspot; a channel needs a band.  Both default to
+		NetworkManager's choice.
+
+	--wpa3
+		secures a hotspot by WPA3 (SAE) instead of WPA2.
+
+	--qr=FILE|STRING
+		lets connect create a new configuration from given wifi QR
+		code string or the string in given file.  An SSID argument
+		must match the QR code's SSID.
+
+	--png=FILE
+		lets share write the QR code as PNG image to given file.
+
+	--with-secrets
+		lets export request the secrets of the configurations from
+		NetworkManager and include them.
+
+	--dir=DIR
+		lets export write the keyfiles to given directory.
+
+	--overwrite
+		lets import replace the existing configuration with the
+		UUID or else the SSID of an imported configuration.
+
+	--rename=ID
+		lets import add the configuration of a single file with
+		given id and a new UUID next to existing configurations.
+
+	--format=keyfile|wpa_supplicant
+		sets the format of the files import reads.  It defaults to
+		keyfile.
+
+	--show-secrets
+		lets show request the secrets of a configuration from
+		NetworkManager and report them unmasked, e.g.:
+
+			$ wifi show home --show-secrets
+
+	--bus-address=ADDRESS
+		lets you reach NetworkManager on the D-Bus bus with given
+		address instead of the system bus, e.g. a proxied system bus
+		of a container or a bus forwarded over ssh:
+
+			$ wifi scan --bus-address=unix:path=/run/host/bus
+
+		All D-Bus connections of wifi go to this address.  The 
+		option overwrites a set WIFI_DBUS_ADDRESS environment 
+		variable.
+`
+
+const subErr = `
+wifi: error: unknown sub-command: '%s'
+call wifi without any argument to see its help.
+`
+
+const deviceErr = `
+wifi: error: device retrieval: %v
+call wifi without any argument to see its help.
+`
+
+const scanErr = `
+wifi: error: scan '%s': %v
+call wifi without any argument to see its help.
+`
+
+const scanAdapterErr = "wifi: error: scan '%s': %v\n"
+
+const disconnectErr = `
+wifi: error: disconnect '%s': %v
+call wifi without any argument to see its help.
+`
+
+const activeErr = `
+wifi: error: active on '%s': %v
+call wifi without any argument to see its help.
+`
+
+const connectErr = `
+wifi: error: connect on '%s': %v
+call wifi without any argument to see its help.
+`
+
+const adaptersErr = `
+wifi: error: adapters: %v
+call wifi without any argument to see its help.
+`
+
+const watchErr = `
+wifi: error: watch '%s': %v
+call wifi without any argument to see its help.
+`
+
+const profilesErr = `
+wifi: error: profiles: %v
+call wifi without any argument to see its help.
+`
+
+const showErr = `
+wifi: error: show %s: %v
+call wifi without any argument to see its help.
+`
+
+const ambiguousErr = `
+wifi: error: %s: %v
+select one configuration by --uuid or --profile-id, see: wifi profiles
+`
+
+const hotspotErr = `
+wifi: error: hotspot %s on '%s': %v
+call wifi without any argument to see its help.
+`
+
+const shareErr = `
+wifi: error: share %s: %v
+call wifi without any argument to see its help.
+`
+
+const exportErr = `
+wifi: error: export %s: %v
+call wifi without any argument to see its help.
+`
+
+const importErr = `
+wifi: error: import: %v
+call wifi without any argument to see its help.
+`
+
+const importFileErr = "wifi: error: import '%s': %v\n"
+
+const delErr = `
+wifi: error: delete on '%s': %v
+call wifi without any argument to see its help.
+`
+
+// scanLine is the text output format of a scanned access point
+const scanLine = "SSID: %s, BSSID: %s, strength: %d, " +
+	"channel: %d (%s, %d MHz), security: %s, mode: %s, " +
+	"max-bitrate: %d Mb/s, last-seen: %d"
+
+// sightingsLine is the text output format appended to the scanLine of
+// an access point seen by several adapters.
+const sightingsLine = ", seen by: %s"
+
+// adapterLine is the text output format of a listed wifi adapter
+const adapterLine = "%s %s, MAC: %s, driver: %s, state: %s, " +
+	"managed: %t, autoconnect: %t, SSID: '%s'"
+
+// profileLine is the text output format of a listed wifi profile
+const profileLine = "%s, UUID: %s, SSID: '%s', security: %s, " +
+	"autoconnect: %t, priority: %d, last-used: %s, interface: %s"
+
+// hotspotLine is the text output format of a hotspot
+const hotspotLine = "hotspot on '%s': SSID: '%s', security: %s, " +
+	"password: %s, band: %s, channel: %s, active: %t"
+
+// exportLine is the text output format of a profile exported to a
+// file
+const exportLine = "exported '%s' (%s) to %s"
+
+// importLine is the text output format of an imported profile
+const importLine = "%s '%s' (%s) from %s"
+
+// settingLine is the text output format of a shown profile setting
+const settingLine = "%s.%s: %v"
+
+// eventTime is the text output format of an event's timestamp
+const eventTime = "2006-01-02T15:04:05.000Z07:00"
+
+const outputErr = `
+wifi: error: unknown output format: '%s'
+call wifi without any argument to see its help.
+`
+
+func handleRequest(ctx context.Context, env *Env) {
+	if env.Output() != TextOutput && env.Output() != JSONOutput {
+		env.Fatal(fmt.Sprintf(outputErr, env.Output()))
+	}
+	switch env.Sub() {
+	case AdaptersSub:
+		handleAdapters(ctx, env)
+		return
+	case ProfilesSub:
+		handleProfiles(ctx, env)
+		return
+	case ShowSub:
+		handleShow(ctx, env)
+		return
+	case ShareSub:
+		handleShare(ctx, env)
+		return
+	case ExportSub:
+		handleExport(ctx, env)
+		return
+	case ImportSub:
+		handleImport(ctx, env)
+		return
+	}
+	if _, ok := env.Option(ALL_ADAPTERS_OPTION); ok &&
+		env.Sub() == ScanSub {
+		handleScanAll(ctx, env)
+		return
+	}
+	dev, err := env.Device(ctx)
+	if err != nil {
+		fatal(env, "", err, fmt.Sprintf(deviceErr, err))
+	}
+	asJSON := env.Output() == JSONOutput
+	switch env.Sub() {
+	case ActiveSub:
+		ssid, err := dev.Active(ctx)
+		if err != nil {
+			fatal(env, dev.Name(), err,
+				fmt.Sprintf(activeErr, dev.Name(), err))
+		}
+		if asJSON {
+			env.PrintJSON(activeReport{Adapter: dev.Name(), SSID: ssid})
+			return
+		}
+		env.Println(fmt.Sprintf("active access point on '%s' is: '%s'",
+			dev.Name(), ssid))
+	case ScanSub:
+		aa, err := dev.Scan(ctx, env.HiddenSSIDs()...)
+		if err != nil {
+			fatal(env, dev.Name(), err,
+				fmt.Sprintf(scanErr, dev.Name(), err))
+		}
+		if asJSON {
+			env.PrintJSON(scanReport{
+				Adapter: dev.Name(), AccessPoints: aa})
+			return
+		}
+		for _, a := range aa {
+			env.Println(fmt.Sprintf(scanLine, a.SSID, a.BSSID,
+				a.Strength, a.Channel, a.Band, a.Frequency, a.Security,
+				a.Mode, a.MaxBitrate/1000, a.LastSeen))
+		}
+	case DisconnectSub:
+		if err := dev.Disconnect(ctx); err != nil {
+			fatal(env, dev.Name(), err,
+				fmt.Sprintf(disconnectErr, dev.Name(), err))
+		}
+		if asJSON {
+			env.PrintJSON(disconnectReport{
+				Adapter: dev.Name(), Disconnected: true})
+		}
+	case ConnectSub:
+		sel := env.ProfileSelector()
+		creds, err := env.Credentials()
+		if err != nil {
+			fatal(env, dev.Name(), err,
+				fmt.Sprintf(connectErr, dev.Name(), err))
+		}
+		if creds != nil && sel.SSID == "" {
+			sel.SSID = creds.SSID
+		}
+		if creds != nil && sel.SSID != creds.SSID {
+			err := fmt.Errorf("%w: SSID '%s' differs from '%s'",
+				ErrCredentials, sel.SSID, creds.SSID)
+			fatal(env, dev.Name(), err,
+				fmt.Sprintf(connectErr, dev.Name(), err))
+		}
+		if sel == (wifi.ProfileSelector{}) {
+			fatal(env, dev.Name(), "missing SSID",
+				fmt.Sprintf(connectErr, dev.Name(), "missing SSID"))
+		}
+		ent, err := env.Enterprise()
+		if err != nil {
+			fatal(env, dev.Name(), err,
+				fmt.Sprintf(connectErr, dev.Name(), err))
+		}
+		ip, err := env.IPConfig()
+		if err != nil {
+			fatal(env, dev.Name(), err,
+				fmt.Sprintf(connectErr, dev.Name(), err))
+		}
+		_, hidden := env.Option(HIDDEN_OPTION)
+		err = dev.ConnectWith(ctx, sel, wifi.ConnectOptions{
+			Hidden:     hidden || creds != nil && creds.Hidden,
+			Enterprise: ent, IP: ip, Credentials: creds})
+		if err != nil {
+			profileFatal(env, dev.Name(), err,
+				fmt.Sprintf(connectErr, dev.Name(), err))
+		}
+		if !asJSON {
+			return
+		}
+		ssid := sel.SSID
+		if ssid == "" {
+			ssid, _ = dev.Active(ctx)
+		}
+		env.PrintJSON(connectReport{
+			Adapter: dev.Name(), SSID: ssid, Connected: true})
+	case DeleteSub:
+		sel := env.ProfileSelector()
+		if sel == (wifi.ProfileSelector{}) {
+			fatal(env, dev.Name(), "missing SSID",
+				fmt.Sprintf(delErr, dev.Name(), "missing SSID"))
+		}
+		report := deleteReport{
+			Adapter: dev.Name(), SSID: sel.SSID, Deleted: true}
+		if _, all := env.Option(ALL_OPTION); all {
+			pp, err := env.Client().DeleteProfiles(ctx, sel)
+			if err != nil {
+				fatal(env, dev.Name(), err,
+					fmt.Sprintf(delErr, dev.Name(), err))
+			}
+			for _, p := range pp {
+				report.Profiles = append(report.Profiles, p.UUID)
+			}
+		} else if err := env.Client().DeleteProfile(ctx, sel); err != nil {
+			profileFatal(env, dev.Name(), err,
+				fmt.Sprintf(delErr, dev.Name(), err))
+		}
+		if asJSON {
+			env.PrintJSON(report)
+		}
+	case HotspotSub:
+		handleHotspot(ctx, env, dev)
+	case WatchSub:
+		err := dev.Watch(ctx, func(e wifi.Event) {
+			if asJSON {
+				env.PrintJSON(e)
+				return
+			}
+			env.Println(eventText(e))
+		})
+		if err != nil {
+			fatal(env, dev.Name(), err,
+				fmt.Sprintf(watchErr, dev.Name(), err))
+		}
+	case ZeroSub:
+		env.Println(help)
+	default:
+		fatal(env, dev.Name(), fmt.Sprintf(
+			"unknown sub-command: '%s'", env.Sub()),
+			fmt.Sprintf(subErr, env.Sub()))
+	}
+}
+
+// eventText returns the text output of given event e.
+func eventText(e wifi.Event) string {
+	prefix := fmt.Sprintf("%s %s: ", e.Time.Format(eventTime), e.Adapter)
+	switch e.Kind {
+	case wifi.StateEvent:
+		if e.Reason == "" {
+			return prefix + fmt.Sprintf("state: %s", e.State)
+		}
+		return prefix + fmt.Sprintf("state: %s (%s)", e.State, e.Reason)
+	case wifi.ActiveAccessPointEvent:
+		if e.SSID == "" {
+			return prefix + "no active access point"
+		}
+		return prefix + fmt.Sprintf("active access point: '%s' (%s)",
+			e.SSID, e.BSSID)
+	case wifi.AccessPointAddedEvent:
+		return prefix + fmt.Sprintf("access point appeared: '%s' (%s)",
+			e.SSID, e.BSSID)
+	case wifi.AccessPointRemovedEvent:
+		return prefix + fmt.Sprintf(
+			"access point disappeared: '%s' (%s)", e.SSID, e.BSSID)
+	case wifi.ConnectivityEvent:
+		return prefix + fmt.Sprintf("connectivity: %s", e.Connectivity)
+	}
+	return prefix + string(e.Kind)
+}
+
+// handleAdapters reports all wifi adapters of given environment env's
+// client marking the adapter which env provides by default.
+func handleAdapters(ctx context.Context, env *Env) {
+	aa, err := env.Client().Adapters(ctx)
+	if err != nil {
+		fatal(env, "", err, fmt.Sprintf(adaptersErr, err))
+	}
+	dflt := ""
+	if dev, err := env.Device(ctx); err == nil {
+		dflt = dev.Name()
+	}
+	report := adaptersReport{Adapters: []adapterReport{}}
+	for _, a := range aa {
+		info, err := a.Info(ctx)
+		if err != nil {
+			fatal(env, a.Name(), err, fmt.Sprintf(adaptersErr, err))
+		}
+		report.Adapters = append(report.Adapters, adapterReport{
+			AdapterInfo: info, Default: info.Name == dflt})
+	}
+	if env.Output() == JSONOutput {
+		env.PrintJSON(report)
+		return
+	}
+	for _, a := range report.Adapters {
+		marker := " "
+		if a.Default {
+			marker = "*"
+		}
+		env.Println(fmt.Sprintf(adapterLine, marker, a.Name, a.MAC,
+			a.Driver, a.State, a.Managed, a.Autoconnect, a.SSID))
+	}
+}
+
+// handleProfiles reports all wifi profiles of given environment env's
+// client.
+func handleProfiles(ctx context.Context, env *Env) {
+	pp, err := env.Client().Profiles(ctx)
+	if err != nil {
+		fatal(env, "", err, fmt.Sprintf(profilesErr, err))
+	}
+	if env.Output() == JSONOutput {
+		env.PrintJSON(profilesReport{Profiles: pp})
+		return
+	}
+	for _, p := range pp {
+		lastUsed := "never"
+		if p.LastUsed > 0 {
+			lastUsed = time.Unix(int64(p.LastUsed), 0).Format(
+				time.RFC3339)
+		}
+		iface := p.Interface
+		if iface == "" {
+			iface = "any"
+		}
+		env.Println(fmt.Sprintf(profileLine, p.ID, p.UUID, p.SSID,
+			p.Security, p.Autoconnect, p.Priority, lastUsed, iface))
+	}
+}
+
+// handleShow reports the settings of the wifi profile given environment
+// env's selector selects.  The SSID argument is tried as UUID first.
+func handleShow(ctx context.Context, env *Env) {
+	sel := env.ProfileSelector()
+	if sel == (wifi.ProfileSelector{}) {
+		fatal(env, "", "missing SSID or UUID",
+			fmt.Sprintf(showErr, "", "missing SSID or UUID"))
+	}
+	_, secrets := env.Option(SHOW_SECRETS_OPTION)
+	var ss nm.ConnectionSettings
+	err := wifi.ErrNoProfile
+	if sel.SSID != "" && sel.UUID == "" {
+		ss, err = env.Client().ProfileSettings(ctx, wifi.ProfileSelector{
+			UUID: sel.SSID, ID: sel.ID}, secrets)
+	}
+	if errors.Is(err, wifi.ErrNoProfile) {
+		ss, err = env.Client().ProfileSettings(ctx, sel, secrets)
+	}
+	ref := sel.String()
+	if err != nil {
+		profileFatal(env, "", err, fmt.Sprintf(showErr, ref, err))
+	}
+	uuid, _ := ss["connection"]["uuid"].(string)
+	report := showReport{Profile: uuid,
+		Settings: map[string]map[string]interface{}{}}
+	for name, setting := range ss {
+		report.Settings[name] = map[string]interface{}{}
+		for k, v := range setting {
+			if bb, ok := v.([]byte); ok {
+				v = string(bb)
+			}
+			report.Settings[name][k] = v
+		}
+	}
+	if env.Output() == JSONOutput {
+		env.PrintJSON(report)
+		return
+	}
+	names := []string{}
+	for name := range report.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		keys := []string{}
+		for k := range report.Settings[name] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			env.Println(fmt.Sprintf(settingLine, name, k,
+				report.Settings[name][k]))
+		}
+	}
+}
+
+// handleHotspot starts, stops or reports the hotspot of given adapter
+// dev according to given environment env's hotspot action.
+func handleHotspot(ctx context.Context, env *Env, dev *wifi.WifiAdapter) {
+	action := env.HotspotAction()
+	var h wifi.Hotspot
+	var err error
+	switch action {
+	case HotspotStart:
+		var cfg wifi.HotspotConfig
+		if cfg, err = env.HotspotConfig(); err == nil {
+			h, err = dev.StartHotspot(ctx, cfg)
+		}
+	case HotspotStop:
+		err = dev.StopHotspot(ctx)
+	case HotspotStatus:
+		h, err = dev.HotspotStatus(ctx)
+	default:
+		err = fmt.Errorf("unknown action: '%s'", action)
+	}
+	if err != nil {
+		fatal(env, dev.Name(), err,
+			fmt.Sprintf(hotspotErr, action, dev.Name(), err))
+	}
+	asJSON := env.Output() == JSONOutput
+	if action == HotspotStop {
+		if asJSON {
+			env.PrintJSON(hotspotStopReport{
+				Adapter: dev.Name(), Stopped: true})
+		}
+		return
+	}
+	if asJSON {
+		env.PrintJSON(h)
+		return
+	}
+	if h.SSID == "" {
+		env.Println(fmt.Sprintf("no hotspot on '%s'", dev.Name()))
+		return
+	}
+	band, channel := h.Band, fmt.Sprint(h.Channel)
+	if band == "" {
+		band = "auto"
+	}
+	if h.Channel == 0 {
+		channel = "auto"
+	}
+	env.Println(fmt.Sprintf(hotspotLine, h.Adapter, h.SSID, h.Security,
+		h.Password, band, channel, h.Active))
+}
+
+// handleShare reports the credentials of the wifi profile given
+// environment env's selector selects as wifi QR code string and as QR
+// code rendered for the terminal.
+func handleShare(ctx context.Context, env *Env) {
+	sel := env.ProfileSelector()
+	if sel == (wifi.ProfileSelector{}) {
+		fatal(env, "", "missing SSID",
+			fmt.Sprintf(shareErr, "", "missing SSID"))
+	}
+	c, err := env.Client().Share(ctx, sel)
+	if err != nil {
+		profileFatal(env, "", err, fmt.Sprintf(shareErr, sel, err))
+	}
+	code, err := env.qrCode(c.String())
+	if err != nil {
+		fatal(env, "", err, fmt.Sprintf(shareErr, sel, err))
+	}
+	if env.Output() == JSONOutput {
+		env.PrintJSON(shareReport{Credentials: c, QR: c.String()})
+		return
+	}
+	env.Println(c.String())
+	env.Println(code)
+}
+
+// handleExport reports the profiles given environment env's selector
+// selects in NetworkManager's keyfile format.  Without the ALL_OPTION
+// exactly one profile must be selected.  With the DIR_OPTION the
+// keyfiles are written to the given directory instead.
+func handleExport(ctx context.Context, env *Env) {
+	sel := env.ProfileSelector()
+	_, all := env.Option(ALL_OPTION)
+	if sel == (wifi.ProfileSelector{}) && !all {
+		fatal(env, "", "missing SSID",
+			fmt.Sprintf(exportErr, "", "missing SSID"))
+	}
+	_, secrets := env.Option(WITH_SECRETS_OPTION)
+	var kk []wifi.Keyfile
+	var err error
+	if all {
+		kk, err = env.Client().ExportProfiles(ctx, sel, secrets)
+	} else {
+		var kf wifi.Keyfile
+		kf, err = env.Client().ExportProfile(ctx, sel, secrets)
+		kk = append(kk, kf)
+	}
+	if err != nil {
+		profileFatal(env, "", err, fmt.Sprintf(exportErr, sel, err))
+	}
+	dir, toDir := env.Option(DIR_OPTION)
+	report := exportReport{Profiles: []exportedReport{}}
+	for i, name := range keyfileNames(kk) {
+		exported := exportedReport{Profile: kk[i].Profile}
+		if !toDir {
+			exported.Keyfile = string(kk[i].Data)
+			report.Profiles = append(report.Profiles, exported)
+			continue
+		}
+		exported.File = filepath.Join(dir, name)
+		err := env.lib().WriteFile(exported.File, kk[i].Data, 0o600)
+		if err != nil {
+			fatal(env, "", err, fmt.Sprintf(exportErr, sel, err))
+		}
+		report.Profiles = append(report.Profiles, exported)
+	}
+	if env.Output() == JSONOutput {
+		env.PrintJSON(report)
+		return
+	}
+	for _, p := range report.Profiles {
+		if toDir {
+			env.Println(fmt.Sprintf(exportLine, p.ID, p.UUID, p.File))
+			continue
+		}
+		if len(report.Profiles) > 1 {
+			env.Println(fmt.Sprintf("# %s (%s)", p.ID, p.UUID))
+		}
+		env.Println(p.Keyfile)
+	}
+}
+
+// handleImport adds the profiles of the keyfiles or wpa_supplicant.conf
+// files given environment env provides.  A failing file or network
+// block is reported and the remaining ones are imported before
+// execution ends with a failure.
+func handleImport(ctx context.Context, env *Env) {
+	files := env.ImportFiles()
+	if len(files) == 0 {
+		fatal(env, "", "missing FILE",
+			fmt.Sprintf(importErr, "missing FILE"))
+	}
+	opts, err := env.ImportOptions()
+	if err != nil {
+		fatal(env, "", err, fmt.Sprintf(importErr, err))
+	}
+	format, err := env.ImportFormat()
+	if err != nil {
+		fatal(env, "", err, fmt.Sprintf(importErr, err))
+	}
+	report, failed := importReport{Imported: []importedReport{}}, 0
+	for _, file := range files {
+		var ii []importedReport
+		if format == SupplicantFormat {
+			ii = importSupplicantConf(ctx, env, file, opts)
+		} else {
+			ii = []importedReport{importKeyfile(ctx, env, file, opts)}
+		}
+		for _, i := range ii {
+			if i.Error != "" {
+				failed++
+			}
+		}
+		report.Imported = append(report.Imported, ii...)
+	}
+	asJSON := env.Output() == JSONOutput
+	if asJSON && failed > 0 {
+		env.FatalJSON(report)
+	}
+	if asJSON {
+		env.PrintJSON(report)
+		return
+	}
+	for _, i := range report.Imported {
+		if i.Error != "" {
+			fmt.Fprintf(env.lib().Stderr, importFileErr, i.File, i.Error)
+			continue
+		}
+		env.Println(fmt.Sprintf(importLine, i.Action, i.Profile.ID,
+			i.Profile.UUID, i.File))
+	}
+	if failed > 0 {
+		err := fmt.Errorf("%d of %d imports failed", failed,
+			len(report.Imported))
+		fatal(env, "", err, fmt.Sprintf(importErr, err))
+	}
+}
+
+// importKeyfile adds the profile of the keyfile with given path using
+// given environment env's client and reports the result.
+func importKeyfile(
+	ctx context.Context, env *Env, path string, opts wifi.ImportOptions,
+) importedReport {
+	bb, err := env.lib().ReadFile(path)
+	if err != nil {
+		return importedReport{File: path, Error: err.Error()}
+	}
+	ss, err := wifi.UnmarshalKeyfile(bb)
+	if err != nil {
+		return importedReport{File: path, Error: err.Error()}
+	}
+	return importSettings(ctx, env, path, ss, opts)
+}
+
+// importSupplicantConf adds a profile for each network block of the
+// wpa_supplicant.conf with given path using given environment env's
+// client and reports the result of each block as FILE:LINE.
+func importSupplicantConf(
+	ctx context.Context, env *Env, path string, opts wifi.ImportOptions,
+) []importedReport {
+	bb, err := env.lib().ReadFile(path)
+	if err != nil {
+		return []importedReport{{File: path, Error: err.Error()}}
+	}
+	nn, err := wifi.ParseSupplicantConf(bb)
+	if err != nil {
+		return []importedReport{{File: path, Error: err.Error()}}
+	}
+	ii := []importedReport{}
+	for _, n := range nn {
+		block := fmt.Sprintf("%s:%d", path, n.Line)
+		ss, err := n.Settings()
+		if err != nil {
+			ii = append(ii, importedReport{File: block,
+				Error: err.Error()})
+			continue
+		}
+		ii = append(ii, importSettings(ctx, env, block, ss, opts))
+	}
+	return ii
+}
+
+// importSettings adds a profile with given settings ss read from given
+// source using given environment env's client and reports the result.
+func importSettings(
+	ctx context.Context, env *Env, source string,
+	ss nm.ConnectionSettings, opts wifi.ImportOptions,
+) importedReport {
+	p, action, err := env.Client().ImportProfile(ctx, ss, opts)
+	if err != nil {
+		return importedReport{File: source, Error: err.Error()}
+	}
+	return importedReport{File: source, Profile: &p, Action: action}
+}
+
+// profileFatal ends execution like fatal but hints at the profile
+// selectors if given error err is a wifi.ErrAmbiguousProfile.
+func profileFatal(env *Env, adapter string, err error, msg string) {
+	if errors.Is(err, wifi.ErrAmbiguousProfile) {
+		msg = fmt.Sprintf(ambiguousErr, env.Sub(), err)
+	}
+	fatal(env, adapter, err, msg)
+}
+
+// handleScanAll reports the merged scan of all wifi adapters of given
+// environment env's client.  Failing adapters are reported in the json
+// report or on standard error respectively.
+func handleScanAll(ctx context.Context, env *Env) {
+	merged, err := env.Client().ScanAll(ctx, env.HiddenSSIDs()...)
+	if err != nil {
+		fatal(env, "", err, fmt.Sprintf(scanErr, "all adapters", err))
+	}
+	if env.Output() == JSONOutput {
+		report := allScanReport{AccessPoints: merged.AccessPoints}
+		for name, err := range merged.Errors {
+			if report.Errors == nil {
+				report.Errors = map[string]string{}
+			}
+			report.Errors[name] = err.Error()
+		}
+		env.PrintJSON(report)
+		return
+	}
+	names := []string{}
+	for name := range merged.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(env.lib().Stderr, scanAdapterErr, name,
+			merged.Errors[name])
+	}
+	for _, a := range merged.AccessPoints {
+		seenBy := []string{}
+		for _, s := range a.SeenBy {
+			seenBy = append(seenBy,
+				fmt.Sprintf("%s (%d)", s.Adapter, s.Strength))
+		}
+		env.Println(fmt.Sprintf(scanLine, a.SSID, a.BSSID,
+			a.Strength, a.Channel, a.Band, a.Frequency, a.Security,
+			a.Mode, a.MaxBitrate/1000, a.LastSeen) +
+			fmt.Sprintf(sightingsLine, strings.Join(seenBy, ", ")))
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(),
+		os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// let a second interrupt end execution at once
+		<-ctx.Done()
+		stop()
+	}()
+	env := &Env{}
+	defer env.Close()
+	handleRequest(ctx, env)
+}
@@ -0,0 +1,862 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/wifi"
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	. "github.com/slukits/gounit"
+)
+
+type RequestHandler struct{ Suite }
+
+// noSecretsMessage is contained in the reported error of an activation
+// failing for missing secrets.
+const noSecretsMessage = "secrets were required"
+
+func (s *RequestHandler) SetUp(t *T) { t.Parallel() }
+
+func (s *RequestHandler) Prints_help_if_no_sub_command_given(t *T) {
+	got := ""
+	handleRequest(bg, mckPrint(t, fakeEnv(), &got))
+	t.Contains(got, help)
+}
+
+func (s *RequestHandler) Fails_on_unknown_sub_command(t *T) {
+	expPnc, expErr, subCmd := "fatal mock panic", "", "unknown"
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, fmt.Sprintf(subErr, subCmd))
+	}()
+	handleRequest(bg, mckFakeNM(mckArgs(
+		mckFatal(t, &Env{}, expPnc, &expErr), subCmd), nmfake.Default()))
+}
+
+func (s *RequestHandler) Fails_on_failing_device_retrieval(t *T) {
+	expPnc, expErr := "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, wifi.ErrDeviceNotFound.Error())
+	}()
+	handleRequest(bg, mckEnvVar(mckArgs(
+		mckFatal(t, fakeEnv(), expPnc, &expErr)), "unknown"))
+}
+
+func (s *RequestHandler) Fails_on_unknown_output_format(t *T) {
+	expPnc, expErr := "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, fmt.Sprintf(outputErr, "yaml"))
+	}()
+	handleRequest(bg, mckArgs(
+		mckFatal(t, &Env{}, expPnc, &expErr), "scan", "--output=yaml"))
+}
+
+func (s *RequestHandler) Reports_errors_as_json_object(t *T) {
+	expPnc, code, got := "exit mock panic", 0, ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Eq(1, code)
+		report := errorReport{}
+		t.FatalOn(json.Unmarshal([]byte(got), &report))
+		t.Eq(ScanSub, report.Command)
+		t.Contains(report.Error, ErrMckNewNM.Error())
+	}()
+	handleRequest(bg, mckNewNMErr(mckExit(mckArgs(mckPrint(
+		t, &Env{}, &got), "scan", "--output=json"), expPnc, &code)))
+}
+
+type MckDeviceScanFailing struct {
+	nm.DeviceWireless
+}
+
+func (s *RequestHandler) Fails_on_failing_scan(t *T) {
+	env := mckNMWifiDevice(&Env{}, func(
+		wd nm.DeviceWireless) nm.DeviceWireless {
+		return &MckDeviceScanFailing{DeviceWireless: wd}
+	})
+	expPnc, expErr := "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, ErrMckDeviceScanFailing.Error())
+	}()
+	handleRequest(bg, mckArgs(
+		mckFatal(t, env, expPnc, &expErr), "scan"))
+}
+
+func (s *RequestHandler) Prints_available_SSID_on_scan(t *T) {
+	env := fakeEnv("scan")
+	out := []string{}
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		out = append(out, vv[0].(string))
+		return 0, nil
+	}
+	handleRequest(bg, env)
+	t.FatalIfNot(t.Eq(3, len(out)))
+	for i, SSID := range []string{"home", "office", "cafe"} {
+		t.Contains(out[i], SSID)
+	}
+}
+
+// mckFakePassword mocks given environment env's password environment
+// variable with given password pwd.
+func mckFakePassword(env *Env, pwd string) *Env {
+	env.Lib.OsEnv = func(key string) string {
+		if key == ENV_PASSWORD {
+			return pwd
+		}
+		return ""
+	}
+	return env
+}
+
+func (s *RequestHandler) Reports_active_SSID(t *T) {
+	got := ""
+	handleRequest(bg, mckPrint(t, fakeEnv("active"), &got))
+	t.Contains(got, "active access point on 'wlan0' is: 'home'")
+}
+
+func (s *RequestHandler) Disconnects_active_access_point(t *T) {
+	fake, got := nmfake.Default(), ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{},
+		"disconnect", "--output=json"), fake), &got))
+	t.Contains(got, `"disconnected":true`)
+	t.Eq(nm.NmDeviceStateDisconnected, fake.Device("wlan0").State)
+}
+
+func (s *RequestHandler) Connects_to_new_access_point(t *T) {
+	fake, got := nmfake.Default(), ""
+	handleRequest(bg, mckFakePassword(mckPrint(t, mckFakeNM(mckArgs(&Env{},
+		"connect", "office", "--output=json"), fake), &got),
+		"office-secret"))
+	report := connectReport{}
+	t.FatalOn(json.Unmarshal([]byte(got), &report))
+	t.True(report.Connected)
+	wlan := fake.Device("wlan0")
+	t.Eq(nm.NmDeviceStateActivated, wlan.State)
+	t.Eq("office", wlan.Active.SSID)
+	t.Eq(2, len(fake.Profiles()))
+}
+
+func (s *RequestHandler) Fails_connecting_with_wrong_password(t *T) {
+	fake, expPnc, expErr := nmfake.Default(), "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, noSecretsMessage)
+		t.Eq(nm.NmDeviceStateDisconnected, fake.Device("wlan0").State)
+		t.Eq(1, len(fake.Profiles()))
+	}()
+	handleRequest(bg, mckFakePassword(mckFatal(t, mckFakeNM(mckArgs(
+		&Env{}, "connect", "office"), fake), expPnc, &expErr), "wrong"))
+}
+
+func (s *RequestHandler) Rolls_back_an_interrupted_connect(t *T) {
+	fake, expPnc, expErr := nmfake.Default(), "fatal mock panic", ""
+	fake.Device("wlan0").Activate = func(
+		*nmfake.Device, *nmfake.Connection, *nmfake.AP,
+	) []nmfake.Transition {
+		return []nmfake.Transition{{State: nm.NmDeviceStatePrepare}}
+	}
+	ctx, cancel := context.WithTimeout(bg, 20*time.Millisecond)
+	defer cancel()
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, context.DeadlineExceeded.Error())
+		t.Eq(1, len(fake.Profiles()))
+		t.Eq(nm.NmDeviceStateDisconnected, fake.Device("wlan0").State)
+	}()
+	handleRequest(ctx, mckFakePassword(mckFatal(t, mckFakeNM(mckArgs(
+		&Env{}, "connect", "office"), fake), expPnc, &expErr),
+		"office-secret"))
+}
+
+// closingBus records if the client's bus connection was closed.
+type closingBus struct {
+	wifi.BusConnection
+	closed *bool
+}
+
+func (b closingBus) Close() error {
+	*b.closed = true
+	return b.BusConnection.Close()
+}
+
+// mckClosingBus mocks given environment env's client to record in given
+// closed flag if its bus connection was closed.
+func mckClosingBus(env *Env, closed *bool) *Env {
+	newClient := env.Lib.NewClient
+	env.Lib.NewClient = func() *wifi.Client {
+		client := newClient()
+		systemBus := client.Lib.SystemBus
+		client.Lib.SystemBus = func() (wifi.BusConnection, error) {
+			bus, err := systemBus()
+			return closingBus{BusConnection: bus, closed: closed}, err
+		}
+		return client
+	}
+	return env
+}
+
+func (s *RequestHandler) Closes_the_client_on_a_canceled_connect(t *T) {
+	for _, output := range []string{"--output=text", "--output=json"} {
+		fake, expPnc, closed := nmfake.Default(), "fatal mock panic", false
+		fake.Device("wlan0").Activate = func(
+			*nmfake.Device, *nmfake.Connection, *nmfake.AP,
+		) []nmfake.Transition {
+			return []nmfake.Transition{{State: nm.NmDeviceStatePrepare}}
+		}
+		ctx, cancel := context.WithTimeout(bg, 20*time.Millisecond)
+		env, expErr, code := &Env{}, "", 0
+		env = mckExit(mckFatal(t, env, expPnc, &expErr), expPnc, &code)
+		env.Lib.Println = func(vv ...interface{}) (int, error) {
+			return 0, nil
+		}
+		func() {
+			defer func() { t.Eq(expPnc, recover().(string)) }()
+			handleRequest(ctx, mckFakePassword(mckClosingBus(mckFakeNM(
+				mckArgs(env, "connect", "office", output), fake), &closed),
+				"office-secret"))
+		}()
+		cancel()
+		t.True(closed)
+	}
+}
+
+func (s *RequestHandler) Lists_adapters_marking_the_default(t *T) {
+	fake, out := nmfake.Default(), []string{}
+	fake.AddDevice("wlan1", nm.NmDeviceTypeWifi,
+		nm.NmDeviceStateUnavailable)
+	env := mckFakeNM(mckArgs(&Env{}, "adapters"), fake)
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		out = append(out, vv[0].(string))
+		return 0, nil
+	}
+	handleRequest(bg, env)
+	t.FatalIfNot(t.Eq(2, len(out)))
+	t.Eq("* wlan0, MAC: 02:00:00:00:00:01, driver: fake, state: "+
+		"activated, managed: true, autoconnect: true, SSID: 'home'",
+		out[0])
+	t.Contains(out[1], "  wlan1, ")
+	t.Contains(out[1], "state: unavailable")
+}
+
+func (s *RequestHandler) Lists_adapters_as_json_without_default(t *T) {
+	fake, got := nmfake.Default(), ""
+	fake.Device("wlan0").State = nm.NmDeviceStateUnavailable
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{},
+		"adapters", "--output=json"), fake), &got))
+	report := adaptersReport{}
+	t.FatalOn(json.Unmarshal([]byte(got), &report))
+	t.FatalIfNot(t.Eq(1, len(report.Adapters)))
+	t.Eq("wlan0", report.Adapters[0].Name)
+	t.Not.True(report.Adapters[0].Default)
+}
+
+// mckSecondRadio adds to given fake network manager a second wifi
+// device "wlan1" with given state seeing "home" and "lab".
+func mckSecondRadio(fake *nmfake.NM, state nm.NmDeviceState) *nmfake.NM {
+	wlan1 := fake.AddDevice("wlan1", nm.NmDeviceTypeWifi, state)
+	wlan1.AddAP(&nmfake.AP{SSID: "home", BSSID: "00:00:00:00:00:01",
+		Strength: 30, Frequency: 2412})
+	wlan1.AddAP(&nmfake.AP{SSID: "lab", BSSID: "00:00:00:00:00:10",
+		Strength: 90, Frequency: 5200})
+	return fake
+}
+
+func (s *RequestHandler) Scans_with_all_adapters(t *T) {
+	fake := mckSecondRadio(nmfake.Default(), nm.NmDeviceStateDisconnected)
+	env, out := mckFakeNM(mckArgs(&Env{}, "scan", "--all-adapters"),
+		fake), []string{}
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		out = append(out, vv[0].(string))
+		return 0, nil
+	}
+	handleRequest(bg, env)
+	t.FatalIfNot(t.Eq(4, len(out)))
+	t.Contains(out[0], "seen by: wlan1 (90)")
+	t.Contains(out[1], "seen by: wlan0 (80), wlan1 (30)")
+}
+
+func (s *RequestHandler) Reports_failing_adapters_of_a_scan(t *T) {
+	fake := mckSecondRadio(nmfake.Default(), nm.NmDeviceStateUnavailable)
+	got, stderr := "", &strings.Builder{}
+	env := mckPrint(t, mckFakeNM(mckArgs(&Env{}, "scan",
+		"--all-adapters", "--output=json"), fake), &got)
+	env.Lib.Stderr = stderr
+	handleRequest(bg, env)
+	report := allScanReport{}
+	t.FatalOn(json.Unmarshal([]byte(got), &report))
+	t.Eq(3, len(report.AccessPoints))
+	t.Contains(report.Errors["wlan1"], "scan")
+	env = mckFakeNM(mckArgs(&Env{}, "scan", "--all-adapters"), fake)
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		return 0, nil
+	}
+	env.Lib.Stderr = stderr
+	handleRequest(bg, env)
+	t.Contains(stderr.String(), "wifi: error: scan 'wlan1'")
+}
+
+func (s *RequestHandler) Deletes_profile_of_SSID(t *T) {
+	fake := nmfake.Default()
+	handleRequest(bg, mckFakeNM(mckArgs(&Env{}, "delete", "home"), fake))
+	t.Eq(0, len(fake.Profiles()))
+	t.Not.True(fake.Device("wlan0").State == nm.NmDeviceStateActivated)
+}
+
+// watchLines runs given environment env's watch sub-command on given
+// fake network manager and returns its first printed line.  The fake's
+// connectivity is changed until the watch reports it.
+func watchLines(t *T, env *Env, fake *nmfake.NM) string {
+	ctx, cancel := context.WithCancel(bg)
+	lines, done := make(chan string, 100), make(chan struct{})
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		lines <- vv[0].(string)
+		return 0, nil
+	}
+	go func() {
+		defer close(done)
+		handleRequest(ctx, mckFakeNM(env, fake))
+	}()
+	defer func() { cancel(); <-done }()
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case l := <-lines:
+			return l
+		case <-ticker.C:
+			fake.SetConnectivity(nm.NmConnectivityFull)
+		case <-timeout:
+			t.Fatal("watch reported no event")
+		}
+	}
+}
+
+func (s *RequestHandler) Watches_events_of_an_adapter(t *T) {
+	line := watchLines(t, mckArgs(&Env{}, "watch"), nmfake.Default())
+	t.Contains(line, " wlan0: connectivity: full")
+}
+
+func (s *RequestHandler) Watches_events_as_json_lines(t *T) {
+	line := watchLines(t, mckArgs(&Env{}, "watch", "--output=json"),
+		nmfake.Default())
+	e := wifi.Event{}
+	t.FatalOn(json.Unmarshal([]byte(line), &e))
+	t.Eq(wifi.ConnectivityEvent, e.Kind)
+	t.Eq("wlan0", e.Adapter)
+	t.Eq("full", e.Connectivity)
+	t.Not.True(e.Time.IsZero())
+}
+
+func (s *RequestHandler) Describes_watched_events(t *T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := wifi.Event{Time: at, Adapter: "wlan0", Kind: wifi.StateEvent,
+		State: "failed", Reason: "secrets were required"}
+	t.Eq("2024-05-01T12:00:00.000Z wlan0: state: failed "+
+		"(secrets were required)", eventText(e))
+	e = wifi.Event{Time: at, Adapter: "wlan0",
+		Kind: wifi.ActiveAccessPointEvent}
+	t.Contains(eventText(e), "no active access point")
+	e.SSID, e.BSSID = "home", "00:00:00:00:00:01"
+	t.Contains(eventText(e),
+		"active access point: 'home' (00:00:00:00:00:01)")
+	e.Kind = wifi.AccessPointAddedEvent
+	t.Contains(eventText(e), "access point appeared: 'home'")
+	e.Kind = wifi.AccessPointRemovedEvent
+	t.Contains(eventText(e), "access point disappeared: 'home'")
+}
+
+func (s *RequestHandler) Lists_profiles(t *T) {
+	fake, out := nmfake.Default(), []string{}
+	ss := nmfake.WifiSettings("lab", "sae", "lab-secret")
+	ss["connection"]["timestamp"] = uint64(1700000000)
+	ss["connection"]["interface-name"] = "wlan1"
+	fake.AddProfile(ss)
+	env := mckFakeNM(mckArgs(&Env{}, "profiles"), fake)
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		out = append(out, vv[0].(string))
+		return 0, nil
+	}
+	handleRequest(bg, env)
+	t.FatalIfNot(t.Eq(2, len(out)))
+	t.Eq("home, UUID: fake-home, SSID: 'home', security: wpa-psk, "+
+		"autoconnect: true, priority: 0, last-used: never, "+
+		"interface: any", out[0])
+	t.Contains(out[1], "security: sae")
+	t.Contains(out[1], "interface: wlan1")
+	t.Not.Contains(out[1], "never")
+}
+
+func (s *RequestHandler) Lists_profiles_as_json(t *T) {
+	got := ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{},
+		"profiles", "--output=json"), nmfake.Default()), &got))
+	report := profilesReport{}
+	t.FatalOn(json.Unmarshal([]byte(got), &report))
+	t.FatalIfNot(t.Eq(1, len(report.Profiles)))
+	t.Eq("fake-home", report.Profiles[0].UUID)
+	t.Eq(wifi.SecurityWPAPSK, report.Profiles[0].Security)
+}
+
+func (s *RequestHandler) Shows_profile_settings(t *T) {
+	out := []string{}
+	env := mckFakeNM(mckArgs(&Env{}, "show", "fake-home"),
+		nmfake.Default())
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		out = append(out, vv[0].(string))
+		return 0, nil
+	}
+	handleRequest(bg, env)
+	lines := strings.Join(out, "\n")
+	t.Contains(lines, "802-11-wireless.ssid: home")
+	t.Contains(lines, "802-11-wireless-security.key-mgmt: wpa-psk")
+	t.Not.Contains(lines, "home-secret")
+}
+
+func (s *RequestHandler) Shows_profile_secrets_on_request(t *T) {
+	got := ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "show",
+		"home", "--show-secrets", "--output=json"), nmfake.Default()),
+		&got))
+	report := showReport{}
+	t.FatalOn(json.Unmarshal([]byte(got), &report))
+	t.Eq("home", report.Settings["802-11-wireless"]["ssid"])
+	t.Eq("home-secret", report.Settings["802-11-wireless-security"]["psk"])
+}
+
+func (s *RequestHandler) Fails_showing_unknown_profile(t *T) {
+	expPnc, expErr := "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, "wifi: error: show SSID 'unknown'")
+	}()
+	handleRequest(bg, mckFatal(t, mckFakeNM(mckArgs(&Env{}, "show",
+		"unknown"), nmfake.Default()), expPnc, &expErr))
+}
+
+// mckOfficeAtHome adds to given fake network manager a second profile
+// "home-office" for the SSID "home".
+func mckOfficeAtHome(fake *nmfake.NM) *nmfake.NM {
+	ss := nmfake.WifiSettings("home", "wpa-psk", "home-secret")
+	ss["connection"]["id"] = "home-office"
+	ss["connection"]["uuid"] = "fake-home-office"
+	fake.AddProfile(ss)
+	return fake
+}
+
+func (s *RequestHandler) Fails_connecting_to_ambiguous_profile(t *T) {
+	expPnc, expErr := "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, wifi.ErrAmbiguousProfile.Error())
+		t.Contains(expErr, "--profile-id")
+	}()
+	handleRequest(bg, mckFatal(t, mckFakeNM(mckArgs(&Env{}, "connect",
+		"home"), mckOfficeAtHome(nmfake.Default())), expPnc, &expErr))
+}
+
+func (s *RequestHandler) Connects_profile_selected_by_id(t *T) {
+	got := ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "connect",
+		"--profile-id=home-office", "--output=json"),
+		mckOfficeAtHome(nmfake.Default())), &got))
+	report := connectReport{}
+	t.FatalOn(json.Unmarshal([]byte(got), &report))
+	t.True(report.Connected)
+	t.Eq("home", report.SSID)
+}
+
+func (s *RequestHandler) Deletes_all_profiles_of_SSID(t *T) {
+	fake, got := mckOfficeAtHome(nmfake.Default()), ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "delete",
+		"home", "--all", "--output=json"), fake), &got))
+	report := deleteReport{}
+	t.FatalOn(json.Unmarshal([]byte(got), &report))
+	t.Eq([]string{"fake-home", "fake-home-office"}, report.Profiles)
+	t.Eq(0, len(fake.Profiles()))
+}
+
+func (s *RequestHandler) Deletes_profile_selected_by_UUID(t *T) {
+	fake := mckOfficeAtHome(nmfake.Default())
+	handleRequest(bg, mckFakeNM(mckArgs(&Env{}, "delete",
+		"--uuid=fake-home-office"), fake))
+	t.FatalIfNot(t.Eq(1, len(fake.Profiles())))
+	t.Eq("home", fake.Profiles()[0].Settings()["connection"]["id"])
+}
+
+func (s *RequestHandler) Shows_profile_selected_by_id(t *T) {
+	got := ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "show",
+		"home", "--profile-id=home-office", "--output=json"),
+		mckOfficeAtHome(nmfake.Default())), &got))
+	report := showReport{}
+	t.FatalOn(json.Unmarshal([]byte(got), &report))
+	t.Eq("fake-home-office", report.Settings["connection"]["uuid"])
+}
+
+// mckHiddenLab adds the hidden access point "lab" to the device "wlan0"
+// of given fake network manager.
+func mckHiddenLab(fake *nmfake.NM) *nmfake.NM {
+	fake.Device("wlan0").AddAP(&nmfake.AP{
+		SSID: "lab", BSSID: "00:00:00:00:00:10", Strength: 50,
+		Flags:    uint32(nm.Nm80211APFlagsPrivacy),
+		RSNFlags: uint32(nm.Nm80211APSecKeyMgmtPSK),
+		Password: "lab-secret", Hidden: true,
+	})
+	return fake
+}
+
+func (s *RequestHandler) Scans_for_given_hidden_SSIDs(t *T) {
+	fake, got := mckHiddenLab(nmfake.Default()), ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "scan",
+		"--output=json"), fake), &got))
+	t.Not.Contains(got, `"lab"`)
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "scan",
+		"--hidden=attic, lab", "--output=json"), fake), &got))
+	t.Contains(got, `"ssid":"lab"`)
+}
+
+func (s *RequestHandler) Connects_to_hidden_access_point(t *T) {
+	fake := mckHiddenLab(nmfake.Default())
+	handleRequest(bg, mckFakePassword(mckFakeNM(mckArgs(&Env{},
+		"connect", "lab", "--hidden"), fake), "lab-secret"))
+	wlan := fake.Device("wlan0")
+	t.Eq(nm.NmDeviceStateActivated, wlan.State)
+	t.Eq("lab", wlan.Active.SSID)
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	t.Eq(true, pp[1].Settings()["802-11-wireless"]["hidden"])
+}
+
+func (s *RequestHandler) Connects_to_enterprise_access_point(t *T) {
+	fake := nmfake.Default()
+	fake.Device("wlan0").AddAP(&nmfake.AP{
+		SSID: "corp", BSSID: "00:00:00:00:00:20", Strength: 70,
+		Flags:    uint32(nm.Nm80211APFlagsPrivacy),
+		RSNFlags: uint32(nm.Nm80211APSecKeyMgmt8021X),
+		Password: "corp-secret",
+	})
+	handleRequest(bg, mckFakePassword(mckFakeNM(mckArgs(&Env{},
+		"connect", "corp", "--eap=peap", "--identity=me"), fake),
+		"corp-secret"))
+	t.Eq("corp", fake.Device("wlan0").Active.SSID)
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	t.Eq("me", pp[1].Settings()["802-1x"]["identity"])
+}
+
+func (s *RequestHandler) Fails_connecting_with_invalid_802_1x_options(
+	t *T,
+) {
+	fake, expPnc, expErr := nmfake.Default(), "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, "missing identity")
+		t.Eq(1, len(fake.Profiles()))
+	}()
+	handleRequest(bg, mckFatal(t, mckFakeNM(mckArgs(&Env{}, "connect",
+		"corp", "--eap=ttls"), fake), expPnc, &expErr))
+}
+
+func (s *RequestHandler) Connects_with_static_addressing(t *T) {
+	fake := nmfake.Default()
+	handleRequest(bg, mckFakeNM(mckArgs(&Env{}, "connect", "cafe",
+		"--ip4=10.0.0.10/8", "--gateway4=10.0.0.1", "--proxy=none"),
+		fake))
+	t.Eq("cafe", fake.Device("wlan0").Active.SSID)
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	t.Eq("manual", pp[1].Settings()["ipv4"]["method"])
+	t.Eq(int32(0), pp[1].Settings()["proxy"]["method"])
+}
+
+func (s *RequestHandler) Connects_with_static_ipv6_only(t *T) {
+	fake := nmfake.Default()
+	handleRequest(bg, mckFakeNM(mckArgs(&Env{}, "connect", "cafe",
+		"--ip6=fd00::10/64", "--dns=fd00::2", "--dns-search=lab.example"),
+		fake))
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	ipv6 := pp[1].Settings()["ipv6"]
+	t.Eq("manual", ipv6["method"])
+	t.Eq([]string{"lab.example"}, ipv6["dns-search"])
+}
+
+func (s *RequestHandler) Fails_connecting_with_invalid_ip_options(
+	t *T,
+) {
+	fake, expPnc, expErr := nmfake.Default(), "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, "10.0.0.300")
+		t.Eq(1, len(fake.Profiles()))
+	}()
+	handleRequest(bg, mckFatal(t, mckFakeNM(mckArgs(&Env{}, "connect",
+		"cafe", "--dns=10.0.0.300"), fake), expPnc, &expErr))
+}
+
+func (s *RequestHandler) Starts_a_hotspot_printing_its_credentials(
+	t *T,
+) {
+	fake, got := nmfake.Default(), ""
+	handleRequest(bg, mckPrint(t, mckFakePassword(mckFakeNM(mckArgs(
+		&Env{}, "hotspot", "start", "spot", "--band=bg", "--channel=6",
+		"--wpa3"), fake), "spot-secret"), &got))
+	t.Eq(fmt.Sprintf(hotspotLine, "wlan0", "spot", wifi.SecuritySAE,
+		"spot-secret", "bg", "6", true)+"\n", got)
+	wlan := fake.Device("wlan0")
+	t.Eq(nm.NmDeviceStateActivated, wlan.State)
+	t.Eq("spot", wlan.Active.SSID)
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	t.Eq("shared", pp[1].Settings()["ipv4"]["method"])
+}
+
+func (s *RequestHandler) Reports_and_stops_a_hotspot(t *T) {
+	fake, started, status, stopped := nmfake.Default(), "", "", ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "hotspot",
+		"start", "--output=json"), fake), &started))
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "hotspot",
+		"status", "--output=json"), fake), &status))
+	t.Eq(started, status)
+	t.Contains(status, `"ssid":"hotspot-wlan0"`)
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "hotspot",
+		"stop", "--output=json"), fake), &stopped))
+	t.Eq(`{"adapter":"wlan0","stopped":true}`+"\n", stopped)
+	t.Eq(1, len(fake.Profiles()))
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckArgs(&Env{}, "hotspot",
+		"status"), fake), &status))
+	t.Eq("no hotspot on 'wlan0'\n", status)
+}
+
+func (s *RequestHandler) Fails_starting_a_hotspot_with_invalid_options(
+	t *T,
+) {
+	fake, expPnc, expErr := nmfake.Default(), "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, wifi.ErrHotspotConfig.Error())
+		t.Eq(1, len(fake.Profiles()))
+	}()
+	handleRequest(bg, mckFatal(t, mckFakeNM(mckArgs(&Env{}, "hotspot",
+		"start", "--channel=6"), fake), expPnc, &expErr))
+}
+
+func (s *RequestHandler) Fails_on_unknown_hotspot_action(t *T) {
+	expPnc, expErr := "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, "unknown action: 'pause'")
+	}()
+	handleRequest(bg, mckFatal(t, fakeEnv("hotspot", "pause"),
+		expPnc, &expErr))
+}
+
+func (s *RequestHandler) Shares_a_profile_as_QR_code(t *T) {
+	qr, code, png := "", "", []byte{}
+	env := mckPrint(t, fakeEnv("share", "home", "--png=home.png"),
+		&qr, &code)
+	env.Lib.WriteFile = func(
+		path string, bb []byte, _ os.FileMode,
+	) error {
+		t.Eq("home.png", path)
+		png = bb
+		return nil
+	}
+	handleRequest(bg, env)
+	t.Eq("WIFI:T:WPA;S:home;P:home-secret;;\n", qr)
+	t.Contains(code, "█")
+	t.Eq("\x89PNG", string(png[:4]))
+}
+
+func (s *RequestHandler) Reports_shared_credentials_as_json(t *T) {
+	got := ""
+	handleRequest(bg, mckPrint(t, fakeEnv("share", "home",
+		"--output=json"), &got))
+	t.Eq(`{"ssid":"home","security":"wpa-psk",`+
+		`"password":"home-secret",`+
+		`"qr":"WIFI:T:WPA;S:home;P:home-secret;;"}`+"\n", got)
+}
+
+func (s *RequestHandler) Fails_sharing_unknown_profile(t *T) {
+	expPnc, expErr := "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, wifi.ErrNoProfile.Error())
+	}()
+	handleRequest(bg, mckFatal(t, fakeEnv("share", "cafe"),
+		expPnc, &expErr))
+}
+
+func (s *RequestHandler) Connects_with_QR_code_credentials(t *T) {
+	fake := nmfake.Default()
+	handleRequest(bg, mckFakeNM(mckArgs(&Env{}, "connect",
+		"--qr=WIFI:T:WPA;S:office;P:office-secret;;"), fake))
+	t.Eq("office", fake.Device("wlan0").Active.SSID)
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	ss := pp[1].Settings()
+	t.Eq("office-secret", ss["802-11-wireless-security"]["psk"])
+}
+
+func (s *RequestHandler) Fails_connecting_to_other_SSID_than_QR_code(
+	t *T,
+) {
+	fake, expPnc, expErr := nmfake.Default(), "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, "SSID 'cafe' differs from 'office'")
+		t.Eq(1, len(fake.Profiles()))
+	}()
+	handleRequest(bg, mckFatal(t, mckFakeNM(mckArgs(&Env{}, "connect",
+		"cafe", "--qr=WIFI:T:WPA;S:office;P:office-secret;;"), fake),
+		expPnc, &expErr))
+}
+
+func (s *RequestHandler) Exports_a_profile_as_keyfile(t *T) {
+	got := ""
+	handleRequest(bg, mckPrint(t, fakeEnv("export", "home",
+		"--with-secrets"), &got))
+	t.Contains(got, "[connection]\nid=home\n")
+	t.Contains(got, "psk=home-secret\n")
+}
+
+func (s *RequestHandler) Exports_all_profiles_to_a_directory(t *T) {
+	fake := nmfake.Default()
+	fake.AddProfile(nmfake.WifiSettings("office", "wpa-psk", "o-pwd"))
+	out, files := []string{}, map[string]string{}
+	env := mckFakeNM(mckArgs(&Env{}, "export", "--all", "--dir=/kf"),
+		fake)
+	env.Lib.Println = func(vv ...interface{}) (int, error) {
+		out = append(out, vv[0].(string))
+		return 0, nil
+	}
+	env.Lib.WriteFile = func(
+		path string, bb []byte, mode os.FileMode,
+	) error {
+		t.Eq(os.FileMode(0o600), mode)
+		files[path] = string(bb)
+		return nil
+	}
+	handleRequest(bg, env)
+	t.FatalIfNot(t.Eq(2, len(files)))
+	t.Contains(files["/kf/office.nmconnection"], "ssid=office\n")
+	t.Not.Contains(files["/kf/office.nmconnection"], "o-pwd")
+	t.Eq([]string{
+		"exported 'home' (fake-home) to /kf/home.nmconnection",
+		"exported 'office' (fake-office) to /kf/office.nmconnection",
+	}, out)
+}
+
+func (s *RequestHandler) Fails_exporting_without_SSID(t *T) {
+	expPnc, expErr := "fatal mock panic", ""
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, fmt.Sprintf(exportErr, "", "missing SSID"))
+	}()
+	handleRequest(bg, mckFatal(t, fakeEnv("export"), expPnc, &expErr))
+}
+
+// importFiles are keyfiles of a new, a duplicate and an invalid
+// profile.
+var importFiles = map[string]string{
+	"office.nmconnection": "[connection]\nid=office\ntype=wifi\n" +
+		"[wifi]\nssid=office\n[wifi-security]\nkey-mgmt=wpa-psk\n" +
+		"psk=office-secret\n",
+	"home.nmconnection": "[connection]\nid=home\nuuid=fake-home\n" +
+		"type=wifi\n[wifi]\nssid=home\n",
+	"lan.nmconnection": "[connection]\nid=lan\ntype=ethernet\n",
+}
+
+func (s *RequestHandler) Imports_keyfiles_reporting_failing_files(t *T) {
+	fake, expPnc, expErr := nmfake.Default(), "fatal mock panic", ""
+	got, stderr := "", &strings.Builder{}
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, "2 of 3 imports failed")
+		t.Contains(got, "added 'office' (")
+		t.Contains(stderr.String(),
+			"wifi: error: import 'home.nmconnection': ")
+		t.Contains(stderr.String(), wifi.ErrDuplicateProfile.Error())
+		t.Contains(stderr.String(),
+			"wifi: error: import 'lan.nmconnection': ")
+		t.Eq(2, len(fake.Profiles()))
+	}()
+	env := mckFakeNM(mckFilesEnv(importFiles, "import",
+		"office.nmconnection", "home.nmconnection", "lan.nmconnection"),
+		fake)
+	env.Lib.Stderr = stderr
+	handleRequest(bg, mckPrint(t, mckFatal(t, env, expPnc, &expErr),
+		&got))
+}
+
+func (s *RequestHandler) Imports_keyfiles_overwriting_duplicates(t *T) {
+	fake, got := nmfake.Default(), ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckFilesEnv(importFiles,
+		"import", "home.nmconnection", "--overwrite", "--output=json"),
+		fake), &got))
+	report := importReport{}
+	t.FatalOn(json.Unmarshal([]byte(got), &report))
+	t.FatalIfNot(t.Eq(1, len(report.Imported)))
+	t.Eq(wifi.ImportOverwritten, report.Imported[0].Action)
+	t.Eq("fake-home", report.Imported[0].Profile.UUID)
+	t.Eq(1, len(fake.Profiles()))
+}
+
+func (s *RequestHandler) Imports_a_renamed_keyfile(t *T) {
+	fake, got := nmfake.Default(), ""
+	handleRequest(bg, mckPrint(t, mckFakeNM(mckFilesEnv(importFiles,
+		"import", "home.nmconnection", "--rename=home-2"), fake), &got))
+	t.Contains(got, "renamed 'home-2' (")
+	t.Eq(2, len(fake.Profiles()))
+}
+
+func (s *RequestHandler) Imports_networks_of_a_wpa_supplicant_conf(
+	t *T,
+) {
+	fake, expPnc, expErr := nmfake.Default(), "fatal mock panic", ""
+	got, stderr := "", &strings.Builder{}
+	defer func() {
+		t.Eq(expPnc, recover().(string))
+		t.Contains(expErr, "1 of 2 imports failed")
+		t.Contains(got, "added 'office' (")
+		t.Contains(got, ") from wpa.conf:1")
+		t.Contains(stderr.String(),
+			"wifi: error: import 'wpa.conf:5': ")
+		t.Contains(stderr.String(), "WPA-EAP-SUITE-B-192")
+		pp := fake.Profiles()
+		t.FatalIfNot(t.Eq(2, len(pp)))
+		ss := pp[1].Settings()
+		t.Eq("office-secret", ss["802-11-wireless-security"]["psk"])
+	}()
+	env := mckFakeNM(mckFilesEnv(map[string]string{
+		"wpa.conf": "network={\n\tssid=\"office\"\n" +
+			"\tpsk=\"office-secret\"\n}\n" +
+			"network={\n\tssid=\"corp\"\n" +
+			"\tkey_mgmt=WPA-EAP-SUITE-B-192\n}\n",
+	}, "import", "--format=wpa_supplicant", "wpa.conf"), fake)
+	env.Lib.Stderr = stderr
+	handleRequest(bg, mckPrint(t, mckFatal(t, env, expPnc, &expErr),
+		&got))
+}
+
+var ErrMckDeviceScanFailing = errors.New("device scan failing mock")
+
+func (m *MckDeviceScanFailing) RequestScan() error {
+	return ErrMckDeviceScanFailing
+}
+
+func TestRequestHandler(t *testing.T) {
+	t.Parallel()
+	Run(&RequestHandler{}, t)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"example.com/wifi"
+)
+
+// OutputFormat determines how sub-command results and errors are
+// reported.
+type OutputFormat string
+
+const (
+	TextOutput OutputFormat = "text"
+	JSONOutput OutputFormat = "json"
+)
+
+// OUTPUT_OPTION is the name of the output format commandline option
+const OUTPUT_OPTION = "output"
+
+// Output returns the output format set by the OUTPUT_OPTION defaulting
+// to TextOutput.  NOTE Output returns any set value, i.e. it is the
+// caller's responsibility to reject unknown output formats.
+func (e *Env) Output() OutputFormat {
+	format, ok := e.Option(OUTPUT_OPTION)
+	if !ok || format == "" {
+		return TextOutput
+	}
+	return OutputFormat(format)
+}
+
+// PrintJSON prints given value v json encoded to given environment e's
+// standard library printer.
+func (e *Env) PrintJSON(v interface{}) {
+	bb, err := json.Marshal(v)
+	if err != nil {
+		e.Fatal(err)
+	}
+	e.Println(string(bb))
+}
+
+// FatalJSON prints given value v json encoded, closes given
+// environment e's client and ends execution with a non-zero exit code.
+func (e *Env) FatalJSON(v interface{}) {
+	e.PrintJSON(v)
+	e.Close()
+	e.lib().Exit(1)
+	panic("env: expected execution to end")
+}
+
+// errorReport is the json document reported for a failing sub-command.
+type errorReport struct {
+	Command SubCommand `json:"command,omitempty"`
+	Adapter string     `json:"adapter,omitempty"`
+	Error   string     `json:"error"`
+}
+
+// fatal ends execution reporting given error err which occurred while
+// the current sub-command was executed on given adapter.  If json
+// output is requested err is reported as errorReport otherwise given
+// human readable message msg is reported.
+func fatal(env *Env, adapter string, err interface{}, msg string) {
+	if env.Output() == JSONOutput {
+		env.FatalJSON(errorReport{
+			Command: env.Sub(),
+			Adapter: adapter,
+			Error:   fmt.Sprint(err),
+		})
+	}
+	env.Fatal(msg)
+}
+
+// scanReport is the json document reported by the scan sub-command.
+type scanReport struct {
+	Adapter      string             `json:"adapter"`
+	AccessPoints []wifi.AccessPoint `json:"access_points"`
+}
+
+// allScanReport is the json document reported by the scan sub-command
+// with the ALL_ADAPTERS_OPTION.
+type allScanReport struct {
+	AccessPoints []wifi.MergedAccessPoint `json:"access_points"`
+	Errors       map[string]string        `json:"errors,omitempty"`
+}
+
+// activeReport is the json document reported by the active sub-command.
+type activeReport struct {
+	Adapter string `json:"adapter"`
+	SSID    string `json:"ssid"`
+}
+
+// connectReport is the json document reported by the connect
+// sub-command.
+type connectReport struct {
+	Adapter   string `json:"adapter"`
+	SSID      string `json:"ssid"`
+	Connected bool   `json:"connected"`
+}
+
+// disconnectReport is the json document reported by the disconnect
+// sub-command.
+type disconnectReport struct {
+	Adapter      string `json:"adapter"`
+	Disconnected bool   `json:"disconnected"`
+}
+
+// deleteReport is the json document reported by the delete
+// sub-command.
+type deleteReport struct {
+	Adapter string `json:"adapter"`
+	SSID    string `json:"ssid"`
+	Deleted bool   `json:"deleted"`
+
+	// Profiles are the UUIDs of the profiles deleted by delete with
+	// the ALL_OPTION.
+	Profiles []string `json:"profiles,omitempty"`
+}
+
+// adaptersReport is the json document reported by the adapters
+// sub-command.
+type adaptersReport struct {
+	Adapters []adapterReport `json:"adapters"`
+}
+
+// adapterReport describes an adapter of the adaptersReport and if it
+// is the adapter used by default.
+type adapterReport struct {
+	wifi.AdapterInfo
+	Default bool `json:"default"`
+}
+
+// profilesReport is the json document reported by the profiles
+// sub-command.
+type profilesReport struct {
+	Profiles []wifi.Profile `json:"profiles"`
+}
+
+// showReport is the json document reported by the show sub-command
+// for the profile with the UUID Profile.
+type showReport struct {
+	Profile  string                            `json:"profile"`
+	Settings map[string]map[string]interface{} `json:"settings"`
+}
+
+// hotspotStopReport is the json document reported by the hotspot
+// sub-command's stop action.
+type hotspotStopReport struct {
+	Adapter string `json:"adapter"`
+	Stopped bool   `json:"stopped"`
+}
+
+// shareReport is the json document reported by the share sub-command;
+// QR is the credentials' wifi QR code string.
+type shareReport struct {
+	wifi.Credentials
+	QR string `json:"qr"`
+}
+
+// exportReport is the json document reported by the export sub-command.
+type exportReport struct {
+	Profiles []exportedReport `json:"profiles"`
+}
+
+// exportedReport describes an exported profile of the exportReport
+// with the file it was written to or else its keyfile's content.
+type exportedReport struct {
+	wifi.Profile
+	File    string `json:"file,omitempty"`
+	Keyfile string `json:"keyfile,omitempty"`
+}
+
+// importReport is the json document reported by the import sub-command.
+type importReport struct {
+	Imported []importedReport `json:"imported"`
+}
+
+// importedReport describes how the profile of an imported file, or of
+// a network block of an imported wpa_supplicant.conf at FILE:LINE, was
+// added or why its import failed.
+type importedReport struct {
+	File    string            `json:"file"`
+	Profile *wifi.Profile     `json:"profile,omitempty"`
+	Action  wifi.ImportAction `json:"action,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
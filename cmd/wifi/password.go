@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// PASSWORD_FILE_OPTION is the name of the commandline option providing
+// a file whose first line is the password.
+const PASSWORD_FILE_OPTION = "password-file"
+
+// PASSWORD_COMMAND_OPTION is the name of the commandline option
+// providing a shell command whose first output line is the password.
+const PASSWORD_COMMAND_OPTION = "password-command"
+
+// ENV_PASSWORD is the name of the password environment variable
+const ENV_PASSWORD = "WIFI_PASSWORD"
+
+var ErrPassword = errors.New("env: password")
+
+// Password provides the password for the access point with given SSID
+// evaluating the following sources in the given order:
+//   - the first line of the file set by the PASSWORD_FILE_OPTION
+//   - the first output line of the command set by the
+//     PASSWORD_COMMAND_OPTION which is executed by sh -c
+//   - the ENV_PASSWORD environment variable
+//   - the first line read from standard input if it is not a terminal
+//   - a password query on the terminal whose prompt is written to
+//     standard error
+//
+// NOTE a password read from a piped standard input is remembered since
+// it can be read only once.
+func (e *Env) Password(SSID string) (string, error) {
+	if pwd, ok, err := e.GivenPassword(); ok || err != nil {
+		return pwd, err
+	}
+	if !e.lib().IsTerminal() {
+		return e.pipedPassword()
+	}
+	fmt.Fprintf(e.lib().Stderr, "password for '%s': ", SSID)
+	pwd, err := e.lib().ReadPassword()
+	fmt.Fprintln(e.lib().Stderr, "")
+	if err != nil {
+		return "", fmt.Errorf("%w: terminal: %w", ErrPassword, err)
+	}
+	return string(pwd), nil
+}
+
+// GivenPassword provides the password of the first of the sources
+// Password evaluates which isn't interactive, i.e. the
+// PASSWORD_FILE_OPTION, the PASSWORD_COMMAND_OPTION and the
+// ENV_PASSWORD environment variable.  The returned flag is false if
+// none of them is given.
+func (e *Env) GivenPassword() (string, bool, error) {
+	if path, ok := e.Option(PASSWORD_FILE_OPTION); ok {
+		bb, err := e.lib().ReadFile(path)
+		if err != nil {
+			return "", true, fmt.Errorf("%w: file: %w", ErrPassword, err)
+		}
+		return firstLine(string(bb)), true, nil
+	}
+	if cmd, ok := e.Option(PASSWORD_COMMAND_OPTION); ok {
+		bb, err := e.lib().Command(cmd)
+		if err != nil {
+			return "", true, fmt.Errorf("%w: command: %w",
+				ErrPassword, err)
+		}
+		return firstLine(string(bb)), true, nil
+	}
+	if pwd := e.lib().OsEnv(ENV_PASSWORD); pwd != "" {
+		return pwd, true, nil
+	}
+	return "", false, nil
+}
+
+func (e *Env) pipedPassword() (string, error) {
+	if e.stdinPassword != nil {
+		return *e.stdinPassword, nil
+	}
+	line, err := bufio.NewReader(e.lib().Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("%w: stdin: %w", ErrPassword, err)
+	}
+	pwd := firstLine(line)
+	e.stdinPassword = &pwd
+	return pwd, nil
+}
+
+// command executes given shell command cmd by sh -c and returns its
+// standard output.
+func (e *Env) command(cmd string) ([]byte, error) {
+	c := exec.Command("sh", "-c", cmd)
+	c.Stderr = e.lib().Stderr
+	return c.Output()
+}
+
+func isTerminal() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+
+func readPassword() ([]byte, error) {
+	return term.ReadPassword(int(os.Stdin.Fd()))
+}
+
+// firstLine returns given string s up to its first line break.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
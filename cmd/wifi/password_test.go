@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/slukits/gounit"
+)
+
+type APassword struct{ Suite }
+
+func (s *APassword) SetUp(t *T) { t.Parallel() }
+
+// mckPasswordEnv returns an environment with given arguments aa whose
+// password sources are mocked:  the file "pwd.txt" contains "file-pwd",
+// the command "pwd-cmd" outputs "command-pwd", the WIFI_PASSWORD is
+// given envPwd and stdin is a pipe providing given stdin.
+func mckPasswordEnv(envPwd, stdin string, aa ...string) *Env {
+	env := mckArgs(&Env{}, aa...)
+	env.Lib.ReadFile = func(path string) ([]byte, error) {
+		if path != "pwd.txt" {
+			return nil, errors.New("read file error mock")
+		}
+		return []byte("file-pwd\nsecond line"), nil
+	}
+	env.Lib.Command = func(cmd string) ([]byte, error) {
+		if cmd != "pwd-cmd" {
+			return nil, errors.New("command error mock")
+		}
+		return []byte("command-pwd\n"), nil
+	}
+	env.Lib.OsEnv = func(key string) string {
+		if key == ENV_PASSWORD {
+			return envPwd
+		}
+		return ""
+	}
+	env.Lib.IsTerminal = func() bool { return false }
+	env.Lib.Stdin = strings.NewReader(stdin)
+	return env
+}
+
+func (s *APassword) Is_read_from_password_file_first(t *T) {
+	pwd, err := mckPasswordEnv("env-pwd", "stdin-pwd\n", "connect",
+		"ssid", "--password-file=pwd.txt",
+		"--password-command=pwd-cmd").Password("ssid")
+	t.FatalOn(err)
+	t.Eq("file-pwd", pwd)
+}
+
+func (s *APassword) Is_read_from_password_command_second(t *T) {
+	pwd, err := mckPasswordEnv("env-pwd", "stdin-pwd\n", "connect",
+		"ssid", "--password-command=pwd-cmd").Password("ssid")
+	t.FatalOn(err)
+	t.Eq("command-pwd", pwd)
+}
+
+func (s *APassword) Is_read_from_environment_variable_third(t *T) {
+	pwd, err := mckPasswordEnv("env-pwd", "stdin-pwd\n", "connect",
+		"ssid").Password("ssid")
+	t.FatalOn(err)
+	t.Eq("env-pwd", pwd)
+}
+
+func (s *APassword) Is_read_once_from_piped_stdin(t *T) {
+	env := mckPasswordEnv("", "stdin-pwd", "connect", "ssid")
+	pwd, err := env.Password("ssid")
+	t.FatalOn(err)
+	t.Eq("stdin-pwd", pwd)
+	pwd, err = env.Password("ssid")
+	t.FatalOn(err)
+	t.Eq("stdin-pwd", pwd)
+}
+
+func (s *APassword) Fails_on_empty_piped_stdin(t *T) {
+	_, err := mckPasswordEnv("", "", "connect", "ssid").Password("ssid")
+	t.ErrIs(err, ErrPassword)
+}
+
+func (s *APassword) Fails_if_password_file_is_unreadable(t *T) {
+	_, err := mckPasswordEnv("", "", "connect", "ssid",
+		"--password-file=unknown").Password("ssid")
+	t.ErrIs(err, ErrPassword)
+}
+
+func (s *APassword) Fails_if_password_command_fails(t *T) {
+	_, err := mckPasswordEnv("", "", "connect", "ssid",
+		"--password-command=unknown").Password("ssid")
+	t.ErrIs(err, ErrPassword)
+}
+
+func (s *APassword) Is_queried_on_terminal_with_prompt_on_stderr(t *T) {
+	env, stderr := mckPasswordEnv("", "", "connect", "ssid"),
+		&bytes.Buffer{}
+	env.Lib.IsTerminal = func() bool { return true }
+	env.Lib.Stderr = stderr
+	env.Lib.ReadPassword = func() ([]byte, error) {
+		return []byte("tty-pwd"), nil
+	}
+	pwd, err := env.Password("ssid")
+	t.FatalOn(err)
+	t.Eq("tty-pwd", pwd)
+	t.Contains(stderr.String(), "password for 'ssid'")
+}
+
+func (s *APassword) Command_runs_in_a_shell(t *T) {
+	bb, err := (&Env{}).command("echo shell-pwd")
+	t.FatalOn(err)
+	t.Eq("shell-pwd", firstLine(string(bb)))
+}
+
+func TestAPassword(t *testing.T) {
+	t.Parallel()
+	Run(&APassword{}, t)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"example.com/wifi"
+	"github.com/skip2/go-qrcode"
+)
+
+// QR_OPTION is the name of the commandline option providing the wifi
+// QR code string, or a file holding it, connect creates a new
+// configuration from.
+const QR_OPTION = "qr"
+
+// PNG_OPTION is the name of the commandline option providing the file
+// share writes the QR code to as PNG image.
+const PNG_OPTION = "png"
+
+// qrPNGSize is the width and height in pixels of a written QR code.
+const qrPNGSize = 512
+
+var ErrCredentials = errors.New("env: wifi QR code")
+
+// Credentials returns the credentials of the wifi QR code set by given
+// environment e's QR_OPTION or nil if it isn't set.  A value which
+// doesn't start with WIFI: is the path of a file holding the QR code's
+// string.
+func (e *Env) Credentials() (*wifi.Credentials, error) {
+	qr, ok := e.Option(QR_OPTION)
+	if !ok {
+		return nil, nil
+	}
+	if !strings.HasPrefix(strings.ToUpper(qr), "WIFI:") {
+		bb, err := e.lib().ReadFile(qr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: file: %w", ErrCredentials, err)
+		}
+		qr = string(bb)
+	}
+	c, err := wifi.ParseCredentials(qr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCredentials, err)
+	}
+	return &c, nil
+}
+
+// qrCode returns the QR code of given string s rendered by unicode
+// block characters for a terminal with dark background.  The QR code
+// is written as PNG image to the file set by given environment e's
+// PNG_OPTION.
+func (e *Env) qrCode(s string) (string, error) {
+	qr, err := qrcode.New(s, qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+	path, ok := e.Option(PNG_OPTION)
+	if !ok {
+		return qr.ToSmallString(false), nil
+	}
+	bb, err := qr.PNG(qrPNGSize)
+	if err != nil {
+		return "", err
+	}
+	if err := e.lib().WriteFile(path, bb, 0o600); err != nil {
+		return "", err
+	}
+	return qr.ToSmallString(false), nil
+}
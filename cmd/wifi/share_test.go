@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"example.com/wifi"
+	. "github.com/slukits/gounit"
+)
+
+type SomeCredentials struct{ Suite }
+
+func (s *SomeCredentials) SetUp(t *T) { t.Parallel() }
+
+func (s *SomeCredentials) Are_nil_without_qr_option(t *T) {
+	c, err := mckArgs(&Env{}, "connect", "guest").Credentials()
+	t.FatalOn(err)
+	t.True(c == nil)
+}
+
+func (s *SomeCredentials) Are_parsed_from_a_string_or_a_file(t *T) {
+	exp := wifi.Credentials{SSID: "guest", Security: wifi.SecurityWPAPSK,
+		Password: "guest-secret"}
+	c, err := mckArgs(&Env{}, "connect",
+		"--qr='WIFI:T:WPA;S:guest;P:guest-secret;;'").Credentials()
+	t.FatalOn(err)
+	t.Eq(exp, *c)
+	c, err = mckFilesEnv(map[string]string{
+		"guest.txt": "WIFI:T:WPA;S:guest;P:guest-secret;;\n",
+	}, "connect", "--qr=guest.txt").Credentials()
+	t.FatalOn(err)
+	t.Eq(exp, *c)
+}
+
+func (s *SomeCredentials) Fail_if_invalid(t *T) {
+	_, err := mckFilesEnv(nil, "connect", "--qr=guest.txt").Credentials()
+	t.ErrIs(err, ErrCredentials)
+	_, err = mckArgs(&Env{}, "connect",
+		"--qr=WIFI:T:WPA;P:secret;;").Credentials()
+	t.ErrIs(err, ErrCredentials)
+	t.ErrIs(err, wifi.ErrCredentials)
+}
+
+func TestSomeCredentials(t *testing.T) {
+	t.Parallel()
+	Run(&SomeCredentials{}, t)
+}
@@ -1,6 +1,6 @@
 /* helper for dbus debugging */
 
-package main
+package wifi
 
 import (
 	"fmt"
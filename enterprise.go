@@ -0,0 +1,134 @@
+package wifi
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// EAPMethod is the EAP method authenticating at a WPA2/WPA3-Enterprise
+// access point.
+type EAPMethod string
+
+const (
+	// EAPPEAP authenticates with PEAP and MSCHAPv2 as inner method.
+	EAPPEAP EAPMethod = "peap"
+
+	// EAPTTLS authenticates with TTLS and PAP as inner method.
+	EAPTTLS EAPMethod = "ttls"
+
+	// EAPTLS authenticates with a client certificate.
+	EAPTLS EAPMethod = "tls"
+)
+
+// phase2Auth maps the tunneled EAP methods to their inner method.
+var phase2Auth = map[EAPMethod]string{
+	EAPPEAP: "mschapv2",
+	EAPTTLS: "pap",
+}
+
+// Enterprise describes the 802.1X authentication of new configuration
+// settings for a WPA2/WPA3-Enterprise access point.  All file paths
+// must be absolute.
+type Enterprise struct {
+	Method            EAPMethod `json:"method"`
+	Identity          string    `json:"identity"`
+	AnonymousIdentity string    `json:"anonymous_identity,omitempty"`
+
+	// Password authenticates the identity of a PEAP or TTLS
+	// authentication; it is queried like the password of a WPA-PSK
+	// access point if not set.
+	Password string `json:"password,omitempty"`
+
+	// CACert is the path of the CA certificate the authentication
+	// server's certificate is verified with.
+	CACert string `json:"ca_cert,omitempty"`
+
+	// DomainSuffixMatch constrains the domain of the authentication
+	// server's certificate.
+	DomainSuffixMatch string `json:"domain_suffix_match,omitempty"`
+
+	// ClientCert, PrivateKey and PrivateKeyPassword are the paths of
+	// the client certificate and its private key and the password
+	// decrypting the key of a TLS authentication.
+	ClientCert         string `json:"client_cert,omitempty"`
+	PrivateKey         string `json:"private_key,omitempty"`
+	PrivateKeyPassword string `json:"private_key_password,omitempty"`
+}
+
+// enterpriseSettings key identifying the 802.1X section of wifi
+// connection settings.
+const enterpriseSettings = "802-1x"
+
+var ErrEnterprise = errors.New("invalid 802.1X settings")
+
+// Validate fails with ErrEnterprise if given enterprise settings e lack
+// a known method or an identity, if a TLS authentication lacks its
+// client certificate or private key, if a PEAP or TTLS authentication
+// has a client certificate or private key or if a path isn't absolute.
+func (e *Enterprise) Validate() error {
+	if e.Method != EAPPEAP && e.Method != EAPTTLS && e.Method != EAPTLS {
+		return fmt.Errorf("%w: unknown EAP method '%s'",
+			ErrEnterprise, e.Method)
+	}
+	if e.Identity == "" {
+		return fmt.Errorf("%w: missing identity", ErrEnterprise)
+	}
+	if e.Method == EAPTLS {
+		if e.ClientCert == "" || e.PrivateKey == "" {
+			return fmt.Errorf("%w: %s: missing client certificate or "+
+				"private key", ErrEnterprise, e.Method)
+		}
+	} else if e.ClientCert != "" || e.PrivateKey != "" ||
+		e.PrivateKeyPassword != "" {
+		return fmt.Errorf("%w: %s: unexpected client certificate or "+
+			"private key", ErrEnterprise, e.Method)
+	}
+	for _, path := range []string{e.CACert, e.ClientCert, e.PrivateKey} {
+		if path != "" && !filepath.IsAbs(path) {
+			return fmt.Errorf("%w: path not absolute: '%s'",
+				ErrEnterprise, path)
+		}
+	}
+	return nil
+}
+
+// needsPassword returns true if the identity of given enterprise
+// settings e authenticates with a password which is not set.
+func (e *Enterprise) needsPassword() bool {
+	return e.Method != EAPTLS && e.Password == ""
+}
+
+// settings returns the 802-1x section of given enterprise settings e
+// using given password pwd if e's password isn't set.
+func (e *Enterprise) settings(pwd string) map[string]interface{} {
+	ss := map[string]interface{}{
+		"eap":      []string{string(e.Method)},
+		"identity": e.Identity,
+	}
+	set := func(key, value string) {
+		if value != "" {
+			ss[key] = value
+		}
+	}
+	blob := func(key, path string) {
+		if path != "" {
+			ss[key] = []byte("file://" + path + "\x00")
+		}
+	}
+	set("anonymous-identity", e.AnonymousIdentity)
+	set("domain-suffix-match", e.DomainSuffixMatch)
+	blob("ca-cert", e.CACert)
+	if e.Method == EAPTLS {
+		blob("client-cert", e.ClientCert)
+		blob("private-key", e.PrivateKey)
+		set("private-key-password", e.PrivateKeyPassword)
+		return ss
+	}
+	ss["phase2-auth"] = phase2Auth[e.Method]
+	if e.Password != "" {
+		pwd = e.Password
+	}
+	ss["password"] = pwd
+	return ss
+}
@@ -0,0 +1,128 @@
+package wifi
+
+import (
+	"testing"
+
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	. "github.com/slukits/gounit"
+)
+
+type AnEnterprise struct{ Suite }
+
+func (s *AnEnterprise) SetUp(t *T) { t.Parallel() }
+
+func (s *AnEnterprise) Needs_a_known_method_and_an_identity(t *T) {
+	t.ErrIs((&Enterprise{Method: "leap", Identity: "me"}).Validate(),
+		ErrEnterprise)
+	t.ErrIs((&Enterprise{Method: EAPPEAP}).Validate(), ErrEnterprise)
+	t.FatalOn((&Enterprise{Method: EAPPEAP, Identity: "me"}).Validate())
+}
+
+func (s *AnEnterprise) Needs_client_certificate_and_key_only_for_tls(
+	t *T,
+) {
+	tls := &Enterprise{Method: EAPTLS, Identity: "me",
+		ClientCert: "/etc/me.pem"}
+	t.ErrIs(tls.Validate(), ErrEnterprise)
+	tls.PrivateKey = "/etc/me.key"
+	t.FatalOn(tls.Validate())
+	t.ErrIs((&Enterprise{Method: EAPTTLS, Identity: "me",
+		PrivateKey: "/etc/me.key"}).Validate(), ErrEnterprise)
+}
+
+func (s *AnEnterprise) Needs_absolute_paths(t *T) {
+	err := (&Enterprise{Method: EAPPEAP, Identity: "me",
+		CACert: "ca.pem"}).Validate()
+	t.ErrIs(err, ErrEnterprise)
+	t.Contains(err.Error(), "ca.pem")
+}
+
+func (s *AnEnterprise) Tunnels_peap_with_mschapv2(t *T) {
+	e := &Enterprise{Method: EAPPEAP, Identity: "me",
+		AnonymousIdentity: "anonymous", CACert: "/etc/ca.pem",
+		DomainSuffixMatch: "corp.example"}
+	t.True(e.needsPassword())
+	t.Eq(map[string]interface{}{
+		"eap":                 []string{"peap"},
+		"identity":            "me",
+		"anonymous-identity":  "anonymous",
+		"domain-suffix-match": "corp.example",
+		"ca-cert":             []byte("file:///etc/ca.pem\x00"),
+		"phase2-auth":         "mschapv2",
+		"password":            "queried",
+	}, e.settings("queried"))
+}
+
+func (s *AnEnterprise) Authenticates_tls_with_client_certificate(t *T) {
+	e := &Enterprise{Method: EAPTLS, Identity: "me",
+		ClientCert: "/etc/me.pem", PrivateKey: "/etc/me.key",
+		PrivateKeyPassword: "key-secret"}
+	t.Not.True(e.needsPassword())
+	t.Eq(map[string]interface{}{
+		"eap":                  []string{"tls"},
+		"identity":             "me",
+		"client-cert":          []byte("file:///etc/me.pem\x00"),
+		"private-key":          []byte("file:///etc/me.key\x00"),
+		"private-key-password": "key-secret",
+	}, e.settings(""))
+}
+
+// withCorp adds the enterprise access point "corp" to the device
+// "wlan0" of given fake network manager and returns a client of the
+// fake providing the corp's password.
+func withCorp(fake *nmfake.NM) *Client {
+	fake.Device("wlan0").AddAP(&nmfake.AP{
+		SSID: "corp", BSSID: "00:00:00:00:00:20", Strength: 70,
+		Frequency: 5500, Flags: uint32(nm.Nm80211APFlagsPrivacy),
+		RSNFlags: uint32(nm.Nm80211APSecKeyMgmt8021X),
+		Password: "corp-secret",
+	})
+	return mckFakeNM(&Client{Password: func(string) (string, error) {
+		return "corp-secret", nil
+	}}, fake)
+}
+
+func (s *AnEnterprise) Configures_a_new_profile_at_connect(t *T) {
+	fake := nmfake.Default()
+	adapter, err := withCorp(fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	t.FatalOn(adapter.ConnectWith(bg, ProfileSelector{SSID: "corp"},
+		ConnectOptions{Enterprise: &Enterprise{
+			Method: EAPTTLS, Identity: "me"}}))
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	ss := pp[1].Settings()
+	t.Eq("wpa-eap", ss[wirelessSecurity]["key-mgmt"])
+	t.Eq("pap", ss[enterpriseSettings]["phase2-auth"])
+	t.Eq("corp-secret", ss[enterpriseSettings]["password"])
+}
+
+func (s *AnEnterprise) Is_validated_before_a_profile_is_added(t *T) {
+	fake := nmfake.Default()
+	adapter, err := withCorp(fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	err = adapter.ConnectWith(bg, ProfileSelector{SSID: "corp"},
+		ConnectOptions{Enterprise: &Enterprise{Method: EAPTLS,
+			Identity: "me"}})
+	t.ErrIs(err, ErrAdapterConnect)
+	t.ErrIs(err, ErrEnterprise)
+	t.Eq(1, len(fake.Profiles()))
+}
+
+func (s *AnEnterprise) Is_required_by_an_enterprise_access_point(t *T) {
+	fake := nmfake.Default()
+	adapter, err := withCorp(fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	t.ErrIs(adapter.Connect(bg, "corp"), ErrUnsupportedSecurity)
+	err = adapter.ConnectWith(bg, ProfileSelector{SSID: "office"},
+		ConnectOptions{Enterprise: &Enterprise{Method: EAPPEAP,
+			Identity: "me"}})
+	t.ErrIs(err, ErrEnterprise)
+	t.Eq(1, len(fake.Profiles()))
+}
+
+func TestAnEnterprise(t *testing.T) {
+	t.Parallel()
+	Run(&AnEnterprise{}, t)
+}
@@ -0,0 +1,227 @@
+package wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/google/uuid"
+)
+
+// Keyfile is a wifi connection profile in NetworkManager's keyfile
+// format.
+type Keyfile struct {
+	Profile Profile
+	Data    []byte
+}
+
+var ErrExport = errors.New("client: export")
+
+// ExportProfile returns the wifi connection profile selected by given
+// selector sel as keyfile.  Its secrets are requested from
+// NetworkManager and included if given secrets flag is set.
+// ExportProfile fails with ErrNoProfile or ErrAmbiguousProfile unless
+// exactly one profile is selected.
+func (c *Client) ExportProfile(
+	ctx context.Context, sel ProfileSelector, secrets bool,
+) (Keyfile, error) {
+	if err := ctx.Err(); err != nil {
+		return Keyfile{}, fmt.Errorf("%w: %s: %w", ErrExport, sel, err)
+	}
+	cnn, p, err := c.profileConnection(sel)
+	if err != nil {
+		return Keyfile{}, fmt.Errorf("%w: %w", ErrExport, err)
+	}
+	if cnn == nil {
+		return Keyfile{}, fmt.Errorf("%w: %w for %s",
+			ErrExport, ErrNoProfile, sel)
+	}
+	kf, err := keyfileOf(cnn, p, secrets)
+	if err != nil {
+		return kf, fmt.Errorf("%w: %s: %w", ErrExport, sel, err)
+	}
+	return kf, nil
+}
+
+// ExportProfiles returns all wifi connection profiles selected by
+// given selector sel as keyfiles like ExportProfile.  ExportProfiles
+// fails with ErrNoProfile if no profile is selected.
+func (c *Client) ExportProfiles(
+	ctx context.Context, sel ProfileSelector, secrets bool,
+) ([]Keyfile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrExport, sel, err)
+	}
+	cc, pp, err := c.profileConnections(sel)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrExport, sel, err)
+	}
+	if len(cc) == 0 {
+		return nil, fmt.Errorf("%w: %w for %s",
+			ErrExport, ErrNoProfile, sel)
+	}
+	kk := []Keyfile{}
+	for i, cnn := range cc {
+		kf, err := keyfileOf(cnn, pp[i], secrets)
+		if err != nil {
+			return nil, fmt.Errorf("%w: '%s': %w",
+				ErrExport, pp[i].UUID, err)
+		}
+		kk = append(kk, kf)
+	}
+	return kk, nil
+}
+
+// keyfileOf returns the keyfile of given connection profile cnn
+// described by given profile p including its secrets if given secrets
+// flag is set.
+func keyfileOf(
+	cnn nm.Connection, p Profile, secrets bool,
+) (Keyfile, error) {
+	ss, err := cnn.GetSettings()
+	if err != nil {
+		return Keyfile{}, err
+	}
+	for _, setting := range ss {
+		for k := range setting {
+			if secretKeys[k] {
+				delete(setting, k)
+			}
+		}
+	}
+	if secrets {
+		if err := mergeSecrets(cnn, ss); err != nil {
+			return Keyfile{}, fmt.Errorf("secrets: %w", err)
+		}
+	}
+	bb, err := MarshalKeyfile(ss)
+	if err != nil {
+		return Keyfile{}, err
+	}
+	return Keyfile{Profile: p, Data: bb}, nil
+}
+
+// ImportAction reports how an imported profile was added.
+type ImportAction string
+
+const (
+	ImportAdded       ImportAction = "added"
+	ImportOverwritten ImportAction = "overwritten"
+	ImportRenamed     ImportAction = "renamed"
+)
+
+// ImportOptions resolve the conflicts of an imported profile with the
+// existing profiles.
+type ImportOptions struct {
+
+	// Overwrite lets the imported profile replace the settings of the
+	// existing profile with its UUID or else with its SSID.
+	Overwrite bool
+
+	// Rename adds the imported profile with this id and a new UUID
+	// next to existing profiles.
+	Rename string
+}
+
+var ErrImport = errors.New("client: import")
+var ErrDuplicateProfile = errors.New("duplicate configuration")
+
+// ImportProfile adds a wifi connection profile with given settings ss,
+// e.g. read by UnmarshalKeyfile, and returns it.  A missing UUID is
+// created and a missing id defaults to the SSID.  ImportProfile fails
+// with ErrDuplicateProfile if a profile with the UUID or SSID of ss
+// exists unless given options opts resolve the conflict; it fails with
+// ErrAmbiguousProfile if several profiles with the SSID of ss exist
+// and should be overwritten.
+func (c *Client) ImportProfile(
+	ctx context.Context, ss nm.ConnectionSettings, opts ImportOptions,
+) (Profile, ImportAction, error) {
+	if err := ctx.Err(); err != nil {
+		return Profile{}, "", fmt.Errorf("%w: %w", ErrImport, err)
+	}
+	if ss["connection"]["type"] != wirelessSettings {
+		return Profile{}, "", fmt.Errorf("%w: not a wifi profile: %v",
+			ErrImport, ss["connection"]["type"])
+	}
+	if ssid, _ := ss[wirelessSettings]["ssid"].([]byte); len(ssid) == 0 {
+		return Profile{}, "", fmt.Errorf("%w: missing SSID", ErrImport)
+	}
+	if id, _ := ss["connection"]["uuid"].(string); id == "" ||
+		opts.Rename != "" {
+		ss["connection"]["uuid"] = uuid.New().String()
+	}
+	if opts.Rename != "" {
+		ss["connection"]["id"] = opts.Rename
+	}
+	if id, _ := ss["connection"]["id"].(string); id == "" {
+		ssid, _ := ss[wirelessSettings]["ssid"].([]byte)
+		ss["connection"]["id"] = string(ssid)
+	}
+	p := profileOf(ss)
+	settings, err := c.lib().NewSettings()
+	if err != nil {
+		return p, "", fmt.Errorf("%w: '%s': %w", ErrImport, p.ID, err)
+	}
+	if opts.Rename != "" {
+		if _, err := settings.AddConnection(ss); err != nil {
+			return p, "", fmt.Errorf("%w: '%s': %w",
+				ErrImport, p.ID, err)
+		}
+		return p, ImportRenamed, nil
+	}
+	target, err := c.importTarget(p, opts.Overwrite)
+	if err != nil {
+		return p, "", fmt.Errorf("%w: '%s': %w", ErrImport, p.ID, err)
+	}
+	if target == nil {
+		if _, err := settings.AddConnection(ss); err != nil {
+			return p, "", fmt.Errorf("%w: '%s': %w",
+				ErrImport, p.ID, err)
+		}
+		return p, ImportAdded, nil
+	}
+	old, err := target.GetSettings()
+	if err != nil {
+		return p, "", fmt.Errorf("%w: '%s': %w", ErrImport, p.ID, err)
+	}
+	ss["connection"]["uuid"] = old["connection"]["uuid"]
+	p.UUID, _ = old["connection"]["uuid"].(string)
+	if err := target.Update(ss); err != nil {
+		return p, "", fmt.Errorf("%w: '%s': %w", ErrImport, p.ID, err)
+	}
+	return p, ImportOverwritten, nil
+}
+
+// importTarget returns the profile an imported profile p overwrites,
+// i.e. the profile with p's UUID or else the profile with p's SSID, or
+// nil if there is none.  importTarget fails with ErrDuplicateProfile
+// if there is a target but given overwrite flag isn't set.
+func (c *Client) importTarget(
+	p Profile, overwrite bool,
+) (nm.Connection, error) {
+	cc, pp, err := c.profileConnections(ProfileSelector{UUID: p.UUID})
+	if err != nil {
+		return nil, err
+	}
+	if len(cc) == 0 {
+		cc, pp, err = c.profileConnections(ProfileSelector{SSID: p.SSID})
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case len(cc) == 0:
+		return nil, nil
+	case !overwrite && pp[0].UUID == p.UUID:
+		return nil, fmt.Errorf("%w: UUID '%s' exists as '%s'",
+			ErrDuplicateProfile, p.UUID, pp[0].ID)
+	case !overwrite:
+		return nil, fmt.Errorf("%w: SSID '%s' is configured by '%s'",
+			ErrDuplicateProfile, p.SSID, pp[0].ID)
+	case len(cc) > 1:
+		return nil, fmt.Errorf("%w: SSID '%s' is configured %d times",
+			ErrAmbiguousProfile, p.SSID, len(cc))
+	}
+	return cc[0], nil
+}
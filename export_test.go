@@ -0,0 +1,137 @@
+package wifi
+
+import (
+	"testing"
+
+	"example.com/wifi/nmfake"
+	. "github.com/slukits/gounit"
+)
+
+type AProfileExport struct{ Suite }
+
+func (s *AProfileExport) SetUp(t *T) { t.Parallel() }
+
+func (s *AProfileExport) Includes_secrets_only_if_requested(t *T) {
+	client := mckFakeNM(&Client{}, nmfake.Default())
+	kf, err := client.ExportProfile(bg, ProfileSelector{SSID: "home"},
+		false)
+	t.FatalOn(err)
+	t.Eq("home", kf.Profile.SSID)
+	t.Contains(string(kf.Data), "\nssid=home\n")
+	t.Not.Contains(string(kf.Data), "home-secret")
+	kf, err = client.ExportProfile(bg, ProfileSelector{SSID: "home"},
+		true)
+	t.FatalOn(err)
+	t.Contains(string(kf.Data), "psk=home-secret\n")
+}
+
+func (s *AProfileExport) Fails_if_no_profile_is_selected(t *T) {
+	client := mckFakeNM(&Client{}, nmfake.Default())
+	_, err := client.ExportProfile(bg, ProfileSelector{SSID: "cafe"},
+		false)
+	t.ErrIs(err, ErrExport)
+	t.ErrIs(err, ErrNoProfile)
+	_, err = client.ExportProfiles(bg, ProfileSelector{SSID: "cafe"},
+		false)
+	t.ErrIs(err, ErrNoProfile)
+}
+
+func (s *AProfileExport) Exports_all_selected_profiles(t *T) {
+	fake := nmfake.Default()
+	fake.AddProfile(nmfake.WifiSettings("office", "wpa-psk", "o-pwd"))
+	kk, err := mckFakeNM(&Client{}, fake).ExportProfiles(bg,
+		ProfileSelector{}, true)
+	t.FatalOn(err)
+	t.Eq(2, len(kk))
+	t.Eq("office", kk[1].Profile.ID)
+	t.Contains(string(kk[1].Data), "psk=o-pwd\n")
+}
+
+func (s *AProfileExport) Is_imported_as_new_profile(t *T) {
+	fake := nmfake.Default()
+	client := mckFakeNM(&Client{}, fake)
+	kf, err := client.ExportProfile(bg, ProfileSelector{SSID: "home"},
+		true)
+	t.FatalOn(err)
+	t.FatalOn(client.DeleteProfile(bg, ProfileSelector{SSID: "home"}))
+	ss, err := UnmarshalKeyfile(kf.Data)
+	t.FatalOn(err)
+	p, action, err := client.ImportProfile(bg, ss, ImportOptions{})
+	t.FatalOn(err)
+	t.Eq(ImportAdded, action)
+	t.Eq(kf.Profile.UUID, p.UUID)
+	t.Eq(1, len(fake.Profiles()))
+	ss = fake.Profiles()[0].Settings()
+	t.Eq("home-secret", ss[wirelessSecurity]["psk"])
+}
+
+func (s *AProfileExport) Import_fails_on_duplicates(t *T) {
+	fake := nmfake.Default()
+	client := mckFakeNM(&Client{}, fake)
+	ss, err := UnmarshalKeyfile([]byte(
+		"[connection]\nid=home\nuuid=fake-home\ntype=wifi\n" +
+			"[wifi]\nssid=home\n"))
+	t.FatalOn(err)
+	_, _, err = client.ImportProfile(bg, ss, ImportOptions{})
+	t.ErrIs(err, ErrImport)
+	t.ErrIs(err, ErrDuplicateProfile)
+	ss["connection"]["uuid"] = "other"
+	_, _, err = client.ImportProfile(bg, ss, ImportOptions{})
+	t.ErrIs(err, ErrDuplicateProfile)
+	t.Contains(err.Error(), "SSID 'home'")
+	t.Eq(1, len(fake.Profiles()))
+}
+
+func (s *AProfileExport) Import_overwrites_a_duplicate(t *T) {
+	fake := nmfake.Default()
+	client := mckFakeNM(&Client{}, fake)
+	ss, err := UnmarshalKeyfile([]byte(
+		"[connection]\nid=home-new\ntype=wifi\n" +
+			"[wifi]\nssid=home\n[wifi-security]\nkey-mgmt=wpa-psk\n" +
+			"psk=new-secret\n"))
+	t.FatalOn(err)
+	p, action, err := client.ImportProfile(bg, ss,
+		ImportOptions{Overwrite: true})
+	t.FatalOn(err)
+	t.Eq(ImportOverwritten, action)
+	t.Eq("home-new", p.ID)
+	t.Eq(1, len(fake.Profiles()))
+	ss = fake.Profiles()[0].Settings()
+	t.Eq(p.UUID, ss["connection"]["uuid"])
+	t.Eq("new-secret", ss[wirelessSecurity]["psk"])
+}
+
+func (s *AProfileExport) Import_renames_a_duplicate(t *T) {
+	fake := nmfake.Default()
+	client := mckFakeNM(&Client{}, fake)
+	ss, err := UnmarshalKeyfile([]byte(
+		"[connection]\nid=home\nuuid=fake-home\ntype=wifi\n" +
+			"[wifi]\nssid=home\n"))
+	t.FatalOn(err)
+	p, action, err := client.ImportProfile(bg, ss,
+		ImportOptions{Rename: "home-2"})
+	t.FatalOn(err)
+	t.Eq(ImportRenamed, action)
+	t.Eq("home-2", p.ID)
+	t.True(p.UUID != "fake-home")
+	t.Eq(2, len(fake.Profiles()))
+}
+
+func (s *AProfileExport) Import_fails_for_non_wifi_profiles(t *T) {
+	client := mckFakeNM(&Client{}, nmfake.Default())
+	ss, err := UnmarshalKeyfile([]byte(
+		"[connection]\nid=lan\ntype=ethernet\n"))
+	t.FatalOn(err)
+	_, _, err = client.ImportProfile(bg, ss, ImportOptions{})
+	t.ErrIs(err, ErrImport)
+	ss, err = UnmarshalKeyfile([]byte(
+		"[connection]\nid=lab\ntype=wifi\n"))
+	t.FatalOn(err)
+	_, _, err = client.ImportProfile(bg, ss, ImportOptions{})
+	t.ErrIs(err, ErrImport)
+}
+
+func TestAProfileExport(t *testing.T) {
+	t.Parallel()
+	Run(&AProfileExport{}, t)
+}
@@ -6,6 +6,7 @@ require (
 	github.com/Wifx/gonetworkmanager/v2 v2.1.0
 	github.com/godbus/dbus/v5 v5.1.0
 	github.com/google/uuid v1.3.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/slukits/gounit v0.8.2
 	golang.org/x/term v0.4.0
 )
@@ -0,0 +1,379 @@
+package wifi
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/google/uuid"
+)
+
+// HotspotConfig configures the access point a wifi adapter provides as
+// hotspot.  The zero value is a WPA2 hotspot on a band and channel
+// NetworkManager chooses with an SSID derived from the adapter's name
+// and a random password.
+type HotspotConfig struct {
+	SSID string
+
+	// Password defaults to a random password of HotspotPasswordLength
+	// characters.
+	Password string
+
+	// Security is either SecurityWPAPSK, i.e. WPA2, or SecuritySAE,
+	// i.e. WPA3; it defaults to SecurityWPAPSK.
+	Security Security
+
+	// Band is either "bg", i.e. 2.4 GHz, or "a", i.e. 5 GHz.
+	Band string
+
+	// Channel needs a band.
+	Channel uint32
+}
+
+// Hotspot describes the hotspot of a wifi adapter.
+type Hotspot struct {
+	Adapter  string   `json:"adapter"`
+	Active   bool     `json:"active"`
+	UUID     string   `json:"uuid,omitempty"`
+	SSID     string   `json:"ssid,omitempty"`
+	Security Security `json:"security,omitempty"`
+	Password string   `json:"password,omitempty"`
+	Band     string   `json:"band,omitempty"`
+	Channel  uint32   `json:"channel,omitempty"`
+}
+
+// HotspotID is the id of the connection profiles of hotspots.
+const HotspotID = "wifi-hotspot"
+
+// HotspotPasswordLength is the length of a random hotspot password.
+const HotspotPasswordLength = 16
+
+// passwordRunes are the characters of a random hotspot password.
+const passwordRunes = "abcdefghijkmnopqrstuvwxyz" +
+	"ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var ErrHotspot = errors.New("adapter: hotspot")
+var ErrHotspotConfig = errors.New("invalid hotspot settings")
+var ErrHotspotActive = errors.New("hotspot is active")
+var ErrNoHotspot = errors.New("no hotspot")
+
+// Validate fails with ErrHotspotConfig if given configuration c has an
+// SSID longer than 32 bytes, a password which isn't 8 to 63 characters
+// long, an unsupported security, an unknown band or a channel without
+// or outside its band.
+func (c *HotspotConfig) Validate() error {
+	if len(c.SSID) > 32 {
+		return fmt.Errorf("%w: SSID longer than 32 bytes: '%s'",
+			ErrHotspotConfig, c.SSID)
+	}
+	if n := len([]rune(c.Password)); n < 8 || n > 63 {
+		return fmt.Errorf("%w: password needs 8 to 63 characters",
+			ErrHotspotConfig)
+	}
+	if c.Security != SecurityWPAPSK && c.Security != SecuritySAE {
+		return fmt.Errorf("%w: unsupported security '%s'",
+			ErrHotspotConfig, c.Security)
+	}
+	switch c.Band {
+	case "":
+		if c.Channel != 0 {
+			return fmt.Errorf("%w: channel %d without band",
+				ErrHotspotConfig, c.Channel)
+		}
+	case "bg":
+		if c.Channel > 14 {
+			return fmt.Errorf("%w: channel %d outside band %s",
+				ErrHotspotConfig, c.Channel, c.Band)
+		}
+	case "a":
+		if c.Channel != 0 && (c.Channel < 32 || c.Channel > 196) {
+			return fmt.Errorf("%w: channel %d outside band %s",
+				ErrHotspotConfig, c.Channel, c.Band)
+		}
+	default:
+		return fmt.Errorf("%w: unknown band '%s'",
+			ErrHotspotConfig, c.Band)
+	}
+	return nil
+}
+
+// StartHotspot lets given wifi adapter a provide an access point
+// configured by given configuration cfg which shares a's host's
+// connectivity.  StartHotspot returns once the hotspot is activated;
+// its profile is added unsaved and deleted if the activation fails.
+// Inactive profiles of earlier hotspots of a are deleted.  StartHotspot
+// fails with ErrHotspotActive if a's hotspot is active already.
+func (a *WifiAdapter) StartHotspot(
+	ctx context.Context, cfg HotspotConfig,
+) (_ Hotspot, err error) {
+	h, err := a.HotspotStatus(ctx)
+	if err != nil {
+		return h, err
+	}
+	if h.Active {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, ErrHotspotActive)
+	}
+	if cfg.SSID == "" {
+		cfg.SSID = "hotspot-" + a.name
+	}
+	if cfg.Security == "" {
+		cfg.Security = SecurityWPAPSK
+	}
+	if cfg.Password == "" {
+		if cfg.Password, err = randomPassword(); err != nil {
+			return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	if err := a.deleteHotspots(); err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	c, dfr, err := a.setupSignalMatcher()
+	if err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	defer func() { err = dfr(err, ErrHotspot) }()
+	ss, err := a.client.lib().NewSettings()
+	if err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	settings := hotspotSettings(a.name, cfg)
+	cnn, err := ss.AddConnectionUnsaved(settings)
+	if err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	pending := &activation{created: cnn}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if errors.As(err, new(*StateError)) {
+			pending.active = nil // the activation has ended already
+		}
+		if e := a.rollback(pending); e != nil {
+			err = fmt.Errorf("%w: %w", err, e)
+		}
+	}()
+	if err := a.activate(cnn, nil, pending); err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	err = a.waitForStateChange(ctx, c, nm.NmDeviceStateActivated)
+	if err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	return Hotspot{Adapter: a.name, Active: true,
+		UUID: settings["connection"]["uuid"].(string), SSID: cfg.SSID,
+		Security: cfg.Security, Password: cfg.Password, Band: cfg.Band,
+		Channel: cfg.Channel}, nil
+}
+
+// StopHotspot deactivates given wifi adapter a's active hotspot and
+// deletes the profiles of a's hotspots.  StopHotspot fails with
+// ErrNoHotspot if a has no hotspot profile.
+func (a *WifiAdapter) StopHotspot(ctx context.Context) (err error) {
+	cc, err := a.hotspotProfiles()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	if len(cc) == 0 {
+		return fmt.Errorf("%w: %w", ErrHotspot, ErrNoHotspot)
+	}
+	active, err := a.activeHotspot()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	if active != nil {
+		c, dfr, err := a.setupSignalMatcher()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrHotspot, err)
+		}
+		defer func() { err = dfr(err, ErrHotspot) }()
+		if err := a.lib().Disconnect(); err != nil {
+			return fmt.Errorf("%w: %w", ErrHotspot, err)
+		}
+		err = a.waitForStateChange(ctx, c, nm.NmDeviceStateDisconnected)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrHotspot, err)
+		}
+	}
+	if err := a.deleteHotspots(); err != nil {
+		return fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	return nil
+}
+
+// HotspotStatus describes given wifi adapter a's active hotspot or its
+// inactive hotspot profile.  The returned hotspot has only its adapter
+// set if a has neither.
+func (a *WifiAdapter) HotspotStatus(ctx context.Context) (Hotspot, error) {
+	h := Hotspot{Adapter: a.name}
+	if err := ctx.Err(); err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	cnn, err := a.activeHotspot()
+	if err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	h.Active = cnn != nil
+	if cnn == nil {
+		cc, err := a.hotspotProfiles()
+		if err != nil {
+			return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+		}
+		if len(cc) == 0 {
+			return h, nil
+		}
+		cnn = cc[0]
+	}
+	if err := hotspotOf(cnn, &h); err != nil {
+		return h, fmt.Errorf("%w: %w", ErrHotspot, err)
+	}
+	return h, nil
+}
+
+// activeHotspot returns the profile of given wifi adapter a's active
+// connection if it is in access point mode; otherwise nil.
+func (a *WifiAdapter) activeHotspot() (nm.Connection, error) {
+	active, err := a.dev.GetPropertyActiveConnection()
+	if err != nil || active == nil {
+		return nil, err
+	}
+	cnn, err := active.GetPropertyConnection()
+	if err != nil {
+		return nil, err
+	}
+	ss, err := cnn.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	if ss[wirelessSettings]["mode"] != "ap" {
+		return nil, nil
+	}
+	return cnn, nil
+}
+
+// hotspotProfiles returns the hotspot profiles bound to given wifi
+// adapter a.
+func (a *WifiAdapter) hotspotProfiles() ([]nm.Connection, error) {
+	ss, err := a.client.lib().NewSettings()
+	if err != nil {
+		return nil, err
+	}
+	cc, err := ss.ListConnections()
+	if err != nil {
+		return nil, err
+	}
+	hh := []nm.Connection{}
+	for _, c := range cc {
+		settings, err := c.GetSettings()
+		if err != nil {
+			return nil, err
+		}
+		if settings["connection"]["id"] != HotspotID ||
+			settings["connection"]["interface-name"] != a.name ||
+			settings[wirelessSettings]["mode"] != "ap" {
+			continue
+		}
+		hh = append(hh, c)
+	}
+	return hh, nil
+}
+
+// deleteHotspots deletes given wifi adapter a's hotspot profiles.
+func (a *WifiAdapter) deleteHotspots() error {
+	cc, err := a.hotspotProfiles()
+	if err != nil {
+		return err
+	}
+	for _, c := range cc {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hotspotOf describes given hotspot profile cnn in given hotspot h.
+func hotspotOf(cnn nm.Connection, h *Hotspot) error {
+	ss, err := cnn.GetSettings()
+	if err != nil {
+		return err
+	}
+	h.UUID, _ = ss["connection"]["uuid"].(string)
+	ssid, _ := ss[wirelessSettings]["ssid"].([]byte)
+	h.SSID = string(ssid)
+	h.Band, _ = ss[wirelessSettings]["band"].(string)
+	h.Channel, _ = ss[wirelessSettings]["channel"].(uint32)
+	h.Security = SecurityWPAPSK
+	if ss[wirelessSecurity]["key-mgmt"] == "sae" {
+		h.Security = SecuritySAE
+	}
+	secrets, err := cnn.GetSecrets(wirelessSecurity)
+	if err != nil {
+		return err
+	}
+	h.Password, _ = secrets[wirelessSecurity]["psk"].(string)
+	return nil
+}
+
+// hotspotSettings returns the settings of a hotspot profile for the
+// wifi adapter with given name according to given valid configuration
+// cfg.
+func hotspotSettings(
+	adapter string, cfg HotspotConfig,
+) nm.ConnectionSettings {
+	wireless := map[string]interface{}{
+		"ssid": []byte(cfg.SSID),
+		"mode": "ap",
+	}
+	if cfg.Band != "" {
+		wireless["band"] = cfg.Band
+	}
+	if cfg.Channel != 0 {
+		wireless["channel"] = cfg.Channel
+	}
+	security := map[string]interface{}{
+		"key-mgmt": "wpa-psk",
+		"proto":    []string{"rsn"},
+		"pairwise": []string{"ccmp"},
+		"group":    []string{"ccmp"},
+		"psk":      cfg.Password,
+	}
+	if cfg.Security == SecuritySAE {
+		security["key-mgmt"] = "sae"
+	}
+	wireless["security"] = wirelessSecurity
+	return nm.ConnectionSettings{
+		"connection": map[string]interface{}{
+			"type":           wirelessSettings,
+			"uuid":           uuid.New().String(),
+			"id":             HotspotID,
+			"interface-name": adapter,
+			"autoconnect":    false,
+		},
+		wirelessSettings: wireless,
+		wirelessSecurity: security,
+		"ipv4":           map[string]interface{}{"method": "shared"},
+		"ipv6":           map[string]interface{}{"method": "ignore"},
+	}
+}
+
+// randomPassword returns a random password of HotspotPasswordLength
+// characters.
+func randomPassword() (string, error) {
+	pwd := make([]byte, HotspotPasswordLength)
+	max := big.NewInt(int64(len(passwordRunes)))
+	for i := range pwd {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		pwd[i] = passwordRunes[n.Int64()]
+	}
+	return string(pwd), nil
+}
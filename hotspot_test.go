@@ -0,0 +1,137 @@
+package wifi
+
+import (
+	"testing"
+
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	. "github.com/slukits/gounit"
+)
+
+type AHotspot struct{ Suite }
+
+func (s *AHotspot) SetUp(t *T) { t.Parallel() }
+
+func (s *AHotspot) Rejects_invalid_settings(t *T) {
+	for _, c := range []HotspotConfig{
+		{Password: "short", Security: SecurityWPAPSK},
+		{Password: "long-enough", Security: SecurityWEP},
+		{Password: "long-enough", Security: SecurityWPAPSK,
+			SSID: "a-hotspot-ssid-longer-than-32-bytes"},
+		{Password: "long-enough", Security: SecurityWPAPSK, Band: "ac"},
+		{Password: "long-enough", Security: SecurityWPAPSK, Channel: 6},
+		{Password: "long-enough", Security: SecurityWPAPSK, Band: "bg",
+			Channel: 36},
+		{Password: "long-enough", Security: SecurityWPAPSK, Band: "a",
+			Channel: 6},
+	} {
+		t.ErrIs(c.Validate(), ErrHotspotConfig)
+	}
+	t.FatalOn((&HotspotConfig{Password: "long-enough",
+		Security: SecuritySAE, Band: "a", Channel: 36}).Validate())
+}
+
+func (s *AHotspot) Configures_a_shared_access_point(t *T) {
+	ss := hotspotSettings("wlan0", HotspotConfig{SSID: "spot",
+		Password: "spot-secret", Security: SecuritySAE, Band: "bg",
+		Channel: 6})
+	t.Eq(HotspotID, ss["connection"]["id"])
+	t.Eq("wlan0", ss["connection"]["interface-name"])
+	t.Eq(false, ss["connection"]["autoconnect"])
+	t.Eq(map[string]interface{}{"ssid": []byte("spot"), "mode": "ap",
+		"band": "bg", "channel": uint32(6),
+		"security": wirelessSecurity}, ss[wirelessSettings])
+	t.Eq("sae", ss[wirelessSecurity]["key-mgmt"])
+	t.Eq("spot-secret", ss[wirelessSecurity]["psk"])
+	t.Eq(map[string]interface{}{"method": "shared"}, ss["ipv4"])
+}
+
+func (s *AHotspot) Starts_with_a_random_password_by_default(t *T) {
+	fake := nmfake.Default()
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	h, err := adapter.StartHotspot(bg, HotspotConfig{})
+	t.FatalOn(err)
+	t.True(h.Active)
+	t.Eq("hotspot-wlan0", h.SSID)
+	t.Eq(SecurityWPAPSK, h.Security)
+	t.Eq(HotspotPasswordLength, len(h.Password))
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	t.Eq(h.Password, pp[1].Settings()[wirelessSecurity]["psk"])
+	t.Eq(nm.NmDeviceStateActivated, fake.Device("wlan0").State)
+	t.FatalOn(adapter.StopHotspot(bg))
+	other, err := adapter.StartHotspot(bg, HotspotConfig{})
+	t.FatalOn(err)
+	t.Not.Eq(h.Password, other.Password)
+}
+
+func (s *AHotspot) Fails_to_start_if_active(t *T) {
+	fake := nmfake.Default()
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	_, err = adapter.StartHotspot(bg, HotspotConfig{
+		Password: "spot-secret"})
+	t.FatalOn(err)
+	_, err = adapter.StartHotspot(bg, HotspotConfig{})
+	t.ErrIs(err, ErrHotspot)
+	t.ErrIs(err, ErrHotspotActive)
+	t.Eq(2, len(fake.Profiles()))
+}
+
+func (s *AHotspot) Reports_its_status(t *T) {
+	fake := nmfake.Default()
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	h, err := adapter.HotspotStatus(bg)
+	t.FatalOn(err)
+	t.Eq(Hotspot{Adapter: "wlan0"}, h)
+	started, err := adapter.StartHotspot(bg, HotspotConfig{SSID: "spot",
+		Password: "spot-secret", Security: SecuritySAE, Band: "a",
+		Channel: 36})
+	t.FatalOn(err)
+	h, err = adapter.HotspotStatus(bg)
+	t.FatalOn(err)
+	t.Eq(started, h)
+}
+
+func (s *AHotspot) Is_torn_down_at_stop(t *T) {
+	fake := nmfake.Default()
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	t.ErrIs(adapter.StopHotspot(bg), ErrNoHotspot)
+	_, err = adapter.StartHotspot(bg, HotspotConfig{})
+	t.FatalOn(err)
+	t.FatalOn(adapter.StopHotspot(bg))
+	t.Eq(1, len(fake.Profiles()))
+	t.Eq(nm.NmDeviceStateDisconnected, fake.Device("wlan0").State)
+	h, err := adapter.HotspotStatus(bg)
+	t.FatalOn(err)
+	t.Not.True(h.Active)
+}
+
+func (s *AHotspot) Is_rolled_back_if_its_activation_fails(t *T) {
+	fake := nmfake.Default()
+	fake.Device("wlan0").Activate = func(
+		*nmfake.Device, *nmfake.Connection, *nmfake.AP,
+	) []nmfake.Transition {
+		return []nmfake.Transition{
+			{State: nm.NmDeviceStatePrepare},
+			{State: nm.NmDeviceStateFailed,
+				Reason: nm.NmDeviceStateReasonSupplicantFailed},
+			{State: nm.NmDeviceStateDisconnected,
+				Reason: nm.NmDeviceStateReasonSupplicantFailed},
+		}
+	}
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	_, err = adapter.StartHotspot(bg, HotspotConfig{})
+	t.ErrIs(err, ErrHotspot)
+	t.ErrIs(err, ErrActivationFailed)
+	t.Eq(1, len(fake.Profiles()))
+}
+
+func TestAHotspot(t *testing.T) {
+	t.Parallel()
+	Run(&AHotspot{}, t)
+}
@@ -0,0 +1,237 @@
+package wifi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// signalRule selects the signals of an object path and interface; the
+// zero member selects all signals of the interface.
+type signalRule struct {
+	path   dbus.ObjectPath
+	iface  string
+	member string
+}
+
+// options returns the match options of given rule r.
+func (r signalRule) options() []dbus.MatchOption {
+	oo := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(r.path),
+		dbus.WithMatchInterface(r.iface),
+	}
+	if r.member != "" {
+		oo = append(oo, dbus.WithMatchMember(r.member))
+	}
+	return oo
+}
+
+// matches returns true if given signal s is selected by given rule r.
+func (r signalRule) matches(s *dbus.Signal) bool {
+	if s.Path != r.path {
+		return false
+	}
+	i := strings.LastIndex(s.Name, ".")
+	if i < 0 || s.Name[:i] != r.iface {
+		return false
+	}
+	return r.member == "" || s.Name[i+1:] == r.member
+}
+
+// subscription is a channel receiving the signals matching its rules.
+type subscription struct {
+	c     chan *dbus.Signal
+	rules []signalRule
+}
+
+func (s *subscription) matches(signal *dbus.Signal) bool {
+	for _, r := range s.rules {
+		if r.matches(signal) {
+			return true
+		}
+	}
+	return false
+}
+
+// signalHub owns a single bus connection of a client and dispatches the
+// received signals to its subscriptions by object path and interface.
+// The connection is established with the first subscription and kept
+// until the hub is closed; a match rule is installed on the connection
+// as long as a subscription needs it.  The zero value is ready to use.
+type signalHub struct {
+	mutex sync.Mutex
+	cnn   BusConnection
+	c     chan *dbus.Signal
+	quit  chan struct{}
+	done  chan struct{}
+	subs  map[*subscription]bool
+
+	// rules counts the subscriptions of an installed match rule.
+	rules map[signalRule]int
+}
+
+var ErrHub = errors.New("signal hub")
+
+// ErrHubClosed is reported by operations whose subscription ended
+// because the hub was closed.
+var ErrHubClosed = errors.New("signal hub: closed")
+
+// subscribe returns a channel receiving the signals matching given rules
+// and a function ending the subscription.  If given hub h has no bus
+// connection it is established by given function connect.
+func (h *signalHub) subscribe(
+	connect func() (BusConnection, error), rules ...signalRule,
+) (chan *dbus.Signal, func() error, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if err := h.connect(connect); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrHub, err)
+	}
+	added := []signalRule{}
+	for _, r := range rules {
+		if h.rules[r] > 0 {
+			h.rules[r]++
+			added = append(added, r)
+			continue
+		}
+		if err := h.cnn.AddMatchSignal(r.options()...); err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrHub,
+				errors.Join(append([]error{err},
+					h.removeRules(added)...)...))
+		}
+		h.rules[r] = 1
+		added = append(added, r)
+	}
+	s := &subscription{c: make(chan *dbus.Signal, 100), rules: rules}
+	h.subs[s] = true
+	return s.c, func() error { return h.unsubscribe(s) }, nil
+}
+
+// connect establishes given hub h's bus connection by given function
+// connect and starts the dispatch of its signals if h is not connected.
+// NOTE connect expects h's mutex to be locked.
+func (h *signalHub) connect(connect func() (BusConnection, error)) error {
+	if h.cnn != nil {
+		return nil
+	}
+	cnn, err := connect()
+	if err != nil {
+		return err
+	}
+	h.cnn, h.rules = cnn, map[signalRule]int{}
+	h.c = make(chan *dbus.Signal, 100)
+	h.quit, h.done = make(chan struct{}), make(chan struct{})
+	if h.subs == nil {
+		h.subs = map[*subscription]bool{}
+	}
+	h.cnn.Signal(h.c)
+	go h.dispatch(h.c, h.quit, h.done)
+	return nil
+}
+
+// dispatch passes the signals of given channel c to the subscriptions
+// they match until quit is closed; then done is closed.  A signal is
+// dropped for a subscription whose channel is full.  If c is closed,
+// i.e. the bus connection was terminated, the subscriptions end and the
+// next subscription reconnects.
+func (h *signalHub) dispatch(
+	c chan *dbus.Signal, quit, done chan struct{},
+) {
+	defer close(done)
+	for {
+		select {
+		case <-quit:
+			return
+		case signal, ok := <-c:
+			h.mutex.Lock()
+			if !ok {
+				if h.c == c {
+					h.cnn, h.c, h.quit, h.done = nil, nil, nil, nil
+					h.rules = nil
+					h.endSubscriptions()
+				}
+				h.mutex.Unlock()
+				return
+			}
+			for s := range h.subs {
+				if !s.matches(signal) {
+					continue
+				}
+				select {
+				case s.c <- signal:
+				default:
+				}
+			}
+			h.mutex.Unlock()
+		}
+	}
+}
+
+// endSubscriptions removes all subscriptions of given hub h closing
+// their channels.  NOTE endSubscriptions expects h's mutex to be
+// locked.
+func (h *signalHub) endSubscriptions() {
+	for s := range h.subs {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+// unsubscribe removes given subscription s from given hub h, closes its
+// channel and removes the match rules no other subscription needs.
+func (h *signalHub) unsubscribe(s *subscription) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.subs[s] {
+		return nil
+	}
+	delete(h.subs, s)
+	close(s.c)
+	if h.cnn == nil {
+		return nil
+	}
+	if err := errors.Join(h.removeRules(s.rules)...); err != nil {
+		return fmt.Errorf("%w: %w", ErrHub, err)
+	}
+	return nil
+}
+
+// removeRules decrements the subscription counts of given match rules
+// and removes the rules which are not needed anymore from given hub h's
+// connection.  NOTE removeRules expects h's mutex to be locked.
+func (h *signalHub) removeRules(rules []signalRule) (errs []error) {
+	for _, r := range rules {
+		if h.rules[r]--; h.rules[r] > 0 {
+			continue
+		}
+		delete(h.rules, r)
+		if err := h.cnn.RemoveMatchSignal(r.options()...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// close ends the dispatch of given hub h and closes its bus connection.
+// Pending subscriptions end as if unsubscribed.
+func (h *signalHub) close() error {
+	h.mutex.Lock()
+	if h.cnn == nil {
+		h.mutex.Unlock()
+		return nil
+	}
+	cnn, c, quit, done := h.cnn, h.c, h.quit, h.done
+	h.cnn, h.c, h.quit, h.done, h.rules = nil, nil, nil, nil, nil
+	h.endSubscriptions()
+	h.mutex.Unlock()
+	cnn.RemoveSignal(c)
+	close(quit)
+	<-done
+	if err := cnn.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrHub, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package wifi
+
+import (
+	"testing"
+
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+	. "github.com/slukits/gounit"
+)
+
+type AHub struct{ Suite }
+
+func (s *AHub) SetUp(t *T) { t.Parallel() }
+
+// countingBus wraps a bus connection counting its installed match
+// rules.
+type countingBus struct {
+	BusConnection
+	rules int
+}
+
+func (b *countingBus) AddMatchSignal(oo ...dbus.MatchOption) error {
+	b.rules++
+	return b.BusConnection.AddMatchSignal(oo...)
+}
+
+func (b *countingBus) RemoveMatchSignal(oo ...dbus.MatchOption) error {
+	b.rules--
+	return b.BusConnection.RemoveMatchSignal(oo...)
+}
+
+// countingClient returns a client of given fake network manager whose
+// bus connections are recorded in given slice of counting buses.
+func countingClient(fake *nmfake.NM, bb *[]*countingBus) *Client {
+	client := mckFakeNM(&Client{}, fake)
+	client.Lib.SystemBus = func() (BusConnection, error) {
+		cnn, err := fake.SystemBus()
+		*bb = append(*bb, &countingBus{BusConnection: cnn})
+		return (*bb)[len(*bb)-1], err
+	}
+	return client
+}
+
+func (s *AHub) Shares_one_bus_connection_between_operations(t *T) {
+	bb := []*countingBus{}
+	client := countingClient(nmfake.Default(), &bb)
+	adapter, err := client.Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	_, err = adapter.Scan(bg)
+	t.FatalOn(err)
+	t.FatalOn(adapter.Connect(bg, "cafe"))
+	t.FatalOn(adapter.Disconnect(bg))
+	t.FatalIfNot(t.Eq(1, len(bb)))
+	t.Eq(0, bb[0].rules)
+	t.FatalOn(client.Close())
+}
+
+func (s *AHub) Dispatches_signals_by_path_and_interface(t *T) {
+	fake, bb := nmfake.Default(), []*countingBus{}
+	client := countingClient(fake, &bb)
+	wlan := fake.Device("wlan0")
+	device, unsubscribe, err := client.hub.subscribe(
+		client.lib().SystemBus, signalRule{path: wlan.GetPath(),
+			iface: DBusProperties, member: PropertiesChanged})
+	t.FatalOn(err)
+	defer unsubscribe()
+	root, unsubscribeRoot, err := client.hub.subscribe(
+		client.lib().SystemBus, signalRule{
+			path: nm.NetworkManagerObjectPath, iface: DBusProperties})
+	t.FatalOn(err)
+	defer unsubscribeRoot()
+	t.Eq(2, bb[0].rules)
+	t.FatalOn(wlan.RequestScan())
+	fake.SetConnectivity(nm.NmConnectivityFull)
+	t.Eq(wlan.GetPath(), (<-device).Path)
+	t.Eq(dbus.ObjectPath(nm.NetworkManagerObjectPath), (<-root).Path)
+	t.Eq(0, len(device))
+	t.Eq(0, len(root))
+}
+
+func (s *AHub) Ends_subscriptions_and_reconnects_once_closed(t *T) {
+	bb := []*countingBus{}
+	client := countingClient(nmfake.Default(), &bb)
+	c, unsubscribe, err := client.hub.subscribe(
+		client.lib().SystemBus, signalRule{
+			path: nm.NetworkManagerObjectPath, iface: DBusProperties})
+	t.FatalOn(err)
+	t.FatalOn(client.Close())
+	_, ok := <-c
+	t.Not.True(ok)
+	t.FatalOn(unsubscribe())
+	adapter, err := client.Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	_, err = adapter.Scan(bg)
+	t.FatalOn(err)
+	t.Eq(2, len(bb))
+}
+
+func (s *AHub) Fails_closing_on_connection_close_failure(t *T) {
+	adapter := mckAdapterCnnCloseFailure(t)
+	_, err := adapter.Scan(bg)
+	t.FatalOn(err)
+	err = adapter.client.Close()
+	t.ErrIs(err, ErrHub)
+	t.ErrIs(err, ErrMckAdapterCnnCloseFailure)
+}
+
+func TestAHub(t *testing.T) {
+	t.Parallel()
+	Run(&AHub{}, t)
+}
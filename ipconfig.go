@@ -0,0 +1,262 @@
+package wifi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"unsafe"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+)
+
+// IPConfig describes the addressing, DNS and proxy settings of new
+// configuration settings.  The zero value keeps NetworkManager's
+// automatic configuration.
+type IPConfig struct {
+	IPv4  IPSettings `json:"ipv4"`
+	IPv6  IPSettings `json:"ipv6"`
+	Proxy Proxy      `json:"proxy"`
+}
+
+// IPSettings describes the settings of one IP family.  An address
+// switches the family's configuration from automatic to manual.
+type IPSettings struct {
+
+	// Addresses are static addresses with their prefix length, e.g.
+	// "192.168.1.10/24".
+	Addresses []string `json:"addresses,omitempty"`
+
+	// Gateway is the default gateway of static addresses.
+	Gateway string `json:"gateway,omitempty"`
+
+	// DNS are the addresses of name servers.
+	DNS []string `json:"dns,omitempty"`
+
+	// DNSSearch are the domains searched for host names.
+	DNSSearch []string `json:"dns_search,omitempty"`
+
+	// IgnoreAutoDNS lets automatically configured name servers and
+	// search domains be ignored.
+	IgnoreAutoDNS bool `json:"ignore_auto_dns,omitempty"`
+
+	// RouteMetric is the metric of the family's default route; nil
+	// leaves it to NetworkManager.
+	RouteMetric *int64 `json:"route_metric,omitempty"`
+}
+
+// ProxyMethod is the proxy configuration method of a connection.
+type ProxyMethod string
+
+const (
+	ProxyNone ProxyMethod = "none"
+	ProxyAuto ProxyMethod = "auto"
+)
+
+// Proxy describes the proxy settings of a connection.  The zero value
+// has no proxy.
+type Proxy struct {
+	Method ProxyMethod `json:"method,omitempty"`
+
+	// PACURL is the URL of the proxy auto-config script of the auto
+	// method; without it the script is discovered by WPAD.
+	PACURL string `json:"pac_url,omitempty"`
+}
+
+// NetworkManager's proxy methods.
+const (
+	nmProxyNone int32 = 0
+	nmProxyAuto int32 = 1
+)
+
+var ErrIPConfig = errors.New("invalid ip settings")
+
+// Validate fails with ErrIPConfig if an address, gateway or name server
+// of given IP configuration c is malformed or of the wrong family, if
+// a gateway lacks static addresses, if a route metric is below -1 or if
+// the proxy method or PAC URL is invalid.
+func (c *IPConfig) Validate() error {
+	if err := c.IPv4.validate("ipv4", netip.Addr.Is4); err != nil {
+		return err
+	}
+	if err := c.IPv6.validate("ipv6", func(a netip.Addr) bool {
+		return a.Is6() && !a.Is4In6()
+	}); err != nil {
+		return err
+	}
+	switch c.Proxy.Method {
+	case "", ProxyNone:
+		if c.Proxy.PACURL != "" {
+			return fmt.Errorf("%w: proxy: PAC URL needs method %s",
+				ErrIPConfig, ProxyAuto)
+		}
+	case ProxyAuto:
+		if c.Proxy.PACURL == "" {
+			return nil
+		}
+		u, err := url.Parse(c.Proxy.PACURL)
+		if err != nil {
+			return fmt.Errorf("%w: proxy: %w", ErrIPConfig, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" &&
+			u.Scheme != "file" {
+			return fmt.Errorf("%w: proxy: unsupported PAC URL '%s'",
+				ErrIPConfig, c.Proxy.PACURL)
+		}
+	default:
+		return fmt.Errorf("%w: proxy: unknown method '%s'",
+			ErrIPConfig, c.Proxy.Method)
+	}
+	return nil
+}
+
+// validate fails with ErrIPConfig if given IP settings s of the family
+// with given name are invalid; given function is tells if an address
+// is of that family.
+func (s *IPSettings) validate(
+	name string, is func(netip.Addr) bool,
+) error {
+	for _, a := range s.Addresses {
+		p, err := netip.ParsePrefix(a)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrIPConfig, name, err)
+		}
+		if !is(p.Addr()) {
+			return fmt.Errorf("%w: %s: address of other family: '%s'",
+				ErrIPConfig, name, a)
+		}
+	}
+	if s.Gateway != "" {
+		if len(s.Addresses) == 0 {
+			return fmt.Errorf("%w: %s: gateway without addresses",
+				ErrIPConfig, name)
+		}
+		if err := validAddr(s.Gateway, is); err != nil {
+			return fmt.Errorf("%w: %s: gateway: %w",
+				ErrIPConfig, name, err)
+		}
+	}
+	for _, a := range s.DNS {
+		if err := validAddr(a, is); err != nil {
+			return fmt.Errorf("%w: %s: dns: %w", ErrIPConfig, name, err)
+		}
+	}
+	if s.RouteMetric != nil && *s.RouteMetric < -1 {
+		return fmt.Errorf("%w: %s: route metric below -1: %d",
+			ErrIPConfig, name, *s.RouteMetric)
+	}
+	return nil
+}
+
+func validAddr(a string, is func(netip.Addr) bool) error {
+	addr, err := netip.ParseAddr(a)
+	if err != nil {
+		return err
+	}
+	if !is(addr) {
+		return fmt.Errorf("address of other family: '%s'", a)
+	}
+	return nil
+}
+
+// apply sets the ipv4, ipv6 and proxy sections of given connection
+// settings ss according to given valid IP configuration c.
+func (c *IPConfig) apply(ss nm.ConnectionSettings) {
+	ss["ipv4"] = c.IPv4.settings(dns4)
+	ss["ipv6"] = c.IPv6.settings(dns6)
+	proxy := map[string]interface{}{}
+	switch c.Proxy.Method {
+	case ProxyNone:
+		proxy["method"] = nmProxyNone
+	case ProxyAuto:
+		proxy["method"] = nmProxyAuto
+		if c.Proxy.PACURL != "" {
+			proxy["pac-url"] = c.Proxy.PACURL
+		}
+	}
+	ss["proxy"] = proxy
+}
+
+// dns4 returns given ipv4 name servers as NetworkManager expects them
+// (see nmIP4).
+func dns4(aa []string) interface{} {
+	servers := []uint32{}
+	for _, a := range aa {
+		servers = append(servers, nmIP4(netip.MustParseAddr(a)))
+	}
+	return servers
+}
+
+// hostOrder is the byte order of the host's uint32 values.
+var hostOrder = func() binary.ByteOrder {
+	u := uint32(1)
+	if *(*byte)(unsafe.Pointer(&u)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// nmIP4 returns given ipv4 address a as NetworkManager's uint32 whose
+// in-memory bytes are in network byte order, i.e. its value depends on
+// the host's byte order.
+func nmIP4(a netip.Addr) uint32 {
+	b := a.As4()
+	return hostOrder.Uint32(b[:])
+}
+
+// ip4OfNM returns the ipv4 address of given NetworkManager uint32 u
+// (see nmIP4).
+func ip4OfNM(u uint32) netip.Addr {
+	b := [4]byte{}
+	hostOrder.PutUint32(b[:], u)
+	return netip.AddrFrom4(b)
+}
+
+// dns6 returns given ipv6 name servers as NetworkManager expects them,
+// i.e. as byte arrays.
+func dns6(aa []string) interface{} {
+	servers := [][]byte{}
+	for _, a := range aa {
+		b := netip.MustParseAddr(a).As16()
+		servers = append(servers, b[:])
+	}
+	return servers
+}
+
+// settings returns the ipv4 or ipv6 section of given valid IP settings
+// s; given function dns converts the name servers to their
+// NetworkManager representation.
+func (s *IPSettings) settings(
+	dns func([]string) interface{},
+) map[string]interface{} {
+	ss := map[string]interface{}{"method": "auto"}
+	if len(s.Addresses) > 0 {
+		ss["method"] = "manual"
+		data := []map[string]interface{}{}
+		for _, a := range s.Addresses {
+			p := netip.MustParsePrefix(a)
+			data = append(data, map[string]interface{}{
+				"address": p.Addr().String(),
+				"prefix":  uint32(p.Bits()),
+			})
+		}
+		ss["address-data"] = data
+	}
+	if s.Gateway != "" {
+		ss["gateway"] = s.Gateway
+	}
+	if len(s.DNS) > 0 {
+		ss["dns"] = dns(s.DNS)
+	}
+	if len(s.DNSSearch) > 0 {
+		ss["dns-search"] = s.DNSSearch
+	}
+	if s.IgnoreAutoDNS {
+		ss["ignore-auto-dns"] = true
+	}
+	if s.RouteMetric != nil {
+		ss["route-metric"] = *s.RouteMetric
+	}
+	return ss
+}
@@ -0,0 +1,112 @@
+package wifi
+
+import (
+	"testing"
+	"unsafe"
+
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	. "github.com/slukits/gounit"
+)
+
+type AnIPConfig struct{ Suite }
+
+func (s *AnIPConfig) SetUp(t *T) { t.Parallel() }
+
+func (s *AnIPConfig) Keeps_automatic_configuration_by_default(t *T) {
+	c, ss := &IPConfig{}, nm.ConnectionSettings{}
+	t.FatalOn(c.Validate())
+	c.apply(ss)
+	t.Eq(map[string]interface{}{"method": "auto"}, ss["ipv4"])
+	t.Eq(map[string]interface{}{"method": "auto"}, ss["ipv6"])
+	t.Eq(map[string]interface{}{}, ss["proxy"])
+}
+
+func (s *AnIPConfig) Configures_static_addressing_and_dns(t *T) {
+	metric := int64(50)
+	c, ss := &IPConfig{
+		IPv4: IPSettings{Addresses: []string{"192.168.1.10/24"},
+			Gateway: "192.168.1.1", DNS: []string{"192.168.1.2"},
+			DNSSearch: []string{"lab.example"}, IgnoreAutoDNS: true,
+			RouteMetric: &metric},
+		IPv6: IPSettings{Addresses: []string{"fd00::10/64"},
+			DNS: []string{"fd00::2"}},
+	}, nm.ConnectionSettings{}
+	t.FatalOn(c.Validate())
+	c.apply(ss)
+	// NetworkManager expects the address bytes in network byte order
+	dns := uint32(0)
+	*(*[4]byte)(unsafe.Pointer(&dns)) = [4]byte{192, 168, 1, 2}
+	t.Eq(map[string]interface{}{
+		"method": "manual",
+		"address-data": []map[string]interface{}{
+			{"address": "192.168.1.10", "prefix": uint32(24)}},
+		"gateway":         "192.168.1.1",
+		"dns":             []uint32{dns},
+		"dns-search":      []string{"lab.example"},
+		"ignore-auto-dns": true,
+		"route-metric":    int64(50),
+	}, ss["ipv4"])
+	t.Eq("manual", ss["ipv6"]["method"])
+	t.Eq([][]byte{{0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}},
+		ss["ipv6"]["dns"])
+}
+
+func (s *AnIPConfig) Configures_an_auto_proxy(t *T) {
+	c, ss := &IPConfig{Proxy: Proxy{Method: ProxyAuto,
+		PACURL: "http://wpad.lab.example/wpad.dat"}},
+		nm.ConnectionSettings{}
+	t.FatalOn(c.Validate())
+	c.apply(ss)
+	t.Eq(map[string]interface{}{"method": int32(1),
+		"pac-url": "http://wpad.lab.example/wpad.dat"}, ss["proxy"])
+}
+
+func (s *AnIPConfig) Rejects_invalid_settings(t *T) {
+	metric := int64(-2)
+	for _, c := range []IPConfig{
+		{IPv4: IPSettings{Addresses: []string{"192.168.1.10"}}},
+		{IPv4: IPSettings{Addresses: []string{"fd00::10/64"}}},
+		{IPv6: IPSettings{Addresses: []string{"192.168.1.10/24"}}},
+		{IPv4: IPSettings{Gateway: "192.168.1.1"}},
+		{IPv4: IPSettings{DNS: []string{"fd00::2"}}},
+		{IPv6: IPSettings{DNS: []string{"dns.example"}}},
+		{IPv4: IPSettings{RouteMetric: &metric}},
+		{Proxy: Proxy{Method: "manual"}},
+		{Proxy: Proxy{PACURL: "http://wpad/wpad.dat"}},
+		{Proxy: Proxy{Method: ProxyAuto, PACURL: "ftp://wpad/wpad"}},
+	} {
+		t.ErrIs(c.Validate(), ErrIPConfig)
+	}
+}
+
+func (s *AnIPConfig) Is_validated_before_a_profile_is_added(t *T) {
+	fake := nmfake.Default()
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	err = adapter.ConnectWith(bg, ProfileSelector{SSID: "cafe"},
+		ConnectOptions{IP: &IPConfig{IPv4: IPSettings{
+			Gateway: "10.0.0.1"}}})
+	t.ErrIs(err, ErrAdapterConnect)
+	t.ErrIs(err, ErrIPConfig)
+	t.Eq(1, len(fake.Profiles()))
+}
+
+func (s *AnIPConfig) Configures_a_new_profile_at_connect(t *T) {
+	fake := nmfake.Default()
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	t.FatalOn(adapter.ConnectWith(bg, ProfileSelector{SSID: "cafe"},
+		ConnectOptions{IP: &IPConfig{IPv4: IPSettings{
+			Addresses: []string{"10.0.0.10/8"},
+			Gateway:   "10.0.0.1"}}}))
+	pp := fake.Profiles()
+	t.FatalIfNot(t.Eq(2, len(pp)))
+	t.Eq("manual", pp[1].Settings()["ipv4"]["method"])
+	t.Eq("10.0.0.1", pp[1].Settings()["ipv4"]["gateway"])
+}
+
+func TestAnIPConfig(t *testing.T) {
+	t.Parallel()
+	Run(&AnIPConfig{}, t)
+}
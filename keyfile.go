@@ -0,0 +1,646 @@
+package wifi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+// KeyfileExt is the file extension of NetworkManager's keyfiles.
+const KeyfileExt = ".nmconnection"
+
+// keyfileAliases maps setting names and connection types to the names
+// keyfiles use for them.
+var keyfileAliases = map[string]string{
+	wirelessSettings: "wifi",
+	wirelessSecurity: "wifi-security",
+	"802-3-ethernet": "ethernet",
+}
+
+// keyfileOrder are the settings written first in the given order; the
+// others follow sorted by name.
+var keyfileOrder = []string{"connection", wirelessSettings,
+	wirelessSecurity, enterpriseSettings, "ipv4", "ipv6", "proxy"}
+
+// keyfileSkipped are deprecated settings values NetworkManager reports
+// next to their replacements address-data and route-data.
+var keyfileSkipped = map[string]bool{"addresses": true, "routes": true}
+
+// keyfileKind is the D-Bus type of a setting value a keyfile stores as
+// string.
+type keyfileKind int
+
+const (
+	kfString keyfileKind = iota
+	kfBool
+	kfInt32
+	kfUint32
+	kfInt64
+	kfUint64
+	kfStrings
+
+	// kfSSID is a byte array written as string if possible.
+	kfSSID
+
+	// kfMAC is a byte array written as colon separated hex bytes.
+	kfMAC
+
+	// kfBlob is a certificate or key referenced by its path.
+	kfBlob
+
+	// kfDNS4 and kfDNS6 are name server addresses.
+	kfDNS4
+	kfDNS6
+)
+
+// keyfileKinds are the kinds of the settings values which are no
+// strings.  Values of other settings are imported as string.
+var keyfileKinds = map[string]map[string]keyfileKind{
+	"connection": {
+		"autoconnect": kfBool, "autoconnect-priority": kfInt32,
+		"autoconnect-retries": kfInt32, "auth-retries": kfInt32,
+		"timestamp": kfUint64, "read-only": kfBool,
+		"permissions": kfStrings, "secondaries": kfStrings,
+		"metered": kfInt32, "multi-connect": kfInt32,
+		"wait-device-timeout": kfInt32, "lldp": kfInt32,
+		"mdns": kfInt32, "llmnr": kfInt32, "dns-over-tls": kfInt32,
+		"autoconnect-slaves": kfInt32, "gateway-ping-timeout": kfUint32,
+	},
+	wirelessSettings: {
+		"ssid": kfSSID, "hidden": kfBool, "channel": kfUint32,
+		"mtu": kfUint32, "powersave": kfUint32, "rate": kfUint32,
+		"tx-power": kfUint32, "wake-on-wlan": kfUint32,
+		"mac-address-randomization": kfUint32, "bssid": kfMAC,
+		"mac-address": kfMAC, "seen-bssids": kfStrings,
+		"mac-address-blacklist": kfStrings,
+	},
+	wirelessSecurity: {
+		"proto": kfStrings, "pairwise": kfStrings, "group": kfStrings,
+		"wep-tx-keyidx": kfUint32, "wep-key-type": kfUint32,
+		"psk-flags": kfUint32, "wep-key-flags": kfUint32,
+		"leap-password-flags": kfUint32, "pmf": kfInt32,
+		"fils": kfInt32, "wps-method": kfUint32,
+	},
+	enterpriseSettings: {
+		"eap": kfStrings, "ca-cert": kfBlob, "client-cert": kfBlob,
+		"private-key": kfBlob, "phase2-ca-cert": kfBlob,
+		"phase2-client-cert": kfBlob, "phase2-private-key": kfBlob,
+		"password-flags": kfUint32, "system-ca-certs": kfBool,
+		"private-key-password-flags": kfUint32, "optional": kfBool,
+		"altsubject-matches": kfStrings, "auth-timeout": kfInt32,
+		"phase1-auth-flags": kfUint32,
+	},
+	"ipv4": {
+		"dns": kfDNS4, "dns-search": kfStrings, "dns-options": kfStrings,
+		"dns-priority": kfInt32, "ignore-auto-dns": kfBool,
+		"ignore-auto-routes": kfBool, "never-default": kfBool,
+		"may-fail": kfBool, "route-metric": kfInt64,
+		"route-table": kfUint32, "dhcp-timeout": kfInt32,
+		"dhcp-send-hostname": kfBool, "required-timeout": kfInt32,
+		"dad-timeout": kfInt32,
+	},
+	"ipv6": {
+		"dns": kfDNS6, "dns-search": kfStrings, "dns-options": kfStrings,
+		"dns-priority": kfInt32, "ignore-auto-dns": kfBool,
+		"ignore-auto-routes": kfBool, "never-default": kfBool,
+		"may-fail": kfBool, "route-metric": kfInt64,
+		"route-table": kfUint32, "dhcp-timeout": kfInt32,
+		"dhcp-send-hostname": kfBool, "required-timeout": kfInt32,
+		"ip6-privacy": kfInt32, "addr-gen-mode": kfInt32,
+		"ra-timeout": kfInt32, "mtu": kfUint32,
+	},
+	"proxy": {"method": kfInt32, "browser-only": kfBool},
+}
+
+// addrGenModes are the names keyfiles use for the ipv6 address
+// generation modes.
+var addrGenModes = []string{"eui64", "stable-privacy",
+	"default-or-eui64", "default"}
+
+var ErrKeyfile = errors.New("keyfile")
+
+// MarshalKeyfile returns given connection settings ss in
+// NetworkManager's keyfile format.  MarshalKeyfile fails with
+// ErrKeyfile if a value's type can't be stored in a keyfile.
+func MarshalKeyfile(ss nm.ConnectionSettings) ([]byte, error) {
+	names := []string{}
+	for _, name := range keyfileOrder {
+		if _, ok := ss[name]; ok {
+			names = append(names, name)
+		}
+	}
+	others := []string{}
+	for name := range ss {
+		if !contains(keyfileOrder, name) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	b := &bytes.Buffer{}
+	for i, name := range append(names, others...) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "[%s]\n", keyfileAlias(name))
+		if err := marshalSetting(b, name, ss[name]); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func keyfileAlias(name string) string {
+	if alias, ok := keyfileAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// marshalSetting writes the values of given setting with given name to
+// given buffer b sorted by their keys.
+func marshalSetting(
+	b *bytes.Buffer, name string, setting map[string]interface{},
+) error {
+	keys := []string{}
+	for k := range setting {
+		if !keyfileSkipped[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := setting[k]
+		if variant, ok := v.(dbus.Variant); ok {
+			v = variant.Value()
+		}
+		switch k {
+		case "address-data", "route-data":
+			if err := marshalIPData(b, name, k, v); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := marshalValue(name, k, v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "%s=%s\n", k, value)
+	}
+	return nil
+}
+
+// marshalValue returns the keyfile representation of given value v of
+// the key k of the setting with given name.
+func marshalValue(name, k string, v interface{}) (string, error) {
+	kind := keyfileKinds[name][k]
+	switch v := v.(type) {
+	case string:
+		if name == "connection" && k == "type" {
+			return keyfileAlias(v), nil
+		}
+		return escapeKeyfile(v, false), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int32:
+		if name == "ipv6" && k == "addr-gen-mode" &&
+			0 <= v && int(v) < len(addrGenModes) {
+			return addrGenModes[v], nil
+		}
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case []string:
+		return keyfileList(v), nil
+	case []byte:
+		switch kind {
+		case kfMAC:
+			ss := []string{}
+			for _, b := range v {
+				ss = append(ss, fmt.Sprintf("%02X", b))
+			}
+			return strings.Join(ss, ":"), nil
+		case kfBlob:
+			path := strings.TrimSuffix(string(v), "\x00")
+			if strings.HasPrefix(path, "file://") {
+				return escapeKeyfile(
+					strings.TrimPrefix(path, "file://"), false), nil
+			}
+			return "data:;base64," +
+				base64.StdEncoding.EncodeToString(v), nil
+		}
+		return marshalSSID(v), nil
+	case []uint32:
+		if kind != kfDNS4 {
+			break
+		}
+		ss := []string{}
+		for _, a := range v {
+			ss = append(ss, ip4OfNM(a).String())
+		}
+		return keyfileList(ss), nil
+	case [][]byte:
+		if kind != kfDNS6 {
+			break
+		}
+		ss := []string{}
+		for _, bb := range v {
+			a, ok := netip.AddrFromSlice(bb)
+			if !ok {
+				return "", fmt.Errorf("%w: %s.%s: invalid address",
+					ErrKeyfile, name, k)
+			}
+			ss = append(ss, a.String())
+		}
+		return keyfileList(ss), nil
+	}
+	return "", fmt.Errorf("%w: %s.%s: unsupported value type %T",
+		ErrKeyfile, name, k, v)
+}
+
+// marshalSSID returns given SSID as string if it is printable and
+// doesn't contain a semicolon; otherwise as list of its bytes.
+func marshalSSID(ssid []byte) string {
+	printable := utf8.Valid(ssid) && !bytes.ContainsRune(ssid, ';')
+	for _, r := range string(ssid) {
+		if !unicode.IsPrint(r) {
+			printable = false
+		}
+	}
+	if printable {
+		return escapeKeyfile(string(ssid), false)
+	}
+	b := &strings.Builder{}
+	for _, c := range ssid {
+		fmt.Fprintf(b, "%d;", c)
+	}
+	return b.String()
+}
+
+// marshalIPData writes the address-data or route-data of the ipv4 or
+// ipv6 setting with given name to given buffer b as numbered address
+// or route keys.
+func marshalIPData(
+	b *bytes.Buffer, name, k string, v interface{},
+) error {
+	data := []map[string]interface{}{}
+	switch v := v.(type) {
+	case []map[string]interface{}:
+		data = v
+	case []map[string]dbus.Variant:
+		for _, vv := range v {
+			m := map[string]interface{}{}
+			for k, v := range vv {
+				m[k] = v.Value()
+			}
+			data = append(data, m)
+		}
+	default:
+		return fmt.Errorf("%w: %s.%s: unsupported value type %T",
+			ErrKeyfile, name, k, v)
+	}
+	for i, d := range data {
+		if k == "address-data" {
+			fmt.Fprintf(b, "address%d=%v/%v\n", i+1, d["address"],
+				d["prefix"])
+			continue
+		}
+		route := fmt.Sprintf("%v/%v", d["dest"], d["prefix"])
+		if hop, ok := d["next-hop"]; ok {
+			route += fmt.Sprintf(",%v", hop)
+		}
+		if metric, ok := d["metric"]; ok {
+			if _, ok := d["next-hop"]; !ok {
+				route += ","
+			}
+			route += fmt.Sprintf(",%v", metric)
+		}
+		fmt.Fprintf(b, "route%d=%s\n", i+1, route)
+	}
+	return nil
+}
+
+// keyfileList returns given strings ss as keyfile list.
+func keyfileList(ss []string) string {
+	b := &strings.Builder{}
+	for _, s := range ss {
+		b.WriteString(escapeKeyfile(s, true))
+		b.WriteString(";")
+	}
+	return b.String()
+}
+
+// escapeKeyfile escapes given keyfile value s; semicolons are escaped
+// if given list flag is set.
+func escapeKeyfile(s string, list bool) string {
+	b := &strings.Builder{}
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == ' ' && i == 0:
+			b.WriteString(`\s`)
+		case r == ';' && list:
+			b.WriteString(`\;`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// UnmarshalKeyfile returns the connection settings of given keyfile
+// content bb.  Values are typed as NetworkManager expects them; values
+// of unknown settings are strings.  UnmarshalKeyfile fails with
+// ErrKeyfile if bb isn't a keyfile or a value doesn't match its type.
+func UnmarshalKeyfile(bb []byte) (nm.ConnectionSettings, error) {
+	ss, name := nm.ConnectionSettings{}, ""
+	aliases := map[string]string{}
+	for name, alias := range keyfileAliases {
+		aliases[alias] = name
+	}
+	sc := bufio.NewScanner(bytes.NewReader(bb))
+	for n := 1; sc.Scan(); n++ {
+		// trailing whitespace belongs to a value, e.g. a passphrase
+		raw := sc.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name = strings.TrimSpace(line[1 : len(line)-1])
+			if alias, ok := aliases[name]; ok {
+				name = alias
+			}
+			if _, ok := ss[name]; !ok {
+				ss[name] = map[string]interface{}{}
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(raw, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: line %d: expected key=value",
+				ErrKeyfile, n)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimLeft(v, " \t")
+		if err := unmarshalValue(ss, name, k, v); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %s.%s: %w",
+				ErrKeyfile, n, name, k, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeyfile, err)
+	}
+	if _, ok := ss["connection"]; !ok {
+		return nil, fmt.Errorf("%w: missing connection section",
+			ErrKeyfile)
+	}
+	if t, ok := ss["connection"]["type"].(string); ok {
+		if alias, ok := aliases[t]; ok {
+			ss["connection"]["type"] = alias
+		}
+	}
+	return ss, nil
+}
+
+// unmarshalValue sets the value of the key k of the setting with given
+// name in given settings ss to given keyfile value v.
+func unmarshalValue(
+	ss nm.ConnectionSettings, name, k, v string,
+) (err error) {
+	setting := ss[name]
+	if name == "ipv4" || name == "ipv6" {
+		if ok, err := unmarshalIPData(setting, k, v); ok || err != nil {
+			return err
+		}
+	}
+	switch keyfileKinds[name][k] {
+	case kfBool:
+		setting[k], err = strconv.ParseBool(v)
+	case kfInt32:
+		if name == "ipv6" && k == "addr-gen-mode" {
+			for i, mode := range addrGenModes {
+				if v == mode {
+					setting[k] = int32(i)
+					return nil
+				}
+			}
+		}
+		var i int64
+		i, err = strconv.ParseInt(v, 10, 32)
+		setting[k] = int32(i)
+	case kfUint32:
+		var i uint64
+		i, err = strconv.ParseUint(v, 10, 32)
+		setting[k] = uint32(i)
+	case kfInt64:
+		setting[k], err = strconv.ParseInt(v, 10, 64)
+	case kfUint64:
+		setting[k], err = strconv.ParseUint(v, 10, 64)
+	case kfStrings:
+		setting[k] = splitKeyfileList(v)
+	case kfSSID:
+		setting[k] = unmarshalSSID(v)
+	case kfMAC:
+		setting[k], err = unmarshalMAC(v)
+	case kfBlob:
+		if strings.HasPrefix(v, "data:;base64,") {
+			setting[k], err = base64.StdEncoding.DecodeString(
+				strings.TrimPrefix(v, "data:;base64,"))
+			break
+		}
+		setting[k] = []byte("file://" + unescapeKeyfile(v) + "\x00")
+	case kfDNS4:
+		dns := []uint32{}
+		for _, a := range splitKeyfileList(v) {
+			addr, err := netip.ParseAddr(a)
+			if err != nil || !addr.Is4() {
+				return fmt.Errorf("invalid ipv4 address '%s'", a)
+			}
+			dns = append(dns, nmIP4(addr))
+		}
+		setting[k] = dns
+	case kfDNS6:
+		dns := [][]byte{}
+		for _, a := range splitKeyfileList(v) {
+			addr, err := netip.ParseAddr(a)
+			if err != nil || !addr.Is6() {
+				return fmt.Errorf("invalid ipv6 address '%s'", a)
+			}
+			b := addr.As16()
+			dns = append(dns, b[:])
+		}
+		setting[k] = dns
+	default:
+		setting[k] = unescapeKeyfile(v)
+	}
+	return err
+}
+
+// unmarshalIPData adds the address or route of given numbered address
+// or route key k with given value v to the address-data or route-data
+// of given ipv4 or ipv6 setting.  The returned flag is false if k is no
+// address or route key.
+func unmarshalIPData(
+	setting map[string]interface{}, k, v string,
+) (bool, error) {
+	data, field := "", ""
+	switch {
+	case isNumbered(k, "address"):
+		data, field = "address-data", "address"
+	case isNumbered(k, "route"):
+		data, field = "route-data", "dest"
+	default:
+		return false, nil
+	}
+	parts := strings.Split(v, ",")
+	p, err := netip.ParsePrefix(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return true, err
+	}
+	d := map[string]interface{}{field: p.Addr().String(),
+		"prefix": uint32(p.Bits())}
+	switch {
+	case data == "address-data" && len(parts) > 1:
+		if _, ok := setting["gateway"]; !ok {
+			setting["gateway"] = strings.TrimSpace(parts[1])
+		}
+	case data == "route-data":
+		if len(parts) > 1 && strings.TrimSpace(parts[1]) != "" {
+			d["next-hop"] = strings.TrimSpace(parts[1])
+		}
+		if len(parts) > 2 {
+			metric, err := strconv.ParseUint(
+				strings.TrimSpace(parts[2]), 10, 32)
+			if err != nil {
+				return true, err
+			}
+			d["metric"] = uint32(metric)
+		}
+	}
+	dd, _ := setting[data].([]map[string]interface{})
+	setting[data] = append(dd, d)
+	return true, nil
+}
+
+// isNumbered returns true if given key k is given prefix followed by a
+// number or given prefix itself.
+func isNumbered(k, prefix string) bool {
+	if !strings.HasPrefix(k, prefix) {
+		return false
+	}
+	n := strings.TrimPrefix(k, prefix)
+	_, err := strconv.ParseUint(n, 10, 32)
+	return n == "" || err == nil
+}
+
+// unmarshalSSID returns the bytes of given keyfile SSID which is either
+// a string or a list of its bytes.
+func unmarshalSSID(v string) []byte {
+	if !strings.HasSuffix(v, ";") {
+		return []byte(unescapeKeyfile(v))
+	}
+	bb := []byte{}
+	for _, s := range strings.Split(strings.TrimSuffix(v, ";"), ";") {
+		b, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return []byte(unescapeKeyfile(v))
+		}
+		bb = append(bb, byte(b))
+	}
+	return bb
+}
+
+func unmarshalMAC(v string) ([]byte, error) {
+	bb := []byte{}
+	for _, s := range strings.Split(v, ":") {
+		b, err := strconv.ParseUint(s, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAC address '%s'", v)
+		}
+		bb = append(bb, byte(b))
+	}
+	return bb, nil
+}
+
+// splitKeyfileList returns the unescaped items of given keyfile list v.
+func splitKeyfileList(v string) []string {
+	ss, b, escaped := []string{}, &strings.Builder{}, false
+	for _, r := range v {
+		switch {
+		case escaped:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			ss = append(ss, unescapeKeyfile(b.String()))
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		ss = append(ss, unescapeKeyfile(b.String()))
+	}
+	return ss
+}
+
+// unescapeKeyfile reverts the escapes of given keyfile value v.
+func unescapeKeyfile(v string) string {
+	b, escaped := &strings.Builder{}, false
+	for _, r := range v {
+		if !escaped {
+			if r == '\\' {
+				escaped = true
+				continue
+			}
+			b.WriteRune(r)
+			continue
+		}
+		escaped = false
+		switch r {
+		case 'n':
+			b.WriteRune('\n')
+		case 't':
+			b.WriteRune('\t')
+		case 'r':
+			b.WriteRune('\r')
+		case 's':
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,139 @@
+package wifi
+
+import (
+	"net/netip"
+	"testing"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	. "github.com/slukits/gounit"
+)
+
+type AKeyfile struct{ Suite }
+
+func (s *AKeyfile) SetUp(t *T) { t.Parallel() }
+
+func (s *AKeyfile) Is_written_in_network_manager_s_format(t *T) {
+	bb, err := MarshalKeyfile(nm.ConnectionSettings{
+		"connection": {"id": "home", "uuid": "fake-home",
+			"type": wirelessSettings, "autoconnect": false},
+		wirelessSettings: {"ssid": []byte("home"), "mode": "infrastructure"},
+		wirelessSecurity: {"key-mgmt": "wpa-psk", "psk": "home-secret",
+			"proto": []string{"rsn"}},
+		"ipv4": {"method": "manual", "address-data": []map[string]interface{}{
+			{"address": "192.168.1.10", "prefix": uint32(24)}},
+			"dns": []uint32{nmIP4(netip.MustParseAddr("192.168.1.2"))}},
+		"ipv6": {"method": "auto", "addr-gen-mode": int32(1)},
+	})
+	t.FatalOn(err)
+	t.Eq("[connection]\nautoconnect=false\nid=home\ntype=wifi\n"+
+		"uuid=fake-home\n\n"+
+		"[wifi]\nmode=infrastructure\nssid=home\n\n"+
+		"[wifi-security]\nkey-mgmt=wpa-psk\nproto=rsn;\n"+
+		"psk=home-secret\n\n"+
+		"[ipv4]\naddress1=192.168.1.10/24\ndns=192.168.1.2;\n"+
+		"method=manual\n\n"+
+		"[ipv6]\naddr-gen-mode=stable-privacy\nmethod=auto\n",
+		string(bb))
+}
+
+func (s *AKeyfile) Writes_unprintable_ssids_as_byte_list(t *T) {
+	bb, err := MarshalKeyfile(nm.ConnectionSettings{
+		"connection":     {"id": " a\tb"},
+		wirelessSettings: {"ssid": []byte{'a', 0, ';'}},
+	})
+	t.FatalOn(err)
+	t.Eq("[connection]\nid=\\sa\\tb\n\n[wifi]\nssid=97;0;59;\n",
+		string(bb))
+}
+
+func (s *AKeyfile) Fails_to_write_unsupported_values(t *T) {
+	_, err := MarshalKeyfile(nm.ConnectionSettings{
+		"connection": {"id": struct{}{}}})
+	t.ErrIs(err, ErrKeyfile)
+}
+
+func (s *AKeyfile) Is_read_into_typed_settings(t *T) {
+	ss, err := UnmarshalKeyfile([]byte("# exported\n" +
+		"[connection]\nid=\\sa\\tb\nuuid=fake-lab\ntype=wifi\n" +
+		"autoconnect-priority=-5\n\n" +
+		"[wifi]\nssid=108;97;98;\nhidden=true\n" +
+		"bssid=00:00:00:00:00:0A\n\n" +
+		"[wifi-security]\nkey-mgmt=sae\npsk=lab;secret\n\n" +
+		"[802-1x]\neap=peap;ttls;\nca-cert=/etc/ssl/ca.pem\n\n" +
+		"[ipv4]\nmethod=manual\naddress1=192.168.1.10/24,192.168.1.1\n" +
+		"route1=10.0.0.0/8,,20\ndns=192.168.1.2;\n\n" +
+		"[ipv6]\nmethod=auto\naddr-gen-mode=eui64\ndns=fd00::2;\n"))
+	t.FatalOn(err)
+	t.Eq(map[string]interface{}{"id": " a\tb", "uuid": "fake-lab",
+		"type": wirelessSettings, "autoconnect-priority": int32(-5)},
+		ss["connection"])
+	t.Eq(map[string]interface{}{"ssid": []byte("lab"), "hidden": true,
+		"bssid": []byte{0, 0, 0, 0, 0, 10}}, ss[wirelessSettings])
+	t.Eq(map[string]interface{}{"key-mgmt": "sae", "psk": "lab;secret"},
+		ss[wirelessSecurity])
+	t.Eq(map[string]interface{}{"eap": []string{"peap", "ttls"},
+		"ca-cert": []byte("file:///etc/ssl/ca.pem\x00")},
+		ss[enterpriseSettings])
+	t.Eq(map[string]interface{}{
+		"method": "manual",
+		"address-data": []map[string]interface{}{
+			{"address": "192.168.1.10", "prefix": uint32(24)}},
+		"gateway": "192.168.1.1",
+		"route-data": []map[string]interface{}{
+			{"dest": "10.0.0.0", "prefix": uint32(8),
+				"metric": uint32(20)}},
+		"dns": []uint32{nmIP4(netip.MustParseAddr("192.168.1.2"))},
+	}, ss["ipv4"])
+	t.Eq(map[string]interface{}{"method": "auto",
+		"addr-gen-mode": int32(0), "dns": [][]byte{
+			{0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}}},
+		ss["ipv6"])
+}
+
+func (s *AKeyfile) Reads_back_what_it_writes(t *T) {
+	exp := nm.ConnectionSettings{
+		"connection": {"id": "lab", "uuid": "fake-lab",
+			"type": wirelessSettings, "timestamp": uint64(42)},
+		wirelessSettings: {"ssid": []byte{0xff, 'l'}, "hidden": true},
+		wirelessSecurity: {"key-mgmt": "wpa-psk", "psk": `a\b c`},
+		"ipv4": {"method": "manual", "address-data": []map[string]interface{}{
+			{"address": "192.168.1.10", "prefix": uint32(24)}},
+			"dns-search": []string{"lab.example", "a;b"}},
+	}
+	bb, err := MarshalKeyfile(exp)
+	t.FatalOn(err)
+	ss, err := UnmarshalKeyfile(bb)
+	t.FatalOn(err)
+	t.Eq(exp, ss)
+}
+
+func (s *AKeyfile) Keeps_trailing_whitespace_of_values(t *T) {
+	exp := nm.ConnectionSettings{
+		"connection":     {"id": "lab ", "type": wirelessSettings},
+		wirelessSettings: {"ssid": []byte("lab")},
+		wirelessSecurity: {"key-mgmt": "wpa-psk", "psk": " lab-secret "},
+	}
+	bb, err := MarshalKeyfile(exp)
+	t.FatalOn(err)
+	ss, err := UnmarshalKeyfile(bb)
+	t.FatalOn(err)
+	t.Eq(exp, ss)
+}
+
+func (s *AKeyfile) Fails_to_be_read_if_invalid(t *T) {
+	for _, kf := range []string{
+		"id=lab\n",
+		"[connection]\nid\n",
+		"[connection]\nautoconnect=maybe\n",
+		"[ipv4]\naddress1=192.168.1.10\n",
+		"[wifi]\nssid=lab\n",
+	} {
+		_, err := UnmarshalKeyfile([]byte(kf))
+		t.ErrIs(err, ErrKeyfile)
+	}
+}
+
+func TestAKeyfile(t *testing.T) {
+	t.Parallel()
+	Run(&AKeyfile{}, t)
+}
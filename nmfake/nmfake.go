@@ -0,0 +1,1065 @@
+/*
+Package nmfake provides an in-memory fake of the NetworkManager API which
+lets tests run without a NetworkManager daemon and without a wifi
+device.  A fake NM is scripted with devices, access points and
+connection profiles, e.g. see Default.  Activations and scans are applied
+synchronously and their state transitions are emitted as
+PropertiesChanged signals through the fake's Bus connections.  Access
+points appearing and disappearing are emitted as AccessPointAdded and
+AccessPointRemoved signals.  NOTE the fake types embed the interfaces
+they fake, i.e. a call of a not faked method panics.
+*/
+package nmfake
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+// paths provides unique object path numbers across all fakes.
+var paths int64
+
+func newPath(kind string) dbus.ObjectPath {
+	return dbus.ObjectPath(fmt.Sprintf("%s/%s/%d",
+		nm.NetworkManagerObjectPath, kind, atomic.AddInt64(&paths, 1)))
+}
+
+const (
+	dbusProperties    = "org.freedesktop.DBus.Properties"
+	propertiesChanged = "PropertiesChanged"
+
+	wirelessSettings = "802-11-wireless"
+	wirelessSecurity = "802-11-wireless-security"
+)
+
+// flags of a GetSecrets request
+const (
+	secretsAllowInteraction uint32 = 0x1
+	secretsRequestNew       uint32 = 0x2
+)
+
+var ErrFake = errors.New("fake network manager")
+
+// SecretAgent is the part of NetworkManager's SecretAgent D-Bus
+// interface the fake asks for secrets.
+type SecretAgent interface {
+	GetSecrets(
+		connection map[string]map[string]dbus.Variant,
+		path dbus.ObjectPath, setting string, hints []string,
+		flags uint32,
+	) (map[string]map[string]dbus.Variant, *dbus.Error)
+}
+
+// NM implements an in-memory nm.NetworkManager.
+type NM struct {
+	nm.NetworkManager
+	mutex    *sync.Mutex
+	devices  []*Device
+	profiles []*Connection
+	buses    []*Bus
+	agent    SecretAgent
+}
+
+// New creates a fake network manager without devices and profiles.
+func New() *NM { return &NM{mutex: &sync.Mutex{}} }
+
+// Default creates a fake network manager with an activated ethernet
+// device "eth0" and an activated wifi device "wlan0" which is connected
+// to the access point "home".  Further access points are "office" and
+// the open access point "cafe".  Only "home" has a configured profile.
+func Default() *NM {
+	fake := New()
+	fake.AddDevice("eth0", nm.NmDeviceTypeEthernet,
+		nm.NmDeviceStateActivated)
+	wlan := fake.AddDevice("wlan0", nm.NmDeviceTypeWifi,
+		nm.NmDeviceStateDisconnected)
+	home := wlan.AddAP(&AP{
+		SSID: "home", BSSID: "00:00:00:00:00:01", Strength: 80,
+		Frequency: 2412, Flags: uint32(nm.Nm80211APFlagsPrivacy),
+		RSNFlags: uint32(nm.Nm80211APSecKeyMgmtPSK),
+		Password: "home-secret",
+	})
+	wlan.AddAP(&AP{
+		SSID: "office", BSSID: "00:00:00:00:00:02", Strength: 60,
+		Frequency: 5180, Flags: uint32(nm.Nm80211APFlagsPrivacy),
+		RSNFlags: uint32(nm.Nm80211APSecKeyMgmtPSK |
+			nm.Nm80211APSecKeyMgmtSAE),
+		Password: "office-secret",
+	})
+	wlan.AddAP(&AP{
+		SSID: "cafe", BSSID: "00:00:00:00:00:03", Strength: 40,
+		Frequency: 2437,
+	})
+	profile := fake.AddProfile(WifiSettings("home", "wpa-psk",
+		"home-secret"))
+	fake.Connect(wlan, profile, home)
+	return fake
+}
+
+// WifiSettings returns the settings of a wifi profile for given SSID
+// with given key management and psk.  The security setting is omitted
+// for the zero key management.
+func WifiSettings(SSID, keyMgmt, psk string) nm.ConnectionSettings {
+	ss := nm.ConnectionSettings{
+		"connection": {
+			"id":   SSID,
+			"uuid": fmt.Sprintf("fake-%s", SSID),
+			"type": wirelessSettings,
+		},
+		wirelessSettings: {"ssid": []byte(SSID)},
+	}
+	if keyMgmt == "" {
+		return ss
+	}
+	ss[wirelessSettings]["security"] = wirelessSecurity
+	ss[wirelessSecurity] = map[string]interface{}{
+		"key-mgmt": keyMgmt,
+		"psk":      psk,
+	}
+	return ss
+}
+
+// AddDevice adds a device with given name, type and state.
+func (f *NM) AddDevice(
+	name string, type_ nm.NmDeviceType, state nm.NmDeviceState,
+) *Device {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	d := &Device{
+		nm_:         f,
+		path:        newPath("Devices"),
+		Name:        name,
+		Type:        type_,
+		State:       state,
+		HwAddress:   fmt.Sprintf("02:00:00:00:00:%02x", len(f.devices)),
+		Driver:      "fake",
+		Managed:     true,
+		Autoconnect: true,
+	}
+	f.devices = append(f.devices, d)
+	return d
+}
+
+// Device returns the fake device with given name or nil.
+func (f *NM) Device(name string) *Device {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, d := range f.devices {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// AddProfile adds a saved connection profile with given settings.
+func (f *NM) AddProfile(settings nm.ConnectionSettings) *Connection {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.addProfile(settings, false)
+}
+
+func (f *NM) addProfile(
+	settings nm.ConnectionSettings, unsaved bool,
+) *Connection {
+	c := &Connection{nm_: f, path: newPath("Settings"),
+		settings: copySettings(settings), Unsaved: unsaved}
+	f.profiles = append(f.profiles, c)
+	return c
+}
+
+// Profiles returns the fake's current connection profiles.
+func (f *NM) Profiles() []*Connection {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]*Connection{}, f.profiles...)
+}
+
+// Connect activates given device d with given profile p at given access
+// point ap without emitting signals.
+func (f *NM) Connect(d *Device, p *Connection, ap *AP) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	d.State = nm.NmDeviceStateActivated
+	d.Active = ap
+	d.activeConnection = &ActiveConnection{
+		path: newPath("ActiveConnection"), profile: p, device: d}
+}
+
+// NewWifiDevice fakes nm.NewDeviceWireless.
+func (f *NM) NewWifiDevice(
+	path dbus.ObjectPath,
+) (nm.DeviceWireless, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, d := range f.devices {
+		if d.path == path {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown device: %s", ErrFake, path)
+}
+
+// RequestScan requests a scan of the device with given path which
+// probes given SSIDs, i.e. the SSIDs of hidden access points answering
+// the probe become visible.
+func (f *NM) RequestScan(path dbus.ObjectPath, SSIDs []string) error {
+	f.mutex.Lock()
+	d := f.device(path)
+	f.mutex.Unlock()
+	if d == nil {
+		return fmt.Errorf("%w: unknown device: %s", ErrFake, path)
+	}
+	return d.requestScan(SSIDs)
+}
+
+// SystemBus fakes a new system bus connection.
+func (f *NM) SystemBus() (*Bus, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	b := &Bus{nm_: f}
+	f.buses = append(f.buses, b)
+	return b, nil
+}
+
+// RegisterSecretAgent registers given secret agent which is asked for
+// secrets if an activation lacks them.  The returned function
+// unregisters the agent.
+func (f *NM) RegisterSecretAgent(agent SecretAgent) (func() error, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.agent = agent
+	return func() error {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		f.agent = nil
+		return nil
+	}, nil
+}
+
+// NewSettings fakes nm.NewSettings.
+func (f *NM) NewSettings() (nm.Settings, error) {
+	return &Settings{nm_: f}, nil
+}
+
+func (f *NM) GetAllDevices() ([]nm.Device, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	dd := []nm.Device{}
+	for _, d := range f.devices {
+		dd = append(dd, d)
+	}
+	return dd, nil
+}
+
+func (f *NM) GetDevices() ([]nm.Device, error) {
+	return f.GetAllDevices()
+}
+
+func (f *NM) GetPropertyActiveConnections() (
+	[]nm.ActiveConnection, error,
+) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	cc := []nm.ActiveConnection{}
+	for _, d := range f.devices {
+		if d.activeConnection != nil {
+			cc = append(cc, d.activeConnection)
+		}
+	}
+	return cc, nil
+}
+
+func (f *NM) ActivateWirelessConnection(
+	c nm.Connection, d nm.Device, ap nm.AccessPoint,
+) (nm.ActiveConnection, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	device, profile := f.device(d.GetPath()), f.profile(c.GetPath())
+	if device == nil || profile == nil {
+		return nil, fmt.Errorf("%w: unknown device or profile", ErrFake)
+	}
+	var fakeAP *AP
+	if ap != nil {
+		if fakeAP = device.ap(ap.GetPath()); fakeAP == nil {
+			return nil, fmt.Errorf("%w: unknown access point", ErrFake)
+		}
+	}
+	return f.activate(device, profile, fakeAP), nil
+}
+
+// ActivateConnection activates given connection at given device with
+// an access point of the connection's SSID.  A hidden access point is
+// only found for a profile flagged hidden.  A profile in access point
+// mode activates the device's own access point.  The specific object
+// is ignored.
+func (f *NM) ActivateConnection(
+	c nm.Connection, d nm.Device, _ *dbus.Object,
+) (nm.ActiveConnection, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	device, profile := f.device(d.GetPath()), f.profile(c.GetPath())
+	if device == nil || profile == nil {
+		return nil, fmt.Errorf("%w: unknown device or profile", ErrFake)
+	}
+	ssid, _ := profile.settings[wirelessSettings]["ssid"].([]byte)
+	hidden, _ := profile.settings[wirelessSettings]["hidden"].(bool)
+	var fakeAP *AP
+	for _, ap := range device.APs {
+		if ap.SSID == string(ssid) && (!ap.Hidden || hidden) {
+			fakeAP = ap
+			break
+		}
+	}
+	if profile.settings[wirelessSettings]["mode"] == "ap" {
+		fakeAP = &AP{path: newPath("AccessPoint"), SSID: string(ssid),
+			BSSID: device.HwAddress, Mode: nm.Nm80211ModeAp}
+	}
+	return f.activate(device, profile, fakeAP), nil
+}
+
+// activate runs the activation script of given device d with given
+// profile p at given access point ap.  NOTE activate expects the
+// fake's mutex to be locked.
+func (f *NM) activate(
+	device *Device, profile *Connection, fakeAP *AP,
+) nm.ActiveConnection {
+	activate := device.Activate
+	if activate == nil {
+		activate = f.activation
+	}
+	active := &ActiveConnection{path: newPath("ActiveConnection"),
+		profile: profile, device: device}
+	for _, t := range activate(device, profile, fakeAP) {
+		switch t.State {
+		case nm.NmDeviceStatePrepare:
+			device.activeConnection = active
+		case nm.NmDeviceStateActivated:
+			device.activeConnection = active
+			device.setActive(fakeAP)
+		case nm.NmDeviceStateDisconnected:
+			device.activeConnection = nil
+			device.setActive(nil)
+		}
+		device.transition(t)
+	}
+	return active
+}
+
+func (f *NM) DeactivateConnection(c nm.ActiveConnection) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, d := range f.devices {
+		if d.activeConnection == nil ||
+			d.activeConnection.GetPath() != c.GetPath() {
+			continue
+		}
+		d.deactivate(nm.NmDeviceStateReasonUserRequested)
+		return nil
+	}
+	return fmt.Errorf("%w: unknown active connection", ErrFake)
+}
+
+// Transition is a device state transition with its reason.
+type Transition struct {
+	State  nm.NmDeviceState
+	Reason nm.NmDeviceStateReason
+}
+
+// activation is the default activation script of a device d with given
+// profile at given access point ap.  It fails with missing secrets if
+// the profile's secret doesn't match the access point's password and
+// a registered secret agent doesn't provide the access point password.
+// It fails with a not found SSID if ap is nil.
+func (f *NM) activation(
+	d *Device, p *Connection, ap *AP,
+) []Transition {
+	tt := []Transition{}
+	if d.State == nm.NmDeviceStateActivated {
+		tt = append(tt,
+			Transition{nm.NmDeviceStateDeactivating,
+				nm.NmDeviceStateReasonNewActivation},
+			Transition{nm.NmDeviceStateDisconnected,
+				nm.NmDeviceStateReasonNewActivation})
+	}
+	tt = append(tt,
+		Transition{nm.NmDeviceStatePrepare, nm.NmDeviceStateReasonNone},
+		Transition{nm.NmDeviceStateConfig, nm.NmDeviceStateReasonNone})
+	if ap == nil {
+		return append(tt,
+			Transition{nm.NmDeviceStateFailed,
+				nm.NmDeviceStateReasonSsidNotFound},
+			Transition{nm.NmDeviceStateDisconnected,
+				nm.NmDeviceStateReasonSsidNotFound})
+	}
+	if ap.Password != "" && p.secret() != ap.Password &&
+		!f.askAgent(p, ap) {
+		return append(tt,
+			Transition{nm.NmDeviceStateNeedAuth,
+				nm.NmDeviceStateReasonNone},
+			Transition{nm.NmDeviceStateFailed,
+				nm.NmDeviceStateReasonNoSecrets},
+			Transition{nm.NmDeviceStateDisconnected,
+				nm.NmDeviceStateReasonNoSecrets})
+	}
+	return append(tt,
+		Transition{nm.NmDeviceStateIpConfig, nm.NmDeviceStateReasonNone},
+		Transition{nm.NmDeviceStateActivated, nm.NmDeviceStateReasonNone})
+}
+
+// askAgent requests new secrets for given profile p from a registered
+// secret agent and stores them in p if they match given access point's
+// password.
+func (f *NM) askAgent(p *Connection, ap *AP) bool {
+	if f.agent == nil {
+		return false
+	}
+	request := map[string]map[string]dbus.Variant{}
+	for name, setting := range p.settings {
+		request[name] = map[string]dbus.Variant{}
+		for k, v := range setting {
+			request[name][k] = dbus.MakeVariant(v)
+		}
+	}
+	ss, err := f.agent.GetSecrets(request, p.path, wirelessSecurity, nil,
+		secretsAllowInteraction|secretsRequestNew)
+	if err != nil {
+		return false
+	}
+	for k, v := range ss[wirelessSecurity] {
+		p.settings[wirelessSecurity][k] = v.Value()
+	}
+	return p.secret() == ap.Password
+}
+
+func (f *NM) device(path dbus.ObjectPath) *Device {
+	for _, d := range f.devices {
+		if d.path == path {
+			return d
+		}
+	}
+	return nil
+}
+
+func (f *NM) profile(path dbus.ObjectPath) *Connection {
+	for _, p := range f.profiles {
+		if p.path == path {
+			return p
+		}
+	}
+	return nil
+}
+
+// SetConnectivity sets the fake's connectivity and emits its change.
+func (f *NM) SetConnectivity(c nm.NmConnectivity) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.emit(nm.NetworkManagerObjectPath, nm.NetworkManagerInterface,
+		map[string]dbus.Variant{
+			"Connectivity": dbus.MakeVariant(uint32(c))})
+}
+
+// emit sends a PropertiesChanged signal of given interface with given
+// changed properties from given path to all matching buses.  NOTE emit
+// expects the fake's mutex to be locked.
+func (f *NM) emit(
+	path dbus.ObjectPath, iface string, props map[string]dbus.Variant,
+) {
+	f.signal(path, dbusProperties+"."+propertiesChanged,
+		iface, props, []string{})
+}
+
+// signal sends the signal with given name and body from given path to
+// all matching buses.  NOTE signal expects the fake's mutex to be
+// locked.
+func (f *NM) signal(
+	path dbus.ObjectPath, name string, body ...interface{},
+) {
+	s := &dbus.Signal{Path: path, Name: name, Body: body}
+	for _, b := range f.buses {
+		b.send(s)
+	}
+}
+
+// Device implements an in-memory nm.DeviceWireless.
+type Device struct {
+	nm.DeviceWireless
+	nm_                  *NM
+	path                 dbus.ObjectPath
+	Name                 string
+	Type                 nm.NmDeviceType
+	State                nm.NmDeviceState
+	HwAddress            string
+	Driver               string
+	Managed, Autoconnect bool
+	APs                  []*AP
+	Active               *AP
+	activeConnection     *ActiveConnection
+	lastScan             int64
+
+	// Activate scripts the state transitions of an activation of
+	// given device with given profile at given access point; it
+	// defaults to NM.activation.
+	Activate func(*Device, *Connection, *AP) []Transition
+}
+
+// AddAP adds given access point ap to the access points the device d
+// sees and emits its appearance.
+func (d *Device) AddAP(ap *AP) *AP {
+	d.nm_.mutex.Lock()
+	defer d.nm_.mutex.Unlock()
+	ap.path = newPath("AccessPoint")
+	if ap.Mode == 0 {
+		ap.Mode = nm.Nm80211ModeInfra
+	}
+	d.APs = append(d.APs, ap)
+	d.nm_.signal(d.path, nm.DeviceWirelessInterface+".AccessPointAdded",
+		ap.path)
+	return ap
+}
+
+// RemoveAP removes given access point ap from the access points the
+// device d sees and emits its disappearance.
+func (d *Device) RemoveAP(ap *AP) {
+	d.nm_.mutex.Lock()
+	defer d.nm_.mutex.Unlock()
+	for i, ap_ := range d.APs {
+		if ap_ != ap {
+			continue
+		}
+		d.APs = append(d.APs[:i], d.APs[i+1:]...)
+		d.nm_.signal(d.path,
+			nm.DeviceWirelessInterface+".AccessPointRemoved", ap.path)
+		return
+	}
+}
+
+func (d *Device) ap(path dbus.ObjectPath) *AP {
+	for _, ap := range d.APs {
+		if ap.path == path {
+			return ap
+		}
+	}
+	return nil
+}
+
+// transition sets given transition's state and emits it.  NOTE
+// transition expects the fake's mutex to be locked.
+func (d *Device) transition(t Transition) {
+	d.State = t.State
+	d.nm_.emit(d.path, nm.DeviceInterface, map[string]dbus.Variant{
+		"State": dbus.MakeVariant(uint32(t.State)),
+		"StateReason": dbus.MakeVariant([]interface{}{
+			uint32(t.State), uint32(t.Reason)}),
+	})
+}
+
+// deactivate transitions an activated device to disconnected.  NOTE
+// deactivate expects the fake's mutex to be locked.
+func (d *Device) deactivate(reason nm.NmDeviceStateReason) {
+	d.transition(Transition{nm.NmDeviceStateDeactivating, reason})
+	d.activeConnection = nil
+	d.setActive(nil)
+	d.transition(Transition{nm.NmDeviceStateDisconnected, reason})
+}
+
+// setActive sets given access point ap as the device's active access
+// point and emits its change if it changed.  NOTE setActive expects
+// the fake's mutex to be locked.
+func (d *Device) setActive(ap *AP) {
+	if d.Active == ap {
+		return
+	}
+	d.Active = ap
+	path := dbus.ObjectPath("/")
+	if ap != nil {
+		path = ap.path
+	}
+	d.nm_.emit(d.path, nm.DeviceWirelessInterface,
+		map[string]dbus.Variant{
+			"ActiveAccessPoint": dbus.MakeVariant(path)})
+}
+
+func (d *Device) lock() func() {
+	d.nm_.mutex.Lock()
+	return d.nm_.mutex.Unlock
+}
+
+func (d *Device) GetPath() dbus.ObjectPath { return d.path }
+
+func (d *Device) GetPropertyInterface() (string, error) {
+	defer d.lock()()
+	return d.Name, nil
+}
+
+func (d *Device) GetPropertyDeviceType() (nm.NmDeviceType, error) {
+	defer d.lock()()
+	return d.Type, nil
+}
+
+func (d *Device) GetPropertyState() (nm.NmDeviceState, error) {
+	defer d.lock()()
+	return d.State, nil
+}
+
+func (d *Device) GetPropertyHwAddress() (string, error) {
+	defer d.lock()()
+	return d.HwAddress, nil
+}
+
+func (d *Device) GetPropertyDriver() (string, error) {
+	defer d.lock()()
+	return d.Driver, nil
+}
+
+func (d *Device) GetPropertyManaged() (bool, error) {
+	defer d.lock()()
+	return d.Managed, nil
+}
+
+func (d *Device) GetPropertyAutoConnect() (bool, error) {
+	defer d.lock()()
+	return d.Autoconnect, nil
+}
+
+func (d *Device) GetPropertyLastScan() (int64, error) {
+	defer d.lock()()
+	return d.lastScan, nil
+}
+
+// RequestScan emits a LastScan change.
+func (d *Device) RequestScan() error {
+	return d.requestScan(nil)
+}
+
+// requestScan reveals the hidden access points with given SSIDs and
+// emits a LastScan change.
+func (d *Device) requestScan(SSIDs []string) error {
+	defer d.lock()()
+	if d.Type != nm.NmDeviceTypeWifi {
+		return fmt.Errorf("%w: scan: not a wifi device", ErrFake)
+	}
+	if d.State < nm.NmDeviceStateDisconnected {
+		return fmt.Errorf("%w: scan: device is not available", ErrFake)
+	}
+	for _, ap := range d.APs {
+		for _, ssid := range SSIDs {
+			if ap.Hidden && ap.SSID == ssid {
+				ap.revealed.Store(true)
+			}
+		}
+	}
+	d.lastScan++
+	d.nm_.emit(d.path, nm.DeviceWirelessInterface,
+		map[string]dbus.Variant{"LastScan": dbus.MakeVariant(d.lastScan)})
+	return nil
+}
+
+func (d *Device) GetPropertyAccessPoints() ([]nm.AccessPoint, error) {
+	defer d.lock()()
+	aa := []nm.AccessPoint{}
+	for _, ap := range d.APs {
+		aa = append(aa, ap)
+	}
+	return aa, nil
+}
+
+func (d *Device) GetAccessPoints() ([]nm.AccessPoint, error) {
+	return d.GetPropertyAccessPoints()
+}
+
+func (d *Device) GetPropertyActiveAccessPoint() (nm.AccessPoint, error) {
+	defer d.lock()()
+	if d.Active == nil {
+		return nil, nil
+	}
+	return d.Active, nil
+}
+
+func (d *Device) GetPropertyActiveConnection() (
+	nm.ActiveConnection, error,
+) {
+	defer d.lock()()
+	if d.activeConnection == nil {
+		return nil, nil
+	}
+	return d.activeConnection, nil
+}
+
+// Disconnect deactivates an activated device.
+func (d *Device) Disconnect() error {
+	defer d.lock()()
+	if d.State != nm.NmDeviceStateActivated {
+		return fmt.Errorf("%w: disconnect: not active", ErrFake)
+	}
+	d.deactivate(nm.NmDeviceStateReasonUserRequested)
+	return nil
+}
+
+// AP implements an in-memory nm.AccessPoint.  Its Password is the
+// secret a profile must provide to activate a connection to it.  A
+// Hidden access point reports the zero SSID until a scan probes its
+// SSID.
+type AP struct {
+	nm.AccessPoint
+	path                      dbus.ObjectPath
+	revealed                  atomic.Bool
+	SSID, BSSID               string
+	Hidden                    bool
+	Strength                  uint8
+	Frequency                 uint32
+	Flags, WPAFlags, RSNFlags uint32
+	Mode                      nm.Nm80211Mode
+	MaxBitrate                uint32
+	LastSeen                  int32
+	Password                  string
+}
+
+func (ap *AP) GetPath() dbus.ObjectPath {
+	return ap.path
+}
+
+func (ap *AP) GetPropertySSID() (string, error) {
+	if ap.Hidden && !ap.revealed.Load() {
+		return "", nil
+	}
+	return ap.SSID, nil
+}
+
+func (ap *AP) GetPropertyHWAddress() (string, error) {
+	return ap.BSSID, nil
+}
+
+func (ap *AP) GetPropertyStrength() (uint8, error) {
+	return ap.Strength, nil
+}
+
+func (ap *AP) GetPropertyFrequency() (uint32, error) {
+	return ap.Frequency, nil
+}
+
+func (ap *AP) GetPropertyFlags() (uint32, error) {
+	return ap.Flags, nil
+}
+
+func (ap *AP) GetPropertyWPAFlags() (uint32, error) {
+	return ap.WPAFlags, nil
+}
+
+func (ap *AP) GetPropertyRSNFlags() (uint32, error) {
+	return ap.RSNFlags, nil
+}
+
+func (ap *AP) GetPropertyMode() (nm.Nm80211Mode, error) {
+	return ap.Mode, nil
+}
+
+func (ap *AP) GetPropertyMaxBitrate() (uint32, error) {
+	return ap.MaxBitrate, nil
+}
+
+func (ap *AP) GetPropertyLastSeen() (int32, error) {
+	return ap.LastSeen, nil
+}
+
+// Settings implements an in-memory nm.Settings.
+type Settings struct {
+	nm.Settings
+	nm_ *NM
+}
+
+func (s *Settings) ListConnections() ([]nm.Connection, error) {
+	s.nm_.mutex.Lock()
+	defer s.nm_.mutex.Unlock()
+	cc := []nm.Connection{}
+	for _, p := range s.nm_.profiles {
+		cc = append(cc, p)
+	}
+	return cc, nil
+}
+
+func (s *Settings) GetConnectionByUUID(uuid string) (
+	nm.Connection, error,
+) {
+	s.nm_.mutex.Lock()
+	defer s.nm_.mutex.Unlock()
+	for _, p := range s.nm_.profiles {
+		if p.settings["connection"]["uuid"] == uuid {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown uuid: %s", ErrFake, uuid)
+}
+
+func (s *Settings) AddConnection(
+	settings nm.ConnectionSettings,
+) (nm.Connection, error) {
+	s.nm_.mutex.Lock()
+	defer s.nm_.mutex.Unlock()
+	return s.nm_.addProfile(settings, false), nil
+}
+
+func (s *Settings) AddConnectionUnsaved(
+	settings nm.ConnectionSettings,
+) (nm.Connection, error) {
+	s.nm_.mutex.Lock()
+	defer s.nm_.mutex.Unlock()
+	return s.nm_.addProfile(settings, true), nil
+}
+
+// Connection implements an in-memory nm.Connection.  Like
+// NetworkManager's GetSettings a Connection's GetSettings doesn't
+// provide secrets which can be obtained by GetSecrets.
+type Connection struct {
+	nm.Connection
+	nm_      *NM
+	path     dbus.ObjectPath
+	settings nm.ConnectionSettings
+	Unsaved  bool
+}
+
+// secretKeys are the keys of setting values which are secrets.
+var secretKeys = map[string]bool{"psk": true, "wep-key0": true,
+	"wep-key1": true, "wep-key2": true, "wep-key3": true,
+	"password": true, "private-key-password": true}
+
+// secret returns the profile's psk, WEP key or 802.1X password.
+func (c *Connection) secret() string {
+	if psk, ok := c.settings[wirelessSecurity]["psk"].(string); ok {
+		return psk
+	}
+	if pwd, ok := c.settings["802-1x"]["password"].(string); ok {
+		return pwd
+	}
+	key, _ := c.settings[wirelessSecurity]["wep-key0"].(string)
+	return key
+}
+
+// Settings returns the profile's settings including its secrets.
+func (c *Connection) Settings() nm.ConnectionSettings {
+	c.nm_.mutex.Lock()
+	defer c.nm_.mutex.Unlock()
+	return copySettings(c.settings)
+}
+
+func (c *Connection) GetPath() dbus.ObjectPath { return c.path }
+
+func (c *Connection) GetSettings() (nm.ConnectionSettings, error) {
+	c.nm_.mutex.Lock()
+	defer c.nm_.mutex.Unlock()
+	ss := copySettings(c.settings)
+	for _, setting := range ss {
+		for k := range setting {
+			if secretKeys[k] {
+				delete(setting, k)
+			}
+		}
+	}
+	return ss, nil
+}
+
+func (c *Connection) GetSecrets(name string) (
+	nm.ConnectionSettings, error,
+) {
+	c.nm_.mutex.Lock()
+	defer c.nm_.mutex.Unlock()
+	secrets := map[string]interface{}{}
+	for k, v := range c.settings[name] {
+		if secretKeys[k] {
+			secrets[k] = v
+		}
+	}
+	return nm.ConnectionSettings{name: secrets}, nil
+}
+
+func (c *Connection) Update(settings nm.ConnectionSettings) error {
+	c.nm_.mutex.Lock()
+	defer c.nm_.mutex.Unlock()
+	c.settings, c.Unsaved = copySettings(settings), false
+	return nil
+}
+
+func (c *Connection) Save() error {
+	c.nm_.mutex.Lock()
+	defer c.nm_.mutex.Unlock()
+	c.Unsaved = false
+	return nil
+}
+
+func (c *Connection) GetPropertyUnsaved() (bool, error) {
+	c.nm_.mutex.Lock()
+	defer c.nm_.mutex.Unlock()
+	return c.Unsaved, nil
+}
+
+func (c *Connection) Delete() error {
+	c.nm_.mutex.Lock()
+	defer c.nm_.mutex.Unlock()
+	for i, p := range c.nm_.profiles {
+		if p != c {
+			continue
+		}
+		c.nm_.profiles = append(c.nm_.profiles[:i],
+			c.nm_.profiles[i+1:]...)
+		for _, d := range c.nm_.devices {
+			if d.activeConnection != nil && d.activeConnection.profile == c {
+				d.deactivate(nm.NmDeviceStateReasonConnectionRemoved)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown profile", ErrFake)
+}
+
+func copySettings(ss nm.ConnectionSettings) nm.ConnectionSettings {
+	cp := nm.ConnectionSettings{}
+	for name, setting := range ss {
+		cp[name] = map[string]interface{}{}
+		for k, v := range setting {
+			cp[name][k] = v
+		}
+	}
+	return cp
+}
+
+// ActiveConnection implements an in-memory nm.ActiveConnection.
+type ActiveConnection struct {
+	nm.ActiveConnection
+	path    dbus.ObjectPath
+	profile *Connection
+	device  *Device
+}
+
+func (c *ActiveConnection) GetPath() dbus.ObjectPath { return c.path }
+
+func (c *ActiveConnection) GetPropertyConnection() (
+	nm.Connection, error,
+) {
+	return c.profile, nil
+}
+
+func (c *ActiveConnection) GetPropertyDevices() ([]nm.Device, error) {
+	return []nm.Device{c.device}, nil
+}
+
+// Bus fakes a bus connection receiving the signals the NM emits which
+// match its match rules.
+type Bus struct {
+	nm_     *NM
+	rules   []map[string]string
+	cc      []chan<- *dbus.Signal
+	Matched int
+	closed  bool
+}
+
+// AddMatchSignal adds a match rule.  NOTE since the fields of a
+// dbus.MatchOption are not exported the options are read from their
+// string representation.
+func (b *Bus) AddMatchSignal(oo ...dbus.MatchOption) error {
+	b.nm_.mutex.Lock()
+	defer b.nm_.mutex.Unlock()
+	b.rules = append(b.rules, ruleOf(oo))
+	return nil
+}
+
+// ruleOf returns the match rule of given match options oo.
+func ruleOf(oo []dbus.MatchOption) map[string]string {
+	rule := map[string]string{}
+	for _, o := range oo {
+		kv := strings.SplitN(strings.Trim(fmt.Sprintf("%v", o), "{}"),
+			" ", 2)
+		if len(kv) == 2 {
+			rule[kv[0]] = kv[1]
+		}
+	}
+	return rule
+}
+
+// RemoveMatchSignal removes a match rule added with the same options.
+func (b *Bus) RemoveMatchSignal(oo ...dbus.MatchOption) error {
+	b.nm_.mutex.Lock()
+	defer b.nm_.mutex.Unlock()
+	rule := ruleOf(oo)
+	for i, r := range b.rules {
+		if fmt.Sprint(r) != fmt.Sprint(rule) {
+			continue
+		}
+		b.rules = append(b.rules[:i], b.rules[i+1:]...)
+		return nil
+	}
+	return fmt.Errorf("%w: unknown match rule", ErrFake)
+}
+
+func (b *Bus) Signal(c chan<- *dbus.Signal) {
+	b.nm_.mutex.Lock()
+	defer b.nm_.mutex.Unlock()
+	b.cc = append(b.cc, c)
+}
+
+func (b *Bus) RemoveSignal(c chan<- *dbus.Signal) {
+	b.nm_.mutex.Lock()
+	defer b.nm_.mutex.Unlock()
+	for i, c_ := range b.cc {
+		if c_ == c {
+			b.cc = append(b.cc[:i], b.cc[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *Bus) Close() error {
+	b.nm_.mutex.Lock()
+	defer b.nm_.mutex.Unlock()
+	if b.closed {
+		return fmt.Errorf("%w: bus already closed", ErrFake)
+	}
+	b.closed = true
+	for i, b_ := range b.nm_.buses {
+		if b_ == b {
+			b.nm_.buses = append(b.nm_.buses[:i], b.nm_.buses[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// send passes given signal s to the bus's channels if it matches one of
+// its rules.  NOTE send expects the fake's mutex to be locked.
+func (b *Bus) send(s *dbus.Signal) {
+	if !b.matches(s) {
+		return
+	}
+	b.Matched++
+	for _, c := range b.cc {
+		select {
+		case c <- s:
+		default:
+		}
+	}
+}
+
+func (b *Bus) matches(s *dbus.Signal) bool {
+	iface, member := s.Name, ""
+	if i := strings.LastIndex(s.Name, "."); i >= 0 {
+		iface, member = s.Name[:i], s.Name[i+1:]
+	}
+	for _, rule := range b.rules {
+		if path, ok := rule["path"]; ok && path != string(s.Path) {
+			continue
+		}
+		if i, ok := rule["interface"]; ok && i != iface {
+			continue
+		}
+		if m, ok := rule["member"]; ok && m != member {
+			continue
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,493 @@
+/*
+Package nmstub serves a subset of NetworkManager's D-Bus API, i.e.
+devices, wireless devices, access points, settings, connections, active
+connections and the agent manager, on a given bus connection.  It lets
+the gonetworkmanager based client code talk to a scripted world on a
+private dbus-daemon bus instead of a real NetworkManager.  Device state
+and LastScan transitions are emitted as PropertiesChanged signals like
+NetworkManager does.
+*/
+package nmstub
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	dbusProperties    = "org.freedesktop.DBus.Properties"
+	propertiesChanged = dbusProperties + ".PropertiesChanged"
+
+	agentManagerInterface = nm.NetworkManagerInterface + ".AgentManager"
+	agentManagerPath      = nm.NetworkManagerObjectPath + "/AgentManager"
+	secretAgentInterface  = nm.NetworkManagerInterface + ".SecretAgent"
+	secretAgentPath       = "/org/freedesktop/NetworkManager/SecretAgent"
+
+	wirelessSettings = "802-11-wireless"
+	wirelessSecurity = "802-11-wireless-security"
+
+	// noPath is the object path of a not set object property.
+	noPath = dbus.ObjectPath("/")
+)
+
+// flags of a GetSecrets request
+const (
+	secretsAllowInteraction uint32 = 0x1
+	secretsRequestNew       uint32 = 0x2
+)
+
+var ErrStub = errors.New("nmstub")
+
+// Settings are connection settings in their D-Bus representation.
+type Settings = map[string]map[string]dbus.Variant
+
+// Stub is a NetworkManager stand-in serving its objects on a bus
+// connection.  A Stub is populated by AddDevice, Device.AddAP and
+// AddProfile.
+type Stub struct {
+
+	// Step is the delay between two state transitions of a device.
+	Step time.Duration
+
+	mutex    *sync.Mutex
+	conn     *dbus.Conn
+	root     *prop.Properties
+	settings *prop.Properties
+	devices  []*Device
+	profiles []*Profile
+	actives  []*Active
+	agent    dbus.Sender
+	paths    int
+}
+
+// New requests NetworkManager's bus name on given connection conn and
+// exports a stub without devices and profiles.
+func New(conn *dbus.Conn) (*Stub, error) {
+	s := &Stub{Step: 10 * time.Millisecond, mutex: &sync.Mutex{},
+		conn: conn}
+	if err := s.exportRoot(); err != nil {
+		return nil, err
+	}
+	if err := s.exportSettings(); err != nil {
+		return nil, err
+	}
+	err := conn.ExportMethodTable(map[string]interface{}{
+		"Register":                 s.register,
+		"RegisterWithCapabilities": s.registerWithCapabilities,
+		"Unregister":               s.unregister,
+	}, agentManagerPath, agentManagerInterface)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	reply, err := conn.RequestName(nm.NetworkManagerInterface,
+		dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, fmt.Errorf("%w: bus name '%s' is taken",
+			ErrStub, nm.NetworkManagerInterface)
+	}
+	return s, nil
+}
+
+// Default exports a stub on given connection conn with an activated
+// ethernet device "eth0" and a wifi device "wlan0" which is connected
+// to the access point "home".  Further access points are "office" and
+// the open access point "cafe".  Only "home" has a profile.
+func Default(conn *dbus.Conn) (*Stub, error) {
+	s, err := New(conn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.AddDevice("eth0", nm.NmDeviceTypeEthernet,
+		nm.NmDeviceStateActivated); err != nil {
+		return nil, err
+	}
+	wlan, err := s.AddDevice("wlan0", nm.NmDeviceTypeWifi,
+		nm.NmDeviceStateDisconnected)
+	if err != nil {
+		return nil, err
+	}
+	home := &AP{SSID: "home", BSSID: "00:00:00:00:00:01", Strength: 80,
+		Frequency: 2412, Flags: uint32(nm.Nm80211APFlagsPrivacy),
+		RSNFlags: uint32(nm.Nm80211APSecKeyMgmtPSK),
+		Password: "home-secret"}
+	for _, ap := range []*AP{home, {SSID: "office",
+		BSSID: "00:00:00:00:00:02", Strength: 60, Frequency: 5180,
+		Flags: uint32(nm.Nm80211APFlagsPrivacy),
+		RSNFlags: uint32(nm.Nm80211APSecKeyMgmtPSK |
+			nm.Nm80211APSecKeyMgmtSAE),
+		Password: "office-secret"}, {SSID: "cafe",
+		BSSID: "00:00:00:00:00:03", Strength: 40, Frequency: 2437},
+	} {
+		if err := wlan.AddAP(ap); err != nil {
+			return nil, err
+		}
+	}
+	profile, err := s.AddProfile(WifiSettings("home", "wpa-psk",
+		"home-secret"))
+	if err != nil {
+		return nil, err
+	}
+	return s, s.Connect(wlan, profile, home)
+}
+
+// WifiSettings returns the settings of a wifi profile for given SSID
+// with given key management and psk.  The security setting is omitted
+// for the zero key management.
+func WifiSettings(SSID, keyMgmt, psk string) Settings {
+	ss := Settings{
+		"connection": {
+			"id":   dbus.MakeVariant(SSID),
+			"uuid": dbus.MakeVariant(fmt.Sprintf("stub-%s", SSID)),
+			"type": dbus.MakeVariant(wirelessSettings),
+		},
+		wirelessSettings: {"ssid": dbus.MakeVariant([]byte(SSID))},
+	}
+	if keyMgmt == "" {
+		return ss
+	}
+	ss[wirelessSettings]["security"] = dbus.MakeVariant(wirelessSecurity)
+	ss[wirelessSecurity] = map[string]dbus.Variant{
+		"key-mgmt": dbus.MakeVariant(keyMgmt),
+		"psk":      dbus.MakeVariant(psk),
+	}
+	return ss
+}
+
+// Close releases the stub's bus name.
+func (s *Stub) Close() error {
+	_, err := s.conn.ReleaseName(nm.NetworkManagerInterface)
+	return err
+}
+
+func (s *Stub) path(kind string) dbus.ObjectPath {
+	s.paths++
+	return dbus.ObjectPath(fmt.Sprintf("%s/%s/%d",
+		nm.NetworkManagerObjectPath, kind, s.paths))
+}
+
+func (s *Stub) exportRoot() (err error) {
+	err = s.conn.ExportMethodTable(map[string]interface{}{
+		"GetDevices":           s.getDevices,
+		"GetAllDevices":        s.getDevices,
+		"ActivateConnection":   s.activateConnection,
+		"DeactivateConnection": s.deactivateConnection,
+	}, nm.NetworkManagerObjectPath, nm.NetworkManagerInterface)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	s.root, err = prop.Export(s.conn, nm.NetworkManagerObjectPath,
+		prop.Map{nm.NetworkManagerInterface: {
+			"Devices":           readable([]dbus.ObjectPath{}),
+			"AllDevices":        readable([]dbus.ObjectPath{}),
+			"ActiveConnections": readable([]dbus.ObjectPath{}),
+			"Version":           readable("1.0.0-stub"),
+			"WirelessEnabled":   readable(true),
+		}})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	return nil
+}
+
+func (s *Stub) exportSettings() (err error) {
+	err = s.conn.ExportMethodTable(map[string]interface{}{
+		"ListConnections":      s.listConnections,
+		"GetConnectionByUuid":  s.connectionByUUID,
+		"AddConnection":        s.addConnection,
+		"AddConnectionUnsaved": s.addConnectionUnsaved,
+	}, nm.SettingsObjectPath, nm.SettingsInterface)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	s.settings, err = prop.Export(s.conn, nm.SettingsObjectPath,
+		prop.Map{nm.SettingsInterface: {
+			"Connections": readable([]dbus.ObjectPath{}),
+			"CanModify":   readable(true),
+		}})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	return nil
+}
+
+func readable(v interface{}) *prop.Prop {
+	return &prop.Prop{Value: v, Emit: prop.EmitFalse}
+}
+
+// emit sends a PropertiesChanged signal for given interface iface of
+// the object at given path with given changed properties.
+func (s *Stub) emit(
+	path dbus.ObjectPath, iface string, props map[string]dbus.Variant,
+) {
+	s.conn.Emit(path, propertiesChanged, iface, props, []string{})
+}
+
+func (s *Stub) getDevices() ([]dbus.ObjectPath, *dbus.Error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pp := []dbus.ObjectPath{}
+	for _, d := range s.devices {
+		pp = append(pp, d.path)
+	}
+	return pp, nil
+}
+
+// updateDevices sets the devices properties.  NOTE updateDevices
+// expects the stub's mutex to be locked.
+func (s *Stub) updateDevices() {
+	pp := []dbus.ObjectPath{}
+	for _, d := range s.devices {
+		pp = append(pp, d.path)
+	}
+	s.root.SetMust(nm.NetworkManagerInterface, "Devices", pp)
+	s.root.SetMust(nm.NetworkManagerInterface, "AllDevices", pp)
+}
+
+// updateActives sets the active connections property.  NOTE
+// updateActives expects the stub's mutex to be locked.
+func (s *Stub) updateActives() {
+	pp := []dbus.ObjectPath{}
+	for _, a := range s.actives {
+		pp = append(pp, a.path)
+	}
+	s.root.SetMust(nm.NetworkManagerInterface, "ActiveConnections", pp)
+}
+
+// updateProfiles sets the connections property of the settings.  NOTE
+// updateProfiles expects the stub's mutex to be locked.
+func (s *Stub) updateProfiles() {
+	pp := []dbus.ObjectPath{}
+	for _, p := range s.profiles {
+		pp = append(pp, p.path)
+	}
+	s.settings.SetMust(nm.SettingsInterface, "Connections", pp)
+}
+
+func (s *Stub) device(path dbus.ObjectPath) *Device {
+	for _, d := range s.devices {
+		if d.path == path {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *Stub) profile(path dbus.ObjectPath) *Profile {
+	for _, p := range s.profiles {
+		if p.path == path {
+			return p
+		}
+	}
+	return nil
+}
+
+// Device returns the device with given name or nil.
+func (s *Stub) Device(name string) *Device {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, d := range s.devices {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// Profiles returns the stub's connection profiles.
+func (s *Stub) Profiles() []*Profile {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*Profile{}, s.profiles...)
+}
+
+// Connect activates given device d with given profile p at given access
+// point ap without emitting signals.
+func (s *Stub) Connect(d *Device, p *Profile, ap *AP) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	a, err := s.newActive(d, p, ap)
+	if err != nil {
+		return err
+	}
+	a.setState(nm.NmActiveConnectionStateActivated)
+	d.setActive(a, ap)
+	d.setState(nm.NmDeviceStateActivated, nm.NmDeviceStateReasonNone)
+	return nil
+}
+
+func (s *Stub) activateConnection(
+	connection, device, specific dbus.ObjectPath,
+) (dbus.ObjectPath, *dbus.Error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d, p := s.device(device), s.profile(connection)
+	if d == nil || p == nil {
+		return noPath, dbus.NewError(nm.NetworkManagerInterface+
+			".UnknownConnection", []interface{}{
+			"unknown connection or device"})
+	}
+	ap := d.ap(specific)
+	if ap == nil && d.Type == nm.NmDeviceTypeWifi {
+		ap = d.apOf(p.SSID())
+	}
+	if ap == nil && d.Type == nm.NmDeviceTypeWifi {
+		return noPath, dbus.NewError(nm.NetworkManagerInterface+
+			".UnknownConnection", []interface{}{
+			"no access point for connection"})
+	}
+	a, err := s.newActive(d, p, ap)
+	if err != nil {
+		return noPath, dbus.MakeFailedError(err)
+	}
+	go s.activate(d, a, ap)
+	return a.path, nil
+}
+
+// activate runs the state transitions of the activation of given
+// device d with given active connection a at given access point ap.
+// The registered secret agent is asked for secrets if the profile's
+// secret doesn't match the access point's password.
+func (s *Stub) activate(d *Device, a *Active, ap *AP) {
+	s.mutex.Lock()
+	active := d.State == nm.NmDeviceStateActivated
+	s.mutex.Unlock()
+	if active {
+		s.transition(d, nm.NmDeviceStateDeactivating,
+			nm.NmDeviceStateReasonNewActivation)
+		s.transition(d, nm.NmDeviceStateDisconnected,
+			nm.NmDeviceStateReasonNewActivation)
+	}
+	s.mutex.Lock()
+	a.setState(nm.NmActiveConnectionStateActivating)
+	d.setActive(a, nil)
+	s.mutex.Unlock()
+	s.transition(d, nm.NmDeviceStatePrepare, nm.NmDeviceStateReasonNone)
+	s.transition(d, nm.NmDeviceStateConfig, nm.NmDeviceStateReasonNone)
+	if ap != nil && ap.Password != "" && a.profile.secret() != ap.Password {
+		s.transition(d, nm.NmDeviceStateNeedAuth,
+			nm.NmDeviceStateReasonNone)
+		if !s.askAgent(a.profile, ap) {
+			s.fail(d, a, nm.NmDeviceStateReasonNoSecrets)
+			return
+		}
+	}
+	s.transition(d, nm.NmDeviceStateIpConfig, nm.NmDeviceStateReasonNone)
+	s.mutex.Lock()
+	a.setState(nm.NmActiveConnectionStateActivated)
+	d.setActive(a, ap)
+	s.mutex.Unlock()
+	s.transition(d, nm.NmDeviceStateActivated, nm.NmDeviceStateReasonNone)
+}
+
+func (s *Stub) fail(d *Device, a *Active, reason nm.NmDeviceStateReason) {
+	s.transition(d, nm.NmDeviceStateFailed, reason)
+	s.mutex.Lock()
+	s.removeActive(a)
+	s.mutex.Unlock()
+	s.transition(d, nm.NmDeviceStateDisconnected, reason)
+}
+
+// askAgent requests new secrets for given profile p from the registered
+// secret agent and stores them if they match the password of given
+// access point ap.
+func (s *Stub) askAgent(p *Profile, ap *AP) bool {
+	s.mutex.Lock()
+	agent, settings := s.agent, p.copySettings()
+	s.mutex.Unlock()
+	if agent == "" {
+		return false
+	}
+	secrets := Settings{}
+	err := s.conn.Object(string(agent), secretAgentPath).Call(
+		secretAgentInterface+".GetSecrets", 0, settings, p.path,
+		wirelessSecurity, []string{},
+		secretsAllowInteraction|secretsRequestNew).Store(&secrets)
+	if err != nil {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for k, v := range secrets[wirelessSecurity] {
+		p.settings[wirelessSecurity][k] = v
+	}
+	return p.secret() == ap.Password
+}
+
+// transition sets given device d's state to given state with given
+// reason and emits the change after the stub's step delay.
+func (s *Stub) transition(
+	d *Device, state nm.NmDeviceState, reason nm.NmDeviceStateReason,
+) {
+	time.Sleep(s.Step)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d.setState(state, reason)
+	s.emit(d.path, nm.DeviceInterface, map[string]dbus.Variant{
+		"State": dbus.MakeVariant(uint32(state)),
+		"StateReason": dbus.MakeVariant(stateReason{
+			uint32(state), uint32(reason)}),
+	})
+}
+
+// deactivate transitions given device d to disconnected for given
+// reason.
+func (s *Stub) deactivate(d *Device, reason nm.NmDeviceStateReason) {
+	s.transition(d, nm.NmDeviceStateDeactivating, reason)
+	s.mutex.Lock()
+	if d.active != nil {
+		s.removeActive(d.active)
+	}
+	s.mutex.Unlock()
+	s.transition(d, nm.NmDeviceStateDisconnected, reason)
+}
+
+func (s *Stub) deactivateConnection(path dbus.ObjectPath) *dbus.Error {
+	s.mutex.Lock()
+	var device *Device
+	for _, a := range s.actives {
+		if a.path == path {
+			device = a.device
+		}
+	}
+	s.mutex.Unlock()
+	if device == nil {
+		return dbus.NewError(nm.NetworkManagerInterface+
+			".ConnectionNotActive", []interface{}{
+			"connection not active"})
+	}
+	go s.deactivate(device, nm.NmDeviceStateReasonUserRequested)
+	return nil
+}
+
+func (s *Stub) register(sender dbus.Sender, id string) *dbus.Error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.agent = sender
+	return nil
+}
+
+func (s *Stub) registerWithCapabilities(
+	sender dbus.Sender, id string, capabilities uint32,
+) *dbus.Error {
+	return s.register(sender, id)
+}
+
+func (s *Stub) unregister(sender dbus.Sender) *dbus.Error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.agent == sender {
+		s.agent = ""
+	}
+	return nil
+}
+
+type stateReason struct{ State, Reason uint32 }
@@ -0,0 +1,524 @@
+package nmstub
+
+import (
+	"fmt"
+	"time"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// Device is a stubbed NetworkManager device.  Wifi devices also provide
+// the wireless device interface.
+type Device struct {
+	Name  string
+	Type  nm.NmDeviceType
+	State nm.NmDeviceState
+
+	// APs are the access points a wifi device sees.
+	APs []*AP
+
+	stub     *Stub
+	path     dbus.ObjectPath
+	props    *prop.Properties
+	active   *Active
+	lastScan int64
+}
+
+// AddDevice exports a device with given name, type and state.
+func (s *Stub) AddDevice(
+	name string, type_ nm.NmDeviceType, state nm.NmDeviceState,
+) (*Device, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d := &Device{Name: name, Type: type_, State: state, stub: s,
+		path: s.path("Devices")}
+	hwAddress := fmt.Sprintf("02:00:00:00:00:%02x", len(s.devices))
+	err := s.conn.ExportMethodTable(map[string]interface{}{
+		"Disconnect": d.disconnect,
+	}, d.path, nm.DeviceInterface)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	pm := prop.Map{nm.DeviceInterface: {
+		"Interface":        readable(name),
+		"Udi":              readable("/sys/devices/virtual/net/" + name),
+		"Driver":           readable("nmstub"),
+		"HwAddress":        readable(hwAddress),
+		"DeviceType":       readable(uint32(type_)),
+		"State":            readable(uint32(state)),
+		"StateReason":      readable(stateReason{uint32(state), 0}),
+		"Managed":          readable(true),
+		"Autoconnect":      readable(true),
+		"Real":             readable(true),
+		"ActiveConnection": readable(noPath),
+	}}
+	if type_ == nm.NmDeviceTypeWifi {
+		err := s.conn.ExportMethodTable(map[string]interface{}{
+			"RequestScan":        d.requestScan,
+			"GetAccessPoints":    d.accessPoints,
+			"GetAllAccessPoints": d.accessPoints,
+		}, d.path, nm.DeviceWirelessInterface)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrStub, err)
+		}
+		pm[nm.DeviceWirelessInterface] = map[string]*prop.Prop{
+			"HwAddress":            readable(hwAddress),
+			"PermHwAddress":        readable(hwAddress),
+			"Mode":                 readable(uint32(nm.Nm80211ModeInfra)),
+			"Bitrate":              readable(uint32(0)),
+			"AccessPoints":         readable([]dbus.ObjectPath{}),
+			"ActiveAccessPoint":    readable(noPath),
+			"LastScan":             readable(int64(-1)),
+			"WirelessCapabilities": readable(uint32(0)),
+		}
+	}
+	if d.props, err = prop.Export(s.conn, d.path, pm); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	s.devices = append(s.devices, d)
+	s.updateDevices()
+	return d, nil
+}
+
+// AddAP exports given access point ap and adds it to the access points
+// device d sees.
+func (d *Device) AddAP(ap *AP) error {
+	d.stub.mutex.Lock()
+	defer d.stub.mutex.Unlock()
+	ap.path = d.stub.path("AccessPoint")
+	if ap.Mode == 0 {
+		ap.Mode = nm.Nm80211ModeInfra
+	}
+	_, err := prop.Export(d.stub.conn, ap.path, prop.Map{
+		nm.AccessPointInterface: {
+			"Ssid":       readable([]byte(ap.SSID)),
+			"HwAddress":  readable(ap.BSSID),
+			"Strength":   readable(ap.Strength),
+			"Frequency":  readable(ap.Frequency),
+			"Flags":      readable(ap.Flags),
+			"WpaFlags":   readable(ap.WPAFlags),
+			"RsnFlags":   readable(ap.RSNFlags),
+			"Mode":       readable(uint32(ap.Mode)),
+			"MaxBitrate": readable(ap.MaxBitrate),
+			"LastSeen":   readable(ap.LastSeen),
+		}})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	d.APs = append(d.APs, ap)
+	pp := []dbus.ObjectPath{}
+	for _, ap := range d.APs {
+		pp = append(pp, ap.path)
+	}
+	d.props.SetMust(nm.DeviceWirelessInterface, "AccessPoints", pp)
+	return nil
+}
+
+func (d *Device) ap(path dbus.ObjectPath) *AP {
+	for _, ap := range d.APs {
+		if ap.path == path {
+			return ap
+		}
+	}
+	return nil
+}
+
+func (d *Device) apOf(SSID string) *AP {
+	for _, ap := range d.APs {
+		if ap.SSID == SSID {
+			return ap
+		}
+	}
+	return nil
+}
+
+// setState sets given state and reason.  NOTE setState expects the
+// stub's mutex to be locked.
+func (d *Device) setState(
+	state nm.NmDeviceState, reason nm.NmDeviceStateReason,
+) {
+	d.State = state
+	d.props.SetMust(nm.DeviceInterface, "State", uint32(state))
+	d.props.SetMust(nm.DeviceInterface, "StateReason",
+		stateReason{uint32(state), uint32(reason)})
+}
+
+// setActive sets the active connection a and the active access point
+// ap.  NOTE setActive expects the stub's mutex to be locked.
+func (d *Device) setActive(a *Active, ap *AP) {
+	d.active = a
+	path := noPath
+	if a != nil {
+		path = a.path
+	}
+	d.props.SetMust(nm.DeviceInterface, "ActiveConnection", path)
+	if d.Type != nm.NmDeviceTypeWifi {
+		return
+	}
+	path = noPath
+	if ap != nil {
+		path = ap.path
+	}
+	d.props.SetMust(nm.DeviceWirelessInterface, "ActiveAccessPoint", path)
+}
+
+// ActiveAP returns the access point device d is connected to or nil.
+func (d *Device) ActiveAP() *AP {
+	d.stub.mutex.Lock()
+	defer d.stub.mutex.Unlock()
+	if d.active == nil || d.State != nm.NmDeviceStateActivated {
+		return nil
+	}
+	return d.active.ap
+}
+
+// CurrentState returns the device's state.
+func (d *Device) CurrentState() nm.NmDeviceState {
+	d.stub.mutex.Lock()
+	defer d.stub.mutex.Unlock()
+	return d.State
+}
+
+func (d *Device) disconnect() *dbus.Error {
+	d.stub.mutex.Lock()
+	active := d.State == nm.NmDeviceStateActivated
+	d.stub.mutex.Unlock()
+	if !active {
+		return dbus.NewError(nm.DeviceInterface+".NotActive",
+			[]interface{}{"device is not active"})
+	}
+	go d.stub.deactivate(d, nm.NmDeviceStateReasonUserRequested)
+	return nil
+}
+
+// requestScan updates the LastScan property after the stub's step
+// delay and emits its change.
+func (d *Device) requestScan(map[string]dbus.Variant) *dbus.Error {
+	go func() {
+		time.Sleep(d.stub.Step)
+		d.stub.mutex.Lock()
+		defer d.stub.mutex.Unlock()
+		d.lastScan++
+		d.props.SetMust(nm.DeviceWirelessInterface, "LastScan",
+			d.lastScan)
+		d.stub.emit(d.path, nm.DeviceWirelessInterface,
+			map[string]dbus.Variant{
+				"LastScan": dbus.MakeVariant(d.lastScan)})
+	}()
+	return nil
+}
+
+func (d *Device) accessPoints() ([]dbus.ObjectPath, *dbus.Error) {
+	d.stub.mutex.Lock()
+	defer d.stub.mutex.Unlock()
+	pp := []dbus.ObjectPath{}
+	for _, ap := range d.APs {
+		pp = append(pp, ap.path)
+	}
+	return pp, nil
+}
+
+// AP is a stubbed access point.  Its Password is the secret a profile
+// must provide to activate a connection to it.
+type AP struct {
+	SSID, BSSID               string
+	Strength                  uint8
+	Frequency                 uint32
+	Flags, WPAFlags, RSNFlags uint32
+	Mode                      nm.Nm80211Mode
+	MaxBitrate                uint32
+	LastSeen                  int32
+	Password                  string
+
+	path dbus.ObjectPath
+}
+
+// Profile is a stubbed connection profile.
+type Profile struct {
+	stub     *Stub
+	path     dbus.ObjectPath
+	props    *prop.Properties
+	settings Settings
+}
+
+// secretKeys are the keys of setting values which are secrets.
+var secretKeys = map[string]bool{"psk": true, "wep-key0": true,
+	"wep-key1": true, "wep-key2": true, "wep-key3": true,
+	"password": true, "private-key-password": true}
+
+// AddProfile exports a saved connection profile with given settings.
+func (s *Stub) AddProfile(settings Settings) (*Profile, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.addProfile(settings, false)
+}
+
+// addProfile expects the stub's mutex to be locked.
+func (s *Stub) addProfile(
+	settings Settings, unsaved bool,
+) (*Profile, error) {
+	p := &Profile{stub: s, path: s.path("Settings")}
+	p.settings = copySettings(settings)
+	err := s.conn.ExportMethodTable(map[string]interface{}{
+		"GetSettings":   p.getSettings,
+		"GetSecrets":    p.getSecrets,
+		"Update":        p.update,
+		"UpdateUnsaved": p.updateUnsaved,
+		"Save":          p.save,
+		"Delete":        p.delete,
+	}, p.path, nm.ConnectionInterface)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	p.props, err = prop.Export(s.conn, p.path, prop.Map{
+		nm.ConnectionInterface: {
+			"Unsaved":  readable(unsaved),
+			"Flags":    readable(uint32(0)),
+			"Filename": readable(""),
+		}})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	s.profiles = append(s.profiles, p)
+	s.updateProfiles()
+	return p, nil
+}
+
+// SSID returns the SSID of a wifi profile.  NOTE SSID expects the
+// stub's mutex to be locked.
+func (p *Profile) SSID() string {
+	ssid, _ := p.settings[wirelessSettings]["ssid"].Value().([]byte)
+	return string(ssid)
+}
+
+// Settings returns a copy of the profile's settings including its
+// secrets.
+func (p *Profile) Settings() Settings {
+	p.stub.mutex.Lock()
+	defer p.stub.mutex.Unlock()
+	return copySettings(p.settings)
+}
+
+// Unsaved reports if the profile is not persisted.
+func (p *Profile) Unsaved() bool {
+	p.stub.mutex.Lock()
+	defer p.stub.mutex.Unlock()
+	v, err := p.props.Get(nm.ConnectionInterface, "Unsaved")
+	if err != nil {
+		return false
+	}
+	unsaved, _ := v.Value().(bool)
+	return unsaved
+}
+
+// secret returns the profile's psk or WEP key.  NOTE secret expects
+// the stub's mutex to be locked.
+func (p *Profile) secret() string {
+	security := p.settings[wirelessSecurity]
+	if psk, ok := security["psk"].Value().(string); ok {
+		return psk
+	}
+	key, _ := security["wep-key0"].Value().(string)
+	return key
+}
+
+func (p *Profile) copySettings() Settings { return copySettings(p.settings) }
+
+func (p *Profile) getSettings() (Settings, *dbus.Error) {
+	p.stub.mutex.Lock()
+	defer p.stub.mutex.Unlock()
+	ss := copySettings(p.settings)
+	for _, setting := range ss {
+		for k := range setting {
+			if secretKeys[k] {
+				delete(setting, k)
+			}
+		}
+	}
+	return ss, nil
+}
+
+func (p *Profile) getSecrets(name string) (Settings, *dbus.Error) {
+	p.stub.mutex.Lock()
+	defer p.stub.mutex.Unlock()
+	secrets := map[string]dbus.Variant{}
+	for k, v := range p.settings[name] {
+		if secretKeys[k] {
+			secrets[k] = v
+		}
+	}
+	return Settings{name: secrets}, nil
+}
+
+func (p *Profile) update(settings Settings) *dbus.Error {
+	p.stub.mutex.Lock()
+	defer p.stub.mutex.Unlock()
+	p.settings = copySettings(settings)
+	p.props.SetMust(nm.ConnectionInterface, "Unsaved", false)
+	return nil
+}
+
+func (p *Profile) updateUnsaved(settings Settings) *dbus.Error {
+	p.stub.mutex.Lock()
+	defer p.stub.mutex.Unlock()
+	p.settings = copySettings(settings)
+	p.props.SetMust(nm.ConnectionInterface, "Unsaved", true)
+	return nil
+}
+
+func (p *Profile) save() *dbus.Error {
+	p.stub.mutex.Lock()
+	defer p.stub.mutex.Unlock()
+	p.props.SetMust(nm.ConnectionInterface, "Unsaved", false)
+	return nil
+}
+
+// delete removes the profile and deactivates the devices it is active
+// on.
+func (p *Profile) delete() *dbus.Error {
+	s := p.stub
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, p_ := range s.profiles {
+		if p_ != p {
+			continue
+		}
+		s.profiles = append(s.profiles[:i], s.profiles[i+1:]...)
+		s.updateProfiles()
+		s.conn.Export(nil, p.path, nm.ConnectionInterface)
+		s.conn.Export(nil, p.path, dbusProperties)
+		for _, d := range s.devices {
+			if d.active != nil && d.active.profile == p {
+				go s.deactivate(d, nm.NmDeviceStateReasonConnectionRemoved)
+			}
+		}
+		return nil
+	}
+	return dbus.MakeFailedError(fmt.Errorf("%w: unknown profile", ErrStub))
+}
+
+func (s *Stub) listConnections() ([]dbus.ObjectPath, *dbus.Error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pp := []dbus.ObjectPath{}
+	for _, p := range s.profiles {
+		pp = append(pp, p.path)
+	}
+	return pp, nil
+}
+
+func (s *Stub) connectionByUUID(uuid string) (
+	dbus.ObjectPath, *dbus.Error,
+) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, p := range s.profiles {
+		if id, _ := p.settings["connection"]["uuid"].Value().(string); id == uuid {
+			return p.path, nil
+		}
+	}
+	return noPath, dbus.NewError(nm.SettingsInterface+
+		".InvalidConnection", []interface{}{"unknown uuid: " + uuid})
+}
+
+func (s *Stub) addConnection(
+	settings Settings,
+) (dbus.ObjectPath, *dbus.Error) {
+	return s.addConnectionSaved(settings, false)
+}
+
+func (s *Stub) addConnectionUnsaved(
+	settings Settings,
+) (dbus.ObjectPath, *dbus.Error) {
+	return s.addConnectionSaved(settings, true)
+}
+
+func (s *Stub) addConnectionSaved(
+	settings Settings, unsaved bool,
+) (dbus.ObjectPath, *dbus.Error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p, err := s.addProfile(settings, unsaved)
+	if err != nil {
+		return noPath, dbus.MakeFailedError(err)
+	}
+	return p.path, nil
+}
+
+func copySettings(ss Settings) Settings {
+	cp := Settings{}
+	for name, setting := range ss {
+		cp[name] = map[string]dbus.Variant{}
+		for k, v := range setting {
+			cp[name][k] = v
+		}
+	}
+	return cp
+}
+
+// Active is a stubbed active connection.
+type Active struct {
+	path    dbus.ObjectPath
+	props   *prop.Properties
+	device  *Device
+	profile *Profile
+	ap      *AP
+}
+
+// newActive exports a new active connection of given device d with
+// given profile p at given access point ap.  NOTE newActive expects the
+// stub's mutex to be locked.
+func (s *Stub) newActive(d *Device, p *Profile, ap *AP) (*Active, error) {
+	a := &Active{path: s.path("ActiveConnection"), device: d,
+		profile: p, ap: ap}
+	specific := noPath
+	if ap != nil {
+		specific = ap.path
+	}
+	id, _ := p.settings["connection"]["id"].Value().(string)
+	uuid, _ := p.settings["connection"]["uuid"].Value().(string)
+	type_, _ := p.settings["connection"]["type"].Value().(string)
+	var err error
+	a.props, err = prop.Export(s.conn, a.path, prop.Map{
+		nm.ActiveConnectionInterface: {
+			"Connection":     readable(p.path),
+			"SpecificObject": readable(specific),
+			"Id":             readable(id),
+			"Uuid":           readable(uuid),
+			"Type":           readable(type_),
+			"Devices":        readable([]dbus.ObjectPath{d.path}),
+			"State": readable(uint32(
+				nm.NmActiveConnectionStateActivating)),
+			"Vpn": readable(false),
+		}})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStub, err)
+	}
+	s.actives = append(s.actives, a)
+	s.updateActives()
+	return a, nil
+}
+
+// setState expects the stub's mutex to be locked.
+func (a *Active) setState(state nm.NmActiveConnectionState) {
+	a.props.SetMust(nm.ActiveConnectionInterface, "State", uint32(state))
+}
+
+// removeActive unexports given active connection a.  NOTE removeActive
+// expects the stub's mutex to be locked.
+func (s *Stub) removeActive(a *Active) {
+	for i, a_ := range s.actives {
+		if a_ != a {
+			continue
+		}
+		s.actives = append(s.actives[:i], s.actives[i+1:]...)
+		s.updateActives()
+		s.conn.Export(nil, a.path, dbusProperties)
+		break
+	}
+	if a.device.active == a {
+		a.device.setActive(nil, nil)
+	}
+}
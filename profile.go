@@ -0,0 +1,312 @@
+package wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+)
+
+// Profile identifies a connection profile of a wifi access point and
+// describes how NetworkManager uses it.
+type Profile struct {
+	ID       string   `json:"id"`
+	UUID     string   `json:"uuid"`
+	SSID     string   `json:"ssid"`
+	Security Security `json:"security"`
+
+	// Autoconnect is true if NetworkManager activates the profile
+	// automatically; candidates of a higher Priority are preferred.
+	Autoconnect bool  `json:"autoconnect"`
+	Priority    int32 `json:"priority"`
+
+	// LastUsed is the time in seconds since the unix epoch the profile
+	// was last activated successfully; it is zero if it never was.
+	LastUsed uint64 `json:"last_used"`
+
+	// Interface is the name of the interface the profile is bound to;
+	// it is the zero string if the profile applies to any interface.
+	Interface string `json:"interface"`
+}
+
+var ErrProfiles = errors.New("client: profiles")
+
+// Profiles returns the connection profiles of wifi access points known
+// to NetworkManager.
+func (c *Client) Profiles(ctx context.Context) ([]Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProfiles, err)
+	}
+	ss, err := c.lib().NewSettings()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProfiles, err)
+	}
+	cc, err := ss.ListConnections()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProfiles, err)
+	}
+	pp := []Profile{}
+	for _, cnn := range cc {
+		ss, err := cnn.GetSettings()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrProfiles, err)
+		}
+		if _, ok := ss[wirelessSettings]; !ok {
+			continue
+		}
+		pp = append(pp, profileOf(ss))
+	}
+	return pp, nil
+}
+
+// profileOf returns the profile of given wifi connection settings ss.
+// NOTE settings NetworkManager omits because they have their default
+// value are reported with this default.
+func profileOf(ss nm.ConnectionSettings) Profile {
+	p := Profile{Autoconnect: true}
+	p.ID, _ = ss["connection"]["id"].(string)
+	p.UUID, _ = ss["connection"]["uuid"].(string)
+	ssid, _ := ss[wirelessSettings]["ssid"].([]uint8)
+	p.SSID = string(ssid)
+	p.Security = profileSecurity(ss)
+	if autoconnect, ok := ss["connection"]["autoconnect"].(bool); ok {
+		p.Autoconnect = autoconnect
+	}
+	p.Priority, _ = ss["connection"]["autoconnect-priority"].(int32)
+	p.LastUsed, _ = ss["connection"]["timestamp"].(uint64)
+	p.Interface, _ = ss["connection"]["interface-name"].(string)
+	return p
+}
+
+// profileSecurity returns the security of given wifi connection
+// settings ss as determined by their key management.
+func profileSecurity(ss nm.ConnectionSettings) Security {
+	keyMgmt, ok := ss[wirelessSecurity]["key-mgmt"].(string)
+	if !ok {
+		return SecurityOpen
+	}
+	switch keyMgmt {
+	case "none":
+		return SecurityWEP
+	case "ieee8021x", "wpa-eap", "wpa-eap-suite-b-192":
+		return SecurityEnterprise
+	}
+	return Security(keyMgmt)
+}
+
+// MaskedSecret replaces the secrets of profile settings which are not
+// requested.
+const MaskedSecret = "********"
+
+// secretSettings are the settings of a wifi profile which may hold
+// secrets.
+var secretSettings = []string{wirelessSecurity, "802-1x"}
+
+// secretKeys are the keys of setting values which are secrets.
+var secretKeys = map[string]bool{"psk": true, "wep-key0": true,
+	"wep-key1": true, "wep-key2": true, "wep-key3": true,
+	"leap-password": true, "password": true, "pin": true,
+	"private-key-password": true, "phase2-private-key-password": true}
+
+// ProfileSelector selects the wifi connection profiles whose SSID,
+// UUID and id match its set properties, i.e. the zero value selects all
+// wifi profiles.
+type ProfileSelector struct {
+	SSID string
+	UUID string
+	ID   string
+}
+
+// matches returns true if given profile p is selected by given selector
+// s.
+func (s ProfileSelector) matches(p Profile) bool {
+	return (s.SSID == "" || s.SSID == p.SSID) &&
+		(s.UUID == "" || s.UUID == p.UUID) &&
+		(s.ID == "" || s.ID == p.ID)
+}
+
+func (s ProfileSelector) String() string {
+	ss := []string{}
+	if s.SSID != "" {
+		ss = append(ss, fmt.Sprintf("SSID '%s'", s.SSID))
+	}
+	if s.UUID != "" {
+		ss = append(ss, fmt.Sprintf("UUID '%s'", s.UUID))
+	}
+	if s.ID != "" {
+		ss = append(ss, fmt.Sprintf("id '%s'", s.ID))
+	}
+	if len(ss) == 0 {
+		return "any profile"
+	}
+	return strings.Join(ss, ", ")
+}
+
+var ErrNoProfile = errors.New("no matching configuration")
+var ErrAmbiguousProfile = errors.New("ambiguous configuration")
+
+var ErrProfile = errors.New("client: profile")
+
+// ProfileSettings returns all settings of the wifi connection profile
+// selected by given selector sel.  Secrets are masked by MaskedSecret
+// unless given secrets flag is set in which case they are requested
+// from NetworkManager.  ProfileSettings fails with ErrNoProfile or
+// ErrAmbiguousProfile unless exactly one profile is selected.
+func (c *Client) ProfileSettings(
+	ctx context.Context, sel ProfileSelector, secrets bool,
+) (nm.ConnectionSettings, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrProfile, sel, err)
+	}
+	cnn, _, err := c.profileConnection(sel)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProfile, err)
+	}
+	if cnn == nil {
+		return nil, fmt.Errorf("%w: %w for %s",
+			ErrProfile, ErrNoProfile, sel)
+	}
+	ss, err := cnn.GetSettings()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrProfile, sel, err)
+	}
+	for _, setting := range ss {
+		for k := range setting {
+			if secretKeys[k] {
+				setting[k] = MaskedSecret
+			}
+		}
+	}
+	if !secrets {
+		return ss, nil
+	}
+	if err := mergeSecrets(cnn, ss); err != nil {
+		return nil, fmt.Errorf("%w: %s: secrets: %w", ErrProfile, sel, err)
+	}
+	return ss, nil
+}
+
+// mergeSecrets requests the secrets of given connection profile cnn
+// from NetworkManager and sets them in given settings ss of cnn.
+func mergeSecrets(cnn nm.Connection, ss nm.ConnectionSettings) error {
+	for _, name := range secretSettings {
+		if _, ok := ss[name]; !ok {
+			continue
+		}
+		ssSecrets, err := cnn.GetSecrets(name)
+		if err != nil {
+			return err
+		}
+		for k, v := range ssSecrets[name] {
+			ss[name][k] = v
+		}
+	}
+	return nil
+}
+
+var ErrProfileDelete = errors.New(
+	"client: delete access-point configuration")
+
+// DeleteProfile deletes the connection profile selected by given
+// selector sel.  DeleteProfile fails with ErrNoProfile or
+// ErrAmbiguousProfile unless exactly one profile is selected.
+func (c *Client) DeleteProfile(
+	ctx context.Context, sel ProfileSelector,
+) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrProfileDelete, sel, err)
+	}
+	cnn, _, err := c.profileConnection(sel)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrProfileDelete, err)
+	}
+	if cnn == nil {
+		return fmt.Errorf("%w: %w for %s",
+			ErrProfileDelete, ErrNoProfile, sel)
+	}
+	if err := cnn.Delete(); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrProfileDelete, sel, err)
+	}
+	return nil
+}
+
+// DeleteProfiles deletes all connection profiles selected by given
+// selector sel and returns them.  DeleteProfiles fails with
+// ErrNoProfile if no profile is selected.
+func (c *Client) DeleteProfiles(
+	ctx context.Context, sel ProfileSelector,
+) ([]Profile, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrProfileDelete, sel, err)
+	}
+	cc, pp, err := c.profileConnections(sel)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrProfileDelete, sel, err)
+	}
+	if len(cc) == 0 {
+		return nil, fmt.Errorf("%w: %w for %s",
+			ErrProfileDelete, ErrNoProfile, sel)
+	}
+	for i, cnn := range cc {
+		if err := cnn.Delete(); err != nil {
+			return pp[:i], fmt.Errorf("%w: %s: '%s': %w",
+				ErrProfileDelete, sel, pp[i].UUID, err)
+		}
+	}
+	return pp, nil
+}
+
+// profileConnection returns the wifi connection profile selected by
+// given selector sel or nil if none is selected.  profileConnection
+// fails with ErrAmbiguousProfile if sel selects several profiles.
+func (c *Client) profileConnection(sel ProfileSelector) (
+	nm.Connection, Profile, error,
+) {
+	cc, pp, err := c.profileConnections(sel)
+	if err != nil {
+		return nil, Profile{}, fmt.Errorf("%s: %w", sel, err)
+	}
+	switch len(cc) {
+	case 0:
+		return nil, Profile{}, nil
+	case 1:
+		return cc[0], pp[0], nil
+	}
+	ss := []string{}
+	for _, p := range pp {
+		ss = append(ss, fmt.Sprintf("'%s' (%s)", p.ID, p.UUID))
+	}
+	return nil, Profile{}, fmt.Errorf("%w: %s selects %s",
+		ErrAmbiguousProfile, sel, strings.Join(ss, ", "))
+}
+
+// profileConnections returns the wifi connection profiles selected by
+// given selector sel and their descriptions.
+func (c *Client) profileConnections(sel ProfileSelector) (
+	[]nm.Connection, []Profile, error,
+) {
+	ss, err := c.lib().NewSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+	cc, err := ss.ListConnections()
+	if err != nil {
+		return nil, nil, err
+	}
+	selected, pp := []nm.Connection{}, []Profile{}
+	for _, cnn := range cc {
+		ss, err := cnn.GetSettings()
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := ss[wirelessSettings]; !ok {
+			continue
+		}
+		if p := profileOf(ss); sel.matches(p) {
+			selected, pp = append(selected, cnn), append(pp, p)
+		}
+	}
+	return selected, pp, nil
+}
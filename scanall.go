@@ -0,0 +1,113 @@
+package wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Sighting reports the signal strength at which an adapter sees an
+// access point.
+type Sighting struct {
+	Adapter  string `json:"adapter"`
+	Strength uint8  `json:"strength"`
+}
+
+// MergedAccessPoint is an access point seen by one or more adapters.
+// Its properties are the properties reported by the adapter seeing it
+// strongest.
+type MergedAccessPoint struct {
+	AccessPoint
+
+	// SeenBy lists the adapters seeing the access point descending by
+	// signal strength.
+	SeenBy []Sighting `json:"seen_by"`
+}
+
+// MergedScan is the result of scanning with several adapters.
+type MergedScan struct {
+
+	// AccessPoints are the access points found by all adapters merged
+	// by their BSSID.
+	AccessPoints []MergedAccessPoint
+
+	// Errors maps the names of adapters whose scan failed to their
+	// error.
+	Errors map[string]error
+}
+
+var ErrScanAll = errors.New("client: scan all adapters")
+
+// ScanAll scans concurrently with all wifi adapters and merges the found
+// access points by their BSSID.  A failing adapter scan doesn't abort
+// ScanAll but is reported in the returned MergedScan's Errors.  ScanAll
+// fails if the adapters can't be obtained or no adapter scan succeeds.
+// Given hidden SSIDs are probed by each adapter, see WifiAdapter.Scan.
+func (c *Client) ScanAll(
+	ctx context.Context, hidden ...string,
+) (*MergedScan, error) {
+	aa, err := c.Adapters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrScanAll, err)
+	}
+	if len(aa) == 0 {
+		return nil, fmt.Errorf("%w: %w", ErrScanAll, ErrNoWifi)
+	}
+	scans := make([][]AccessPoint, len(aa))
+	errs := make([]error, len(aa))
+	wg := sync.WaitGroup{}
+	for i, a := range aa {
+		wg.Add(1)
+		go func(i int, a *WifiAdapter) {
+			defer wg.Done()
+			scans[i], errs[i] = a.Scan(ctx, hidden...)
+		}(i, a)
+	}
+	wg.Wait()
+	merged := &MergedScan{Errors: map[string]error{}}
+	byBSSID := map[string]*MergedAccessPoint{}
+	for i, a := range aa {
+		if errs[i] != nil {
+			merged.Errors[a.Name()] = errs[i]
+			continue
+		}
+		for _, ap := range scans[i] {
+			m, ok := byBSSID[ap.BSSID]
+			if !ok {
+				m = &MergedAccessPoint{AccessPoint: ap}
+				byBSSID[ap.BSSID] = m
+			}
+			if ap.Strength > m.Strength {
+				m.AccessPoint = ap
+			}
+			m.SeenBy = append(m.SeenBy, Sighting{
+				Adapter: a.Name(), Strength: ap.Strength})
+		}
+	}
+	if len(merged.Errors) == len(aa) {
+		return nil, fmt.Errorf("%w: %w",
+			ErrScanAll, errors.Join(errs...))
+	}
+	merged.AccessPoints = []MergedAccessPoint{}
+	for _, m := range byBSSID {
+		sortSightings(m.SeenBy)
+		merged.AccessPoints = append(merged.AccessPoints, *m)
+	}
+	sortAccessPoints(merged.AccessPoints, func(i int) *AccessPoint {
+		return &merged.AccessPoints[i].AccessPoint
+	})
+	return merged, nil
+}
+
+// sortSightings sorts given sightings descending by strength and
+// ascending by adapter name.
+func sortSightings(ss []Sighting) {
+	sort.Slice(ss, func(i, j int) bool {
+		if ss[i].Strength == ss[j].Strength {
+			return ss[i].Adapter < ss[j].Adapter
+		}
+		return ss[i].Strength > ss[j].Strength
+	})
+}
@@ -0,0 +1,64 @@
+package wifi
+
+import (
+	"testing"
+
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	. "github.com/slukits/gounit"
+)
+
+type AllAdaptersScan struct{ Suite }
+
+func (s *AllAdaptersScan) SetUp(t *T) { t.Parallel() }
+
+// twoRadios returns a client of the default fake network manager with a
+// second wifi device "wlan1" seeing "home" weaker than "wlan0" and the
+// access point "lab" which "wlan0" doesn't see.
+func twoRadios() (*Client, *nmfake.NM) {
+	fake := nmfake.Default()
+	wlan1 := fake.AddDevice("wlan1", nm.NmDeviceTypeWifi,
+		nm.NmDeviceStateDisconnected)
+	wlan1.AddAP(&nmfake.AP{SSID: "home", BSSID: "00:00:00:00:00:01",
+		Strength: 30, Frequency: 2412})
+	wlan1.AddAP(&nmfake.AP{SSID: "lab", BSSID: "00:00:00:00:00:10",
+		Strength: 90, Frequency: 5200})
+	return mckFakeNM(&Client{}, fake), fake
+}
+
+func (s *AllAdaptersScan) Merges_access_points_by_BSSID(t *T) {
+	client, _ := twoRadios()
+	merged, err := client.ScanAll(bg)
+	t.FatalOn(err)
+	t.Eq(0, len(merged.Errors))
+	t.FatalIfNot(t.Eq(4, len(merged.AccessPoints)))
+	t.Eq("lab", merged.AccessPoints[0].SSID)
+	home := merged.AccessPoints[1]
+	t.Eq("home", home.SSID)
+	t.Eq(uint8(80), home.Strength)
+	t.Eq([]Sighting{{Adapter: "wlan0", Strength: 80},
+		{Adapter: "wlan1", Strength: 30}}, home.SeenBy)
+}
+
+func (s *AllAdaptersScan) Reports_failing_adapters_separately(t *T) {
+	client, fake := twoRadios()
+	fake.Device("wlan1").State = nm.NmDeviceStateUnavailable
+	merged, err := client.ScanAll(bg)
+	t.FatalOn(err)
+	t.ErrIs(merged.Errors["wlan1"], ErrAdapterScan)
+	t.Eq(3, len(merged.AccessPoints))
+}
+
+func (s *AllAdaptersScan) Fails_if_all_adapters_fail(t *T) {
+	client, fake := twoRadios()
+	fake.Device("wlan0").State = nm.NmDeviceStateUnavailable
+	fake.Device("wlan1").State = nm.NmDeviceStateUnavailable
+	_, err := client.ScanAll(bg)
+	t.ErrIs(err, ErrScanAll)
+	t.ErrIs(err, ErrAdapterScan)
+}
+
+func TestAllAdaptersScan(t *testing.T) {
+	t.Parallel()
+	Run(&AllAdaptersScan{}, t)
+}
@@ -0,0 +1,129 @@
+package wifi
+
+import (
+	"errors"
+	"fmt"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+)
+
+// Security identifies the key management an access point requires and
+// hence the 802-11-wireless-security section a connection profile for
+// this access point needs.
+type Security string
+
+const (
+	SecurityOpen       Security = "open"
+	SecurityOWE        Security = "owe"
+	SecurityWEP        Security = "wep"
+	SecurityWPAPSK     Security = "wpa-psk"
+	SecuritySAE        Security = "sae"
+	SecurityEnterprise Security = "wpa-eap"
+)
+
+// NeedsPassword returns true if a connection with given security s can
+// only be established with a password.
+func (s Security) NeedsPassword() bool {
+	return s == SecurityWEP || s == SecurityWPAPSK || s == SecuritySAE
+}
+
+var ErrAPSecurity = errors.New("access point: security")
+
+// securityOf determines the security of given access point ap from its
+// Flags, WpaFlags and RsnFlags properties.
+func securityOf(ap nm.AccessPoint) (Security, error) {
+	flags, err := ap.GetPropertyFlags()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrAPSecurity, err)
+	}
+	wpa, err := ap.GetPropertyWPAFlags()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrAPSecurity, err)
+	}
+	rsn, err := ap.GetPropertyRSNFlags()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrAPSecurity, err)
+	}
+	return security(nm.Nm80211APFlags(flags),
+		nm.Nm80211APSec(wpa), nm.Nm80211APSec(rsn)), nil
+}
+
+// security maps given access point flags to a Security.  NOTE an access
+// point in WPA2/WPA3 transition mode advertises PSK and SAE in which
+// case wpa-psk is chosen since it works with both.
+func security(flags nm.Nm80211APFlags, wpa, rsn nm.Nm80211APSec) Security {
+	keyMgmt := wpa | rsn
+	switch {
+	case keyMgmt&nm.Nm80211APSecKeyMgmt8021X != 0:
+		return SecurityEnterprise
+	case keyMgmt&nm.Nm80211APSecKeyMgmtPSK != 0:
+		return SecurityWPAPSK
+	case keyMgmt&nm.Nm80211APSecKeyMgmtSAE != 0:
+		return SecuritySAE
+	case keyMgmt&(nm.Nm80211APSecKeyMgmtOWE|
+		nm.Nm80211APSecKeyMgmtOWETM) != 0:
+		return SecurityOWE
+	case flags&nm.Nm80211APFlagsPrivacy != 0:
+		return SecurityWEP
+	}
+	return SecurityOpen
+}
+
+// wirelessSecuritySettings returns the 802-11-wireless-security section
+// for given security s and password pwd or nil if s is open.
+func wirelessSecuritySettings(
+	s Security, pwd string,
+) map[string]interface{} {
+	switch s {
+	case SecurityOWE:
+		return map[string]interface{}{"key-mgmt": "owe"}
+	case SecurityWEP:
+		return map[string]interface{}{
+			"key-mgmt":     "none",
+			"auth-alg":     "open",
+			"wep-key0":     pwd,
+			"wep-key-type": wepKeyType(pwd),
+		}
+	case SecurityWPAPSK:
+		return map[string]interface{}{
+			"key-mgmt": "wpa-psk",
+			"auth-alg": "open",
+			"psk":      pwd,
+		}
+	case SecuritySAE:
+		return map[string]interface{}{
+			"key-mgmt": "sae",
+			"psk":      pwd,
+		}
+	case SecurityEnterprise:
+		return map[string]interface{}{"key-mgmt": "wpa-eap"}
+	}
+	return nil
+}
+
+const (
+	wepKeyTypeKey        uint32 = 1
+	wepKeyTypePassphrase uint32 = 2
+)
+
+// wepKeyType returns wepKeyTypeKey if given WEP password pwd is a 40/104
+// bit key in ASCII or hex notation and wepKeyTypePassphrase otherwise.
+func wepKeyType(pwd string) uint32 {
+	switch len(pwd) {
+	case 5, 13:
+		return wepKeyTypeKey
+	case 10, 26:
+		for _, r := range pwd {
+			if !isHex(r) {
+				return wepKeyTypePassphrase
+			}
+		}
+		return wepKeyTypeKey
+	}
+	return wepKeyTypePassphrase
+}
+
+func isHex(r rune) bool {
+	return '0' <= r && r <= '9' || 'a' <= r && r <= 'f' ||
+		'A' <= r && r <= 'F'
+}
@@ -0,0 +1,135 @@
+package wifi
+
+import (
+	"errors"
+	"testing"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	. "github.com/slukits/gounit"
+)
+
+type ASecurity struct{ Suite }
+
+func (s *ASecurity) SetUp(t *T) { t.Parallel() }
+
+func (s *ASecurity) Is_open_without_privacy_and_key_management(t *T) {
+	t.Eq(SecurityOpen, security(nm.Nm80211APFlagsNone,
+		nm.Nm80211APSecNone, nm.Nm80211APSecNone))
+}
+
+func (s *ASecurity) Is_wep_with_privacy_but_no_key_management(t *T) {
+	t.Eq(SecurityWEP, security(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone, nm.Nm80211APSecNone))
+}
+
+func (s *ASecurity) Is_wpa_psk_if_wpa_or_rsn_advertise_psk(t *T) {
+	t.Eq(SecurityWPAPSK, security(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecKeyMgmtPSK, nm.Nm80211APSecNone))
+	t.Eq(SecurityWPAPSK, security(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone, nm.Nm80211APSecKeyMgmtPSK))
+}
+
+func (s *ASecurity) Prefers_psk_in_wpa3_transition_mode(t *T) {
+	t.Eq(SecurityWPAPSK, security(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone,
+		nm.Nm80211APSecKeyMgmtPSK|nm.Nm80211APSecKeyMgmtSAE))
+}
+
+func (s *ASecurity) Is_sae_if_only_sae_is_advertised(t *T) {
+	t.Eq(SecuritySAE, security(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone, nm.Nm80211APSecKeyMgmtSAE))
+}
+
+func (s *ASecurity) Is_owe_if_owe_or_owe_transition_is_advertised(t *T) {
+	t.Eq(SecurityOWE, security(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone, nm.Nm80211APSecKeyMgmtOWE))
+	t.Eq(SecurityOWE, security(nm.Nm80211APFlagsNone,
+		nm.Nm80211APSecNone, nm.Nm80211APSecKeyMgmtOWETM))
+}
+
+func (s *ASecurity) Is_enterprise_if_802_1x_is_advertised(t *T) {
+	t.Eq(SecurityEnterprise, security(nm.Nm80211APFlagsPrivacy,
+		nm.Nm80211APSecNone, nm.Nm80211APSecKeyMgmt8021X))
+}
+
+func (s *ASecurity) Needs_a_password_for_wep_psk_and_sae_only(t *T) {
+	t.True(SecurityWEP.NeedsPassword())
+	t.True(SecurityWPAPSK.NeedsPassword())
+	t.True(SecuritySAE.NeedsPassword())
+	t.Not.True(SecurityOpen.NeedsPassword())
+	t.Not.True(SecurityOWE.NeedsPassword())
+	t.Not.True(SecurityEnterprise.NeedsPassword())
+}
+
+func (s *ASecurity) Is_read_from_an_access_point_s_flags(t *T) {
+	sec, err := securityOf(&MckSecurityAP{rsn: uint32(
+		nm.Nm80211APSecKeyMgmtSAE)})
+	t.FatalOn(err)
+	t.Eq(SecuritySAE, sec)
+}
+
+func (s *ASecurity) Retrieval_fails_if_flags_are_unobtainable(t *T) {
+	_, err := securityOf(&MckSecurityAP{err: ErrMckSecurityAP})
+	t.ErrIs(err, ErrAPSecurity)
+	t.ErrIs(err, ErrMckSecurityAP)
+}
+
+func (s *ASecurity) Settings_omit_security_section_for_open_aps(t *T) {
+	ss := newConnectionSettings("open", "", SecurityOpen)
+	_, ok := ss[wirelessSecurity]
+	t.Not.True(ok)
+	_, ok = ss[wirelessSettings]["security"]
+	t.Not.True(ok)
+}
+
+func (s *ASecurity) Settings_have_key_management_of_security(t *T) {
+	for sec, keyMgmt := range map[Security]string{
+		SecurityOWE:    "owe",
+		SecurityWEP:    "none",
+		SecurityWPAPSK: "wpa-psk",
+		SecuritySAE:    "sae",
+	} {
+		ss := newConnectionSettings("ssid", "secret", sec)
+		t.Eq(wirelessSecurity, ss[wirelessSettings]["security"])
+		t.Eq(keyMgmt, ss[wirelessSecurity]["key-mgmt"])
+	}
+}
+
+func (s *ASecurity) Settings_store_password_as_psk_or_wep_key(t *T) {
+	ss := newConnectionSettings("ssid", "secret", SecuritySAE)
+	t.Eq("secret", ss[wirelessSecurity]["psk"])
+	ss = newConnectionSettings("ssid", "secret", SecurityWEP)
+	t.Eq("secret", ss[wirelessSecurity]["wep-key0"])
+}
+
+func (s *ASecurity) Wep_key_type_distinguishes_keys_and_phrases(t *T) {
+	t.Eq(wepKeyTypeKey, wepKeyType("abcde"))
+	t.Eq(wepKeyTypeKey, wepKeyType("0123456789"))
+	t.Eq(wepKeyTypePassphrase, wepKeyType("012345678z"))
+	t.Eq(wepKeyTypePassphrase, wepKeyType("a passphrase"))
+}
+
+func TestASecurity(t *testing.T) {
+	t.Parallel()
+	Run(&ASecurity{}, t)
+}
+
+var ErrMckSecurityAP = errors.New("access point flags error mock")
+
+type MckSecurityAP struct {
+	nm.AccessPoint
+	flags, wpa, rsn uint32
+	err             error
+}
+
+func (m *MckSecurityAP) GetPropertyFlags() (uint32, error) {
+	return m.flags, m.err
+}
+
+func (m *MckSecurityAP) GetPropertyWPAFlags() (uint32, error) {
+	return m.wpa, m.err
+}
+
+func (m *MckSecurityAP) GetPropertyRSNFlags() (uint32, error) {
+	return m.rsn, m.err
+}
@@ -0,0 +1,208 @@
+package wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Credentials are what a wifi QR code shares to join an access point.
+// Their String is the format phone cameras understand, e.g.
+//
+//	WIFI:T:WPA;S:guest;P:guest-secret;;
+type Credentials struct {
+	SSID     string   `json:"ssid"`
+	Security Security `json:"security"`
+	Password string   `json:"password,omitempty"`
+	Hidden   bool     `json:"hidden,omitempty"`
+}
+
+// qrTypes maps the upper case authentication types of the wifi QR code
+// format to securities.
+var qrTypes = map[string]Security{
+	"":       SecurityOpen,
+	"NOPASS": SecurityOpen,
+	"WEP":    SecurityWEP,
+	"WPA":    SecurityWPAPSK,
+	"WPA2":   SecurityWPAPSK,
+	"SAE":    SecuritySAE,
+	"WPA3":   SecuritySAE,
+}
+
+var ErrCredentials = errors.New("invalid wifi QR code")
+
+// String returns given credentials c in the wifi QR code format.  NOTE
+// SAE is reported as WPA which is what phone cameras understand for
+// both WPA2 and WPA3 access points.
+func (c Credentials) String() string {
+	b := &strings.Builder{}
+	switch c.Security {
+	case SecurityWEP:
+		b.WriteString("WIFI:T:WEP;")
+	case SecurityWPAPSK, SecuritySAE:
+		b.WriteString("WIFI:T:WPA;")
+	default:
+		b.WriteString("WIFI:T:nopass;")
+	}
+	fmt.Fprintf(b, "S:%s;", qrEscape(c.SSID))
+	if c.Security.NeedsPassword() {
+		fmt.Fprintf(b, "P:%s;", qrEscape(c.Password))
+	}
+	if c.Hidden {
+		b.WriteString("H:true;")
+	}
+	b.WriteString(";")
+	return b.String()
+}
+
+// ParseCredentials parses given string s in the wifi QR code format.
+// Unknown fields are ignored.  ParseCredentials fails with
+// ErrCredentials if s lacks the WIFI: prefix or an SSID, or if its
+// authentication type is unsupported, e.g. WPA2-EAP.
+func ParseCredentials(s string) (Credentials, error) {
+	c := Credentials{}
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(strings.ToUpper(s), "WIFI:") {
+		return c, fmt.Errorf("%w: missing WIFI: prefix", ErrCredentials)
+	}
+	type_ := ""
+	for _, field := range qrFields(s[len("WIFI:"):]) {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+		value = qrUnescape(value)
+		switch strings.ToUpper(key) {
+		case "T":
+			type_ = value
+		case "S":
+			c.SSID = qrUnquote(value)
+		case "P":
+			c.Password = qrUnquote(value)
+		case "H":
+			c.Hidden = strings.EqualFold(value, "true")
+		}
+	}
+	sec, ok := qrTypes[strings.ToUpper(type_)]
+	if !ok {
+		return c, fmt.Errorf("%w: unsupported type '%s'",
+			ErrCredentials, type_)
+	}
+	c.Security = sec
+	if c.SSID == "" {
+		return c, fmt.Errorf("%w: missing SSID", ErrCredentials)
+	}
+	if !sec.NeedsPassword() {
+		c.Password = ""
+	}
+	return c, nil
+}
+
+// qrSpecial are the characters escaped by a backslash in the fields of
+// the wifi QR code format.
+const qrSpecial = `\;,:"`
+
+func qrEscape(s string) string {
+	b := &strings.Builder{}
+	for _, r := range s {
+		if strings.ContainsRune(qrSpecial, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func qrUnescape(s string) string {
+	b, escaped := &strings.Builder{}, false
+	for _, r := range s {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// qrUnquote removes the double quotes some generators put around SSIDs
+// and passwords.
+func qrUnquote(s string) string {
+	if len(s) > 1 && strings.HasPrefix(s, `"`) &&
+		strings.HasSuffix(s, `"`) {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// qrFields splits given wifi QR code fields s at semicolons which are
+// not escaped; the fields keep their escapes.
+func qrFields(s string) []string {
+	ff, start, escaped := []string{}, 0, false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			ff, start = append(ff, s[start:i]), i+1
+		}
+	}
+	if start < len(s) {
+		ff = append(ff, s[start:])
+	}
+	return ff
+}
+
+var ErrShare = errors.New("client: share")
+
+// Share returns the credentials of the wifi connection profile
+// selected by given selector sel including its password which is
+// requested from NetworkManager.  Share fails with ErrNoProfile or
+// ErrAmbiguousProfile unless exactly one profile is selected and with
+// ErrUnsupportedSecurity for a WPA2/WPA3-Enterprise profile.
+func (c *Client) Share(
+	ctx context.Context, sel ProfileSelector,
+) (Credentials, error) {
+	if err := ctx.Err(); err != nil {
+		return Credentials{}, fmt.Errorf("%w: %s: %w", ErrShare, sel, err)
+	}
+	cnn, p, err := c.profileConnection(sel)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%w: %w", ErrShare, err)
+	}
+	if cnn == nil {
+		return Credentials{}, fmt.Errorf("%w: %w for %s",
+			ErrShare, ErrNoProfile, sel)
+	}
+	cr := Credentials{SSID: p.SSID, Security: p.Security}
+	switch p.Security {
+	case SecurityEnterprise:
+		return cr, fmt.Errorf("%w: %s: %w: %s",
+			ErrShare, sel, ErrUnsupportedSecurity, p.Security)
+	case SecurityOWE:
+		cr.Security = SecurityOpen
+	}
+	ss, err := cnn.GetSettings()
+	if err != nil {
+		return cr, fmt.Errorf("%w: %s: %w", ErrShare, sel, err)
+	}
+	cr.Hidden, _ = ss[wirelessSettings]["hidden"].(bool)
+	if !cr.Security.NeedsPassword() {
+		return cr, nil
+	}
+	secrets, err := cnn.GetSecrets(wirelessSecurity)
+	if err != nil {
+		return cr, fmt.Errorf("%w: %s: secrets: %w", ErrShare, sel, err)
+	}
+	key := "psk"
+	if cr.Security == SecurityWEP {
+		idx, _ := ss[wirelessSecurity]["wep-tx-keyidx"].(uint32)
+		key = fmt.Sprintf("wep-key%d", idx)
+	}
+	cr.Password, _ = secrets[wirelessSecurity][key].(string)
+	return cr, nil
+}
@@ -0,0 +1,101 @@
+package wifi
+
+import (
+	"errors"
+	"testing"
+
+	"example.com/wifi/nmfake"
+	. "github.com/slukits/gounit"
+)
+
+type SomeCredentials struct{ Suite }
+
+func (s *SomeCredentials) SetUp(t *T) { t.Parallel() }
+
+func (s *SomeCredentials) Are_formatted_as_wifi_QR_code(t *T) {
+	t.Eq("WIFI:T:WPA;S:guest;P:guest-secret;;", Credentials{
+		SSID: "guest", Security: SecurityWPAPSK,
+		Password: "guest-secret"}.String())
+	t.Eq(`WIFI:T:WPA;S:a\;b;P:c\:d\\e;H:true;;`, Credentials{
+		SSID: "a;b", Security: SecuritySAE, Password: `c:d\e`,
+		Hidden: true}.String())
+	t.Eq("WIFI:T:nopass;S:cafe;;", Credentials{SSID: "cafe",
+		Security: SecurityOpen, Password: "ignored"}.String())
+}
+
+func (s *SomeCredentials) Are_parsed_from_a_wifi_QR_code(t *T) {
+	exp := Credentials{SSID: "a;b", Security: SecurityWPAPSK,
+		Password: `c:d\e`, Hidden: true}
+	c, err := ParseCredentials(exp.String() + "\n")
+	t.FatalOn(err)
+	t.Eq(exp, c)
+	c, err = ParseCredentials(`WIFI:S:"guest";T:SAE;P:"secret";;`)
+	t.FatalOn(err)
+	t.Eq(Credentials{SSID: "guest", Security: SecuritySAE,
+		Password: "secret"}, c)
+	c, err = ParseCredentials("WIFI:S:cafe;;")
+	t.FatalOn(err)
+	t.Eq(Credentials{SSID: "cafe", Security: SecurityOpen}, c)
+}
+
+func (s *SomeCredentials) Fail_to_parse_if_invalid(t *T) {
+	for _, qr := range []string{
+		"WIFI:T:WPA;P:secret;;",
+		"T:WPA;S:guest;P:secret;;",
+		"WIFI:T:WPA2-EAP;S:corp;E:PEAP;I:me;P:secret;;",
+	} {
+		_, err := ParseCredentials(qr)
+		t.ErrIs(err, ErrCredentials)
+	}
+}
+
+func (s *SomeCredentials) Are_shared_with_the_profile_password(t *T) {
+	fake := nmfake.Default()
+	client := mckFakeNM(&Client{}, fake)
+	c, err := client.Share(bg, ProfileSelector{SSID: "home"})
+	t.FatalOn(err)
+	t.Eq(Credentials{SSID: "home", Security: SecurityWPAPSK,
+		Password: "home-secret"}, c)
+	_, err = client.Share(bg, ProfileSelector{SSID: "cafe"})
+	t.ErrIs(err, ErrShare)
+	t.ErrIs(err, ErrNoProfile)
+}
+
+func (s *SomeCredentials) Of_a_hidden_profile_are_flagged_hidden(t *T) {
+	client := withHiddenLab(nmfake.Default())
+	adapter, err := client.Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	t.FatalOn(adapter.ConnectHidden(bg, ProfileSelector{SSID: "lab"}))
+	c, err := client.Share(bg, ProfileSelector{SSID: "lab"})
+	t.FatalOn(err)
+	t.Eq("WIFI:T:WPA;S:lab;P:lab-secret;H:true;;", c.String())
+}
+
+func (s *SomeCredentials) Of_an_enterprise_profile_are_not_shared(t *T) {
+	fake := nmfake.Default()
+	fake.AddProfile(nmfake.WifiSettings("corp", "wpa-eap", ""))
+	_, err := mckFakeNM(&Client{}, fake).Share(bg,
+		ProfileSelector{SSID: "corp"})
+	t.ErrIs(err, ErrShare)
+	t.ErrIs(err, ErrUnsupportedSecurity)
+}
+
+func (s *SomeCredentials) Provide_the_password_at_connect(t *T) {
+	fake := nmfake.Default()
+	adapter, err := mckFakeNM(&Client{
+		Password: func(string) (string, error) {
+			return "", errors.New("password must not be queried")
+		}}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	c, err := ParseCredentials("WIFI:T:WPA;S:office;P:office-secret;;")
+	t.FatalOn(err)
+	t.FatalOn(adapter.ConnectWith(bg, ProfileSelector{SSID: c.SSID},
+		ConnectOptions{Credentials: &c}))
+	t.Eq("office", fake.Device("wlan0").Active.SSID)
+	t.Eq(2, len(fake.Profiles()))
+}
+
+func TestSomeCredentials(t *testing.T) {
+	t.Parallel()
+	Run(&SomeCredentials{}, t)
+}
@@ -0,0 +1,179 @@
+package wifi
+
+import (
+	"errors"
+	"fmt"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+var ErrActivationFailed = errors.New("activation failed")
+var ErrWrongSecrets = errors.New(
+	"the password or other secrets are wrong or missing")
+var ErrSSIDNotFound = errors.New("the wifi network could not be found")
+var ErrDHCPFailed = errors.New(
+	"no IP configuration could be obtained (DHCP failed)")
+var ErrSupplicantTimeout = errors.New(
+	"authentication took too long (supplicant timeout)")
+
+// StateError reports a device state transition which ends an activation
+// attempt together with the reason NetworkManager gave for it.  A
+// StateError wraps one of the typed errors ErrWrongSecrets,
+// ErrSSIDNotFound, ErrDHCPFailed, ErrSupplicantTimeout or
+// ErrActivationFailed according to its reason.
+type StateError struct {
+	State  nm.NmDeviceState
+	Reason nm.NmDeviceStateReason
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("device %s: %s", stateName(e.State),
+		reasonMessage(e.Reason))
+}
+
+func (e *StateError) Unwrap() error {
+	switch e.Reason {
+	case nm.NmDeviceStateReasonNoSecrets,
+		nm.NmDeviceStateReasonSupplicantDisconnect:
+		return ErrWrongSecrets
+	case nm.NmDeviceStateReasonSsidNotFound:
+		return ErrSSIDNotFound
+	case nm.NmDeviceStateReasonIpConfigUnavailable,
+		nm.NmDeviceStateReasonDhcpStartFailed,
+		nm.NmDeviceStateReasonDhcpError,
+		nm.NmDeviceStateReasonDhcpFailed:
+		return ErrDHCPFailed
+	case nm.NmDeviceStateReasonSupplicantTimeout:
+		return ErrSupplicantTimeout
+	}
+	return ErrActivationFailed
+}
+
+// activationErr returns a StateError if a device which is supposed to
+// become activated reports given state with given reason which ends the
+// activation attempt; otherwise nil is returned.  NOTE a device which is
+// connected to an other access point is deactivated and disconnected
+// with the reason "new activation" before it is activated again.
+func activationErr(
+	state nm.NmDeviceState, reason nm.NmDeviceStateReason,
+) error {
+	switch state {
+	case nm.NmDeviceStateFailed:
+		return &StateError{State: state, Reason: reason}
+	case nm.NmDeviceStateDeactivating, nm.NmDeviceStateDisconnected:
+		if reason == nm.NmDeviceStateReasonNone ||
+			reason == nm.NmDeviceStateReasonNewActivation {
+			return nil
+		}
+		return &StateError{State: state, Reason: reason}
+	}
+	return nil
+}
+
+// stateReasonOf returns the reason of the StateReason property of given
+// PropertiesChanged signal body or NmDeviceStateReasonUnknown if it is
+// not provided.
+func stateReasonOf(body map[string]dbus.Variant) nm.NmDeviceStateReason {
+	v, ok := body["StateReason"]
+	if !ok {
+		return nm.NmDeviceStateReasonUnknown
+	}
+	sr, ok := v.Value().([]interface{})
+	if !ok || len(sr) < 2 {
+		return nm.NmDeviceStateReasonUnknown
+	}
+	reason, ok := sr[1].(uint32)
+	if !ok {
+		return nm.NmDeviceStateReasonUnknown
+	}
+	return nm.NmDeviceStateReason(reason)
+}
+
+func stateName(state nm.NmDeviceState) string {
+	switch state {
+	case nm.NmDeviceStateUnknown:
+		return "unknown"
+	case nm.NmDeviceStateUnmanaged:
+		return "unmanaged"
+	case nm.NmDeviceStateUnavailable:
+		return "unavailable"
+	case nm.NmDeviceStateDisconnected:
+		return "disconnected"
+	case nm.NmDeviceStatePrepare:
+		return "preparing"
+	case nm.NmDeviceStateConfig:
+		return "configuring"
+	case nm.NmDeviceStateNeedAuth:
+		return "needs authentication"
+	case nm.NmDeviceStateIpConfig:
+		return "requesting IP configuration"
+	case nm.NmDeviceStateIpCheck:
+		return "checking IP connectivity"
+	case nm.NmDeviceStateSecondaries:
+		return "waiting for secondary connections"
+	case nm.NmDeviceStateActivated:
+		return "activated"
+	case nm.NmDeviceStateDeactivating:
+		return "deactivating"
+	case nm.NmDeviceStateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("state %d", state)
+}
+
+// reasonMessages provides human readable messages for device state
+// reasons which are likely to occur with wifi devices.
+var reasonMessages = map[nm.NmDeviceStateReason]string{
+	nm.NmDeviceStateReasonNone:    "no reason given",
+	nm.NmDeviceStateReasonUnknown: "unknown error",
+	nm.NmDeviceStateReasonConfigFailed: "the device could not be " +
+		"readied for configuration",
+	nm.NmDeviceStateReasonIpConfigUnavailable: "no IP configuration " +
+		"could be obtained (DHCP timeout or no address available)",
+	nm.NmDeviceStateReasonIpConfigExpired: "the IP configuration " +
+		"expired",
+	nm.NmDeviceStateReasonNoSecrets: "secrets were required, but " +
+		"not provided or wrong",
+	nm.NmDeviceStateReasonSupplicantDisconnect: "the supplicant " +
+		"disconnected, the password is probably wrong",
+	nm.NmDeviceStateReasonSupplicantConfigFailed: "the supplicant " +
+		"configuration failed",
+	nm.NmDeviceStateReasonSupplicantFailed: "the supplicant failed",
+	nm.NmDeviceStateReasonSupplicantTimeout: "the supplicant took " +
+		"too long to authenticate",
+	nm.NmDeviceStateReasonDhcpStartFailed: "the DHCP client failed " +
+		"to start",
+	nm.NmDeviceStateReasonDhcpError:  "the DHCP client failed",
+	nm.NmDeviceStateReasonDhcpFailed: "the DHCP client failed",
+	nm.NmDeviceStateReasonFirmwareMissing: "necessary firmware for " +
+		"the device may be missing",
+	nm.NmDeviceStateReasonRemoved:  "the device was removed",
+	nm.NmDeviceStateReasonSleeping: "NetworkManager went to sleep",
+	nm.NmDeviceStateReasonConnectionRemoved: "the connection profile " +
+		"was removed",
+	nm.NmDeviceStateReasonUserRequested: "disconnected by a user " +
+		"or client",
+	nm.NmDeviceStateReasonCarrier: "the link changed",
+	nm.NmDeviceStateReasonSupplicantAvailable: "the supplicant is " +
+		"now available",
+	nm.NmDeviceStateReasonDependencyFailed: "a dependency of the " +
+		"connection failed",
+	nm.NmDeviceStateReasonSsidNotFound: "the wifi network could not " +
+		"be found",
+	nm.NmDeviceStateReasonSecondaryConnectionFailed: "a secondary " +
+		"connection failed",
+	nm.NmDeviceStateReasonNewActivation: "a new activation was " +
+		"enqueued",
+	nm.NmDeviceStateReasonIpAddressDuplicate: "a duplicate IP " +
+		"address was detected",
+	nm.NmDeviceStateReasonIpMethodUnsupported: "the IP method is " +
+		"not supported",
+}
+
+func reasonMessage(reason nm.NmDeviceStateReason) string {
+	if msg, ok := reasonMessages[reason]; ok {
+		return msg
+	}
+	return fmt.Sprintf("reason %d", reason)
+}
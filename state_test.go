@@ -0,0 +1,129 @@
+package wifi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+	. "github.com/slukits/gounit"
+)
+
+type AStateChange struct{ Suite }
+
+func (s *AStateChange) SetUp(t *T) { t.Parallel() }
+
+// stateSignal creates a PropertiesChanged signal of a device reporting
+// given state with given reason.
+func stateSignal(
+	state nm.NmDeviceState, reason nm.NmDeviceStateReason,
+) *dbus.Signal {
+	return &dbus.Signal{Body: []interface{}{
+		nm.DeviceInterface,
+		map[string]dbus.Variant{
+			"State": dbus.MakeVariant(uint32(state)),
+			"StateReason": dbus.MakeVariant([]interface{}{
+				uint32(state), uint32(reason)}),
+		},
+	}}
+}
+
+func (s *AStateChange) Is_reported_if_expected_state_is_reached(t *T) {
+	c := make(chan *dbus.Signal, 3)
+	c <- stateSignal(nm.NmDeviceStatePrepare, nm.NmDeviceStateReasonNone)
+	c <- stateSignal(nm.NmDeviceStateActivated, nm.NmDeviceStateReasonNone)
+	t.FatalOn((&WifiAdapter{Timeout: time.Second}).waitForStateChange(
+		bg, c, nm.NmDeviceStateActivated))
+}
+
+func (s *AStateChange) Fails_fast_with_reason_on_failed_activation(
+	t *T,
+) {
+	c := make(chan *dbus.Signal, 1)
+	c <- stateSignal(nm.NmDeviceStateFailed,
+		nm.NmDeviceStateReasonNoSecrets)
+	err := (&WifiAdapter{Timeout: time.Minute}).waitForStateChange(
+		bg, c, nm.NmDeviceStateActivated)
+	t.ErrIs(err, ErrWrongSecrets)
+	stateErr := &StateError{}
+	t.True(errors.As(err, &stateErr))
+	t.Eq(nm.NmDeviceStateFailed, stateErr.State)
+	t.Contains(err.Error(), reasonMessage(
+		nm.NmDeviceStateReasonNoSecrets))
+}
+
+func (s *AStateChange) Ignores_deactivation_for_a_new_activation(t *T) {
+	c := make(chan *dbus.Signal, 3)
+	c <- stateSignal(nm.NmDeviceStateDeactivating,
+		nm.NmDeviceStateReasonNewActivation)
+	c <- stateSignal(nm.NmDeviceStateDisconnected,
+		nm.NmDeviceStateReasonNewActivation)
+	c <- stateSignal(nm.NmDeviceStateActivated, nm.NmDeviceStateReasonNone)
+	t.FatalOn((&WifiAdapter{Timeout: time.Second}).waitForStateChange(
+		bg, c, nm.NmDeviceStateActivated))
+}
+
+func (s *AStateChange) Fails_on_deactivation_with_a_failure_reason(
+	t *T,
+) {
+	c := make(chan *dbus.Signal, 1)
+	c <- stateSignal(nm.NmDeviceStateDeactivating,
+		nm.NmDeviceStateReasonSsidNotFound)
+	err := (&WifiAdapter{Timeout: time.Minute}).waitForStateChange(
+		bg, c, nm.NmDeviceStateActivated)
+	t.ErrIs(err, ErrSSIDNotFound)
+}
+
+func (s *AStateChange) Is_not_failing_fast_if_waiting_for_disconnect(
+	t *T,
+) {
+	c := make(chan *dbus.Signal, 2)
+	c <- stateSignal(nm.NmDeviceStateDeactivating,
+		nm.NmDeviceStateReasonUserRequested)
+	c <- stateSignal(nm.NmDeviceStateDisconnected,
+		nm.NmDeviceStateReasonUserRequested)
+	t.FatalOn((&WifiAdapter{Timeout: time.Second}).waitForStateChange(
+		bg, c, nm.NmDeviceStateDisconnected))
+}
+
+func (s *AStateChange) Times_out_if_expected_state_is_not_reported(
+	t *T,
+) {
+	err := (&WifiAdapter{Timeout: 0}).waitForStateChange(
+		bg, make(chan *dbus.Signal), nm.NmDeviceStateActivated)
+	t.ErrIs(err, ErrAdapterPropertyChangeTimeout)
+}
+
+func (s *AStateChange) Ends_waiting_if_its_context_is_canceled(t *T) {
+	ctx, cancel := context.WithCancel(bg)
+	cancel()
+	err := (&WifiAdapter{Timeout: time.Minute}).waitForStateChange(
+		ctx, make(chan *dbus.Signal), nm.NmDeviceStateActivated)
+	t.ErrIs(err, context.Canceled)
+}
+
+func (s *AStateChange) Error_is_typed_by_its_reason(t *T) {
+	for reason, err := range map[nm.NmDeviceStateReason]error{
+		nm.NmDeviceStateReasonNoSecrets:            ErrWrongSecrets,
+		nm.NmDeviceStateReasonSupplicantDisconnect: ErrWrongSecrets,
+		nm.NmDeviceStateReasonSsidNotFound:         ErrSSIDNotFound,
+		nm.NmDeviceStateReasonDhcpFailed:           ErrDHCPFailed,
+		nm.NmDeviceStateReasonIpConfigUnavailable:  ErrDHCPFailed,
+		nm.NmDeviceStateReasonSupplicantTimeout:    ErrSupplicantTimeout,
+		nm.NmDeviceStateReasonCarrier:              ErrActivationFailed,
+	} {
+		t.ErrIs(activationErr(nm.NmDeviceStateFailed, reason), err)
+	}
+}
+
+func (s *AStateChange) Reason_defaults_to_unknown_if_not_provided(t *T) {
+	t.Eq(nm.NmDeviceStateReason(nm.NmDeviceStateReasonUnknown),
+		stateReasonOf(map[string]dbus.Variant{}))
+}
+
+func TestAStateChange(t *testing.T) {
+	t.Parallel()
+	Run(&AStateChange{}, t)
+}
@@ -0,0 +1,337 @@
+package wifi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+)
+
+// SupplicantNetwork is a network={...} block of a wpa_supplicant.conf
+// file.
+type SupplicantNetwork struct {
+
+	// Line is the number of the line starting the block.
+	Line int
+
+	// Fields are the block's values by their keys; quoted values keep
+	// their quotes.
+	Fields map[string]string
+}
+
+var ErrSupplicant = errors.New("wpa_supplicant")
+
+// ParseSupplicantConf returns the network blocks of given
+// wpa_supplicant.conf content bb; global settings are ignored.
+// ParseSupplicantConf fails with ErrSupplicant if a block isn't
+// terminated or contains a line which is no key=value pair.
+func ParseSupplicantConf(bb []byte) ([]SupplicantNetwork, error) {
+	nn, n := []SupplicantNetwork{}, (*SupplicantNetwork)(nil)
+	sc := bufio.NewScanner(bytes.NewReader(bb))
+	for i := 1; sc.Scan(); i++ {
+		line := strings.TrimSpace(supplicantComment(sc.Text()))
+		switch {
+		case line == "":
+			continue
+		case n == nil && strings.ReplaceAll(line, " ", "") ==
+			"network={":
+			n = &SupplicantNetwork{Line: i, Fields: map[string]string{}}
+			continue
+		case n == nil:
+			continue
+		case line == "}":
+			nn, n = append(nn, *n), nil
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d: expected key=value",
+				ErrSupplicant, i)
+		}
+		n.Fields[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSupplicant, err)
+	}
+	if n != nil {
+		return nil, fmt.Errorf("%w: line %d: unterminated network block",
+			ErrSupplicant, n.Line)
+	}
+	return nn, nil
+}
+
+// supplicantComment removes a comment from given line unless the # is
+// quoted.
+func supplicantComment(line string) string {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '#' && !quoted:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// supplicantKeyMgmt maps the key management of wpa_supplicant to
+// securities.
+var supplicantKeyMgmt = map[string]Security{
+	"NONE":           SecurityOpen,
+	"OWE":            SecurityOWE,
+	"WPA-PSK":        SecurityWPAPSK,
+	"WPA-PSK-SHA256": SecurityWPAPSK,
+	"FT-PSK":         SecurityWPAPSK,
+	"SAE":            SecuritySAE,
+	"FT-SAE":         SecuritySAE,
+	"WPA-EAP":        SecurityEnterprise,
+	"WPA-EAP-SHA256": SecurityEnterprise,
+	"FT-EAP":         SecurityEnterprise,
+}
+
+// supplicantPhase2 maps the EAP methods to the phase2 value the inner
+// method of Enterprise settings corresponds to.
+var supplicantPhase2 = map[EAPMethod]string{
+	EAPPEAP: "auth=MSCHAPV2",
+	EAPTTLS: "auth=PAP",
+}
+
+// Settings returns the settings of a new wifi connection profile for
+// given network block n built like the profiles connect creates.  It
+// supports the fields ssid, psk, key_mgmt, scan_ssid, priority,
+// disabled, id_str, wep_key0 and the EAP fields of PEAP, TTLS and TLS
+// authentications; other fields are ignored.  Settings fails with
+// ErrSupplicant if n can't be converted, e.g. it lacks an SSID or its
+// key management or EAP method isn't supported.
+func (n SupplicantNetwork) Settings() (nm.ConnectionSettings, error) {
+	ssid, err := supplicantSSID(n.Fields["ssid"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: line %d: ssid: %w",
+			ErrSupplicant, n.Line, err)
+	}
+	sec, err := n.security()
+	if err != nil {
+		return nil, fmt.Errorf("%w: line %d: %w",
+			ErrSupplicant, n.Line, err)
+	}
+	pwd, err := n.password(sec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: line %d: %w",
+			ErrSupplicant, n.Line, err)
+	}
+	ss := newConnectionSettings(ssid, pwd, sec)
+	if sec.NeedsPassword() && pwd == "" {
+		delete(ss[wirelessSecurity], "psk")
+		delete(ss[wirelessSecurity], "wep-key0")
+	}
+	if sec == SecurityEnterprise {
+		ent, err := n.enterprise()
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w",
+				ErrSupplicant, n.Line, err)
+		}
+		ss[enterpriseSettings] = ent.settings(ent.Password)
+		if ent.Method != EAPTLS && ent.Password == "" {
+			delete(ss[enterpriseSettings], "password")
+		}
+	}
+	if err := n.apply(ss); err != nil {
+		return nil, fmt.Errorf("%w: line %d: %w",
+			ErrSupplicant, n.Line, err)
+	}
+	return ss, nil
+}
+
+// security returns the security of given network block n.  Without
+// key_mgmt wpa_supplicant's default WPA-PSK WPA-EAP applies which is
+// resolved by the given psk or EAP method.  NOTE like for an access
+// point in WPA2/WPA3 transition mode wpa-psk is preferred over sae.
+func (n SupplicantNetwork) security() (Security, error) {
+	keyMgmt, ok := n.Fields["key_mgmt"]
+	if !ok {
+		keyMgmt = "WPA-PSK"
+		if _, eap := n.Fields["eap"]; eap {
+			keyMgmt = "WPA-EAP"
+		}
+	}
+	ss := map[Security]bool{}
+	for _, km := range strings.Fields(keyMgmt) {
+		sec, ok := supplicantKeyMgmt[km]
+		if !ok {
+			return "", fmt.Errorf("%w: key_mgmt: %s",
+				ErrUnsupportedSecurity, km)
+		}
+		ss[sec] = true
+	}
+	_, psk := n.Fields["psk"]
+	switch {
+	case ss[SecurityWPAPSK] && (psk || !ss[SecurityEnterprise]):
+		return SecurityWPAPSK, nil
+	case ss[SecuritySAE] && (psk || !ss[SecurityEnterprise]):
+		return SecuritySAE, nil
+	case ss[SecurityEnterprise]:
+		return SecurityEnterprise, nil
+	case ss[SecurityOWE]:
+		return SecurityOWE, nil
+	case ss[SecurityOpen]:
+		for _, k := range []string{"wep_key1", "wep_key2", "wep_key3"} {
+			if _, ok := n.Fields[k]; ok {
+				return "", fmt.Errorf("%w: %s", ErrUnsupportedSecurity, k)
+			}
+		}
+		if _, ok := n.Fields["wep_key0"]; ok {
+			return SecurityWEP, nil
+		}
+		return SecurityOpen, nil
+	}
+	return "", fmt.Errorf("%w: key_mgmt: '%s'",
+		ErrUnsupportedSecurity, keyMgmt)
+}
+
+// password returns the psk or WEP key of given network block n with
+// given security sec or the zero string if it isn't set, i.e. it is
+// queried at connect.  A psk is either a quoted passphrase of 8 to 63
+// characters or a hex key of 64 digits.
+func (n SupplicantNetwork) password(sec Security) (string, error) {
+	switch sec {
+	case SecurityWEP:
+		return supplicantString(n.Fields["wep_key0"], true)
+	case SecurityWPAPSK, SecuritySAE:
+	default:
+		return "", nil
+	}
+	v, ok := n.Fields["psk"]
+	if !ok {
+		return "", nil
+	}
+	psk, err := supplicantString(v, sec == SecurityWPAPSK)
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("psk: %w", err)
+	case !strings.HasPrefix(v, `"`) && len(psk) != 64:
+		return "", fmt.Errorf("psk: hex key of %d instead of 64 digits",
+			len(psk))
+	case sec == SecurityWPAPSK && strings.HasPrefix(v, `"`) &&
+		(len(psk) < 8 || len(psk) > 63):
+		return "", fmt.Errorf(
+			"psk: passphrase of %d instead of 8 to 63 characters",
+			len(psk))
+	}
+	return psk, nil
+}
+
+// enterprise returns the 802.1X settings of the EAP fields of given
+// network block n.
+func (n SupplicantNetwork) enterprise() (*Enterprise, error) {
+	e := &Enterprise{}
+	methods := strings.Fields(n.Fields["eap"])
+	if len(methods) != 1 {
+		return nil, fmt.Errorf("%w: eap: need one method of peap, "+
+			"ttls or tls: '%s'", ErrEnterprise, n.Fields["eap"])
+	}
+	e.Method = EAPMethod(strings.ToLower(methods[0]))
+	for k, value := range map[string]*string{
+		"identity":            &e.Identity,
+		"anonymous_identity":  &e.AnonymousIdentity,
+		"password":            &e.Password,
+		"ca_cert":             &e.CACert,
+		"domain_suffix_match": &e.DomainSuffixMatch,
+		"client_cert":         &e.ClientCert,
+		"private_key":         &e.PrivateKey,
+		"private_key_passwd":  &e.PrivateKeyPassword,
+	} {
+		v, ok := n.Fields[k]
+		if !ok {
+			continue
+		}
+		s, err := supplicantString(v, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		*value = s
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	phase2, ok := n.Fields["phase2"]
+	if !ok || e.Method == EAPTLS {
+		return e, nil
+	}
+	phase2, err := supplicantString(phase2, false)
+	if err != nil {
+		return nil, fmt.Errorf("phase2: %w", err)
+	}
+	if !strings.EqualFold(phase2, supplicantPhase2[e.Method]) {
+		return nil, fmt.Errorf("%w: %s: unsupported phase2 '%s'",
+			ErrEnterprise, e.Method, phase2)
+	}
+	return e, nil
+}
+
+// apply sets the hidden flag, autoconnect priority and flag and id
+// given network block n's scan_ssid, priority, disabled and id_str
+// fields provide in given settings ss.
+func (n SupplicantNetwork) apply(ss nm.ConnectionSettings) error {
+	if n.Fields["scan_ssid"] == "1" {
+		ss[wirelessSettings]["hidden"] = true
+	}
+	if n.Fields["disabled"] == "1" {
+		ss["connection"]["autoconnect"] = false
+	}
+	if v, ok := n.Fields["priority"]; ok {
+		priority, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || priority < -999 || priority > 999 {
+			return fmt.Errorf("priority: '%s' not in -999..999", v)
+		}
+		ss["connection"]["autoconnect-priority"] = int32(priority)
+	}
+	if v, ok := n.Fields["id_str"]; ok {
+		id, err := supplicantString(v, false)
+		if err != nil {
+			return fmt.Errorf("id_str: %w", err)
+		}
+		ss["connection"]["id"] = id
+	}
+	return nil
+}
+
+// supplicantSSID returns given ssid field value v which is either
+// quoted or hex encoded.
+func supplicantSSID(v string) (string, error) {
+	if v == "" {
+		return "", errors.New("missing")
+	}
+	if strings.HasPrefix(v, `"`) {
+		return supplicantString(v, false)
+	}
+	bb, err := hex.DecodeString(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex '%s'", v)
+	}
+	return string(bb), nil
+}
+
+// supplicantString returns the content of given quoted field value v.
+// An unquoted v is only accepted if given raw flag is set, e.g. for the
+// hex notation of a psk or WEP key.
+func supplicantString(v string, raw bool) (string, error) {
+	if len(v) > 1 && strings.HasPrefix(v, `"`) &&
+		strings.HasSuffix(v, `"`) {
+		return v[1 : len(v)-1], nil
+	}
+	if raw {
+		for _, r := range v {
+			if !isHex(r) {
+				return "", fmt.Errorf("invalid hex key '%s'", v)
+			}
+		}
+		return v, nil
+	}
+	return "", fmt.Errorf("expected quoted value: %s", v)
+}
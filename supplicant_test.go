@@ -0,0 +1,113 @@
+package wifi
+
+import (
+	"testing"
+
+	. "github.com/slukits/gounit"
+)
+
+type ASupplicantConf struct{ Suite }
+
+func (s *ASupplicantConf) SetUp(t *T) { t.Parallel() }
+
+const supplicantConf = `ctrl_interface=/run/wpa_supplicant
+update_config=1
+
+network={
+	ssid="home"
+	psk="home-secret" # the passphrase
+	priority=5
+}
+
+# a hidden network with a hex ssid and psk
+network={
+	ssid=6c6162
+	scan_ssid=1
+	key_mgmt=WPA-PSK
+	psk=0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+}
+
+network={
+	ssid="corp"
+	key_mgmt=WPA-EAP
+	eap=PEAP
+	identity="me"
+	password="me-secret"
+	ca_cert="/etc/ssl/corp-ca.pem"
+	phase2="auth=MSCHAPV2"
+}
+
+network={
+	ssid="cafe"
+	key_mgmt=NONE
+	disabled=1
+}
+`
+
+func (s *ASupplicantConf) Provides_its_network_blocks(t *T) {
+	nn, err := ParseSupplicantConf([]byte(supplicantConf))
+	t.FatalOn(err)
+	t.FatalIfNot(t.Eq(4, len(nn)))
+	t.Eq(4, nn[0].Line)
+	t.Eq(map[string]string{"ssid": `"home"`, "psk": `"home-secret"`,
+		"priority": "5"}, nn[0].Fields)
+	t.Eq("NONE", nn[3].Fields["key_mgmt"])
+}
+
+func (s *ASupplicantConf) Fails_if_a_block_is_invalid(t *T) {
+	_, err := ParseSupplicantConf([]byte("network={\n\tssid=\"a\"\n"))
+	t.ErrIs(err, ErrSupplicant)
+	_, err = ParseSupplicantConf([]byte("network={\n\tssid\n}\n"))
+	t.ErrIs(err, ErrSupplicant)
+}
+
+func (s *ASupplicantConf) Converts_networks_to_profile_settings(t *T) {
+	nn, err := ParseSupplicantConf([]byte(supplicantConf))
+	t.FatalOn(err)
+	ss, err := nn[0].Settings()
+	t.FatalOn(err)
+	t.Eq(SecurityWPAPSK, profileSecurity(ss))
+	t.Eq("home-secret", ss[wirelessSecurity]["psk"])
+	t.Eq(int32(5), ss["connection"]["autoconnect-priority"])
+	ss, err = nn[1].Settings()
+	t.FatalOn(err)
+	t.Eq([]byte("lab"), ss[wirelessSettings]["ssid"])
+	t.Eq(true, ss[wirelessSettings]["hidden"])
+	t.Eq(64, len(ss[wirelessSecurity]["psk"].(string)))
+	ss, err = nn[2].Settings()
+	t.FatalOn(err)
+	t.Eq(SecurityEnterprise, profileSecurity(ss))
+	t.Eq(map[string]interface{}{"eap": []string{"peap"},
+		"identity": "me", "password": "me-secret",
+		"phase2-auth": "mschapv2",
+		"ca-cert":     []byte("file:///etc/ssl/corp-ca.pem\x00")},
+		ss[enterpriseSettings])
+	ss, err = nn[3].Settings()
+	t.FatalOn(err)
+	t.Eq(SecurityOpen, profileSecurity(ss))
+	t.Eq(false, ss["connection"]["autoconnect"])
+}
+
+func (s *ASupplicantConf) Fails_to_convert_unsupported_networks(t *T) {
+	for _, fields := range []map[string]string{
+		{"psk": `"secret12"`},
+		{"ssid": "6c6", "psk": `"secret12"`},
+		{"ssid": `"a"`, "psk": `"short"`},
+		{"ssid": `"a"`, "psk": "0123abcd"},
+		{"ssid": `"a"`, "key_mgmt": "WPA-EAP-SUITE-B-192"},
+		{"ssid": `"a"`, "key_mgmt": "WPA-EAP", "eap": "LEAP",
+			"identity": `"me"`},
+		{"ssid": `"a"`, "key_mgmt": "WPA-EAP", "eap": "PEAP",
+			"identity": `"me"`, "phase2": `"auth=GTC"`},
+		{"ssid": `"a"`, "key_mgmt": "NONE", "wep_key1": `"abcde"`},
+		{"ssid": `"a"`, "priority": "1000"},
+	} {
+		_, err := SupplicantNetwork{Line: 1, Fields: fields}.Settings()
+		t.ErrIs(err, ErrSupplicant)
+	}
+}
+
+func TestASupplicantConf(t *testing.T) {
+	t.Parallel()
+	Run(&ASupplicantConf{}, t)
+}
@@ -0,0 +1,225 @@
+package wifi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+)
+
+// EventKind classifies the events reported by WifiAdapter.Watch.
+type EventKind string
+
+const (
+	StateEvent              EventKind = "state"
+	ActiveAccessPointEvent  EventKind = "active-access-point"
+	AccessPointAddedEvent   EventKind = "access-point-added"
+	AccessPointRemovedEvent EventKind = "access-point-removed"
+	ConnectivityEvent       EventKind = "connectivity"
+)
+
+// Event is a decoded NetworkManager signal concerning a wifi adapter.
+// Only the properties of an event's kind are set.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Adapter string    `json:"adapter"`
+	Kind    EventKind `json:"kind"`
+
+	// State is the readable device state of a StateEvent.
+	State string `json:"state,omitempty"`
+
+	// Reason is the readable reason of a StateEvent's state change if
+	// NetworkManager gave one.
+	Reason string `json:"reason,omitempty"`
+
+	// SSID and BSSID identify the access point of an access point
+	// event; they are zero if an adapter has no active access point.
+	SSID  string `json:"ssid,omitempty"`
+	BSSID string `json:"bssid,omitempty"`
+
+	// Connectivity is the readable connectivity of a
+	// ConnectivityEvent, i.e. "unknown", "none", "portal", "limited"
+	// or "full".
+	Connectivity string `json:"connectivity,omitempty"`
+}
+
+const (
+	accessPointAdded   = "AccessPointAdded"
+	accessPointRemoved = "AccessPointRemoved"
+)
+
+var ErrAdapterWatch = errors.New("adapter: watch")
+
+// Watch passes the events of given wifi adapter a and NetworkManager's
+// connectivity changes to given handler until given context ctx is
+// done.  Watch reports state changes with their reasons, changes of
+// the active access point and access points appearing and disappearing.
+func (a *WifiAdapter) Watch(
+	ctx context.Context, handle func(Event),
+) (err error) {
+	c, dfr, err := a.setupSignalMatcher(
+		signalRule{
+			path:  a.dev.GetPath(),
+			iface: nm.DeviceWirelessInterface,
+		},
+		signalRule{
+			path:   nm.NetworkManagerObjectPath,
+			iface:  DBusProperties,
+			member: PropertiesChanged,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrAdapterWatch, err)
+	}
+	defer func() { err = dfr(err, ErrAdapterWatch) }()
+	seen := map[dbus.ObjectPath]Event{}
+	if err := a.updateSeen(seen); err != nil {
+		return fmt.Errorf("%w: %w", ErrAdapterWatch, err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case s, ok := <-c:
+			if !ok {
+				return fmt.Errorf("%w: %w", ErrAdapterWatch, ErrHubClosed)
+			}
+			e, ok, err := a.eventOf(s, seen)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrAdapterWatch, err)
+			}
+			if ok {
+				handle(e)
+			}
+		}
+	}
+}
+
+// updateSeen adds the access points given adapter a currently sees to
+// given access point events by their path which are missing.
+func (a *WifiAdapter) updateSeen(seen map[dbus.ObjectPath]Event) error {
+	aa, err := a.dev.GetPropertyAccessPoints()
+	if err != nil {
+		return err
+	}
+	for _, ap := range aa {
+		if _, ok := seen[ap.GetPath()]; ok {
+			continue
+		}
+		ssid, err := ap.GetPropertySSID()
+		if err != nil {
+			return err
+		}
+		bssid, err := ap.GetPropertyHWAddress()
+		if err != nil {
+			return err
+		}
+		seen[ap.GetPath()] = Event{SSID: ssid, BSSID: bssid}
+	}
+	return nil
+}
+
+// eventOf decodes given signal s into an event of given adapter a.  The
+// access points of access point events are looked up in given seen
+// access points.  eventOf's second return value is false if s isn't an
+// event.
+func (a *WifiAdapter) eventOf(
+	s *dbus.Signal, seen map[dbus.ObjectPath]Event,
+) (Event, bool, error) {
+	e := Event{Time: time.Now(), Adapter: a.name}
+	switch s.Name {
+	case nm.DeviceWirelessInterface + "." + accessPointAdded:
+		path, ok := signalPath(s)
+		if !ok {
+			return e, false, nil
+		}
+		if err := a.updateSeen(seen); err != nil {
+			return e, false, err
+		}
+		e.Kind = AccessPointAddedEvent
+		e.SSID, e.BSSID = seen[path].SSID, seen[path].BSSID
+		return e, true, nil
+	case nm.DeviceWirelessInterface + "." + accessPointRemoved:
+		path, ok := signalPath(s)
+		if !ok {
+			return e, false, nil
+		}
+		e.Kind = AccessPointRemovedEvent
+		e.SSID, e.BSSID = seen[path].SSID, seen[path].BSSID
+		delete(seen, path)
+		return e, true, nil
+	case DBusProperties + "." + PropertiesChanged:
+	default:
+		return e, false, nil
+	}
+	if len(s.Body) < 2 {
+		return e, false, nil
+	}
+	iface, _ := s.Body[0].(string)
+	props, ok := s.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return e, false, nil
+	}
+	switch {
+	case s.Path == a.dev.GetPath() && iface == nm.DeviceInterface:
+		v, ok := props["State"].Value().(uint32)
+		if !ok {
+			return e, false, nil
+		}
+		e.Kind, e.State = StateEvent, stateName(nm.NmDeviceState(v))
+		reason := stateReasonOf(props)
+		if reason != nm.NmDeviceStateReasonNone &&
+			reason != nm.NmDeviceStateReasonUnknown {
+			e.Reason = reasonMessage(reason)
+		}
+		return e, true, nil
+	case s.Path == a.dev.GetPath() &&
+		iface == nm.DeviceWirelessInterface:
+		path, ok := props["ActiveAccessPoint"].Value().(dbus.ObjectPath)
+		if !ok {
+			return e, false, nil
+		}
+		if err := a.updateSeen(seen); err != nil {
+			return e, false, err
+		}
+		e.Kind = ActiveAccessPointEvent
+		e.SSID, e.BSSID = seen[path].SSID, seen[path].BSSID
+		return e, true, nil
+	case s.Path == nm.NetworkManagerObjectPath &&
+		iface == nm.NetworkManagerInterface:
+		v, ok := props["Connectivity"].Value().(uint32)
+		if !ok {
+			return e, false, nil
+		}
+		e.Kind = ConnectivityEvent
+		e.Connectivity = connectivityName(nm.NmConnectivity(v))
+		return e, true, nil
+	}
+	return e, false, nil
+}
+
+// signalPath returns the object path given signal s carries.
+func signalPath(s *dbus.Signal) (dbus.ObjectPath, bool) {
+	if len(s.Body) < 1 {
+		return "", false
+	}
+	path, ok := s.Body[0].(dbus.ObjectPath)
+	return path, ok
+}
+
+func connectivityName(c nm.NmConnectivity) string {
+	switch c {
+	case nm.NmConnectivityNone:
+		return "none"
+	case nm.NmConnectivityPortal:
+		return "portal"
+	case nm.NmConnectivityLimited:
+		return "limited"
+	case nm.NmConnectivityFull:
+		return "full"
+	}
+	return "unknown"
+}
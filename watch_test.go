@@ -0,0 +1,153 @@
+package wifi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/wifi/nmfake"
+	nm "github.com/Wifx/gonetworkmanager/v2"
+	"github.com/godbus/dbus/v5"
+	. "github.com/slukits/gounit"
+)
+
+type AWatch struct{ Suite }
+
+func (s *AWatch) SetUp(t *T) { t.Parallel() }
+
+// subscribedBus wraps a bus connection and closes its subscribed
+// channel once the three match rules of a watch are added.
+type subscribedBus struct {
+	BusConnection
+	rules      int
+	subscribed chan struct{}
+}
+
+func (b *subscribedBus) AddMatchSignal(oo ...dbus.MatchOption) error {
+	err := b.BusConnection.AddMatchSignal(oo...)
+	if b.rules++; b.rules == 3 {
+		close(b.subscribed)
+	}
+	return err
+}
+
+// watching starts watching the adapter "wlan0" of given fake network
+// manager and returns once the watch receives signals.  The returned
+// function ends the watch and returns its error.
+func watching(t *T, fake *nmfake.NM) (chan Event, func() error) {
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	bus := &subscribedBus{subscribed: make(chan struct{})}
+	adapter.client.Lib.SystemBus = func() (BusConnection, error) {
+		cnn, err := fake.SystemBus()
+		bus.BusConnection = cnn
+		return bus, err
+	}
+	ctx, cancel := context.WithCancel(bg)
+	ee, done := make(chan Event, 100), make(chan error, 1)
+	go func() { done <- adapter.Watch(ctx, func(e Event) { ee <- e }) }()
+	select {
+	case <-bus.subscribed:
+	case err := <-done:
+		t.Fatalf("watch ended: %v", err)
+	}
+	return ee, func() error { cancel(); return <-done }
+}
+
+// next returns the next of given events or fails given test.
+func next(t *T, ee chan Event) Event {
+	select {
+	case e := <-ee:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("missing event")
+	}
+	return Event{}
+}
+
+func (s *AWatch) Reports_state_and_active_access_point_changes(t *T) {
+	fake := nmfake.Default()
+	ee, stop := watching(t, fake)
+	t.FatalOn(fake.Device("wlan0").Disconnect())
+	e := next(t, ee)
+	t.Eq(StateEvent, e.Kind)
+	t.Eq("wlan0", e.Adapter)
+	t.Eq("deactivating", e.State)
+	t.Eq("disconnected by a user or client", e.Reason)
+	t.Not.True(e.Time.IsZero())
+	e = next(t, ee)
+	t.Eq(ActiveAccessPointEvent, e.Kind)
+	t.Eq("", e.SSID)
+	t.Eq("disconnected", next(t, ee).State)
+	t.FatalOn(stop())
+}
+
+func (s *AWatch) Reports_the_new_active_access_point(t *T) {
+	fake := nmfake.Default()
+	adapter, err := mckFakeNM(&Client{}, fake).Adapter(bg, "wlan0")
+	t.FatalOn(err)
+	ee, stop := watching(t, fake)
+	t.FatalOn(adapter.Connect(bg, "cafe"))
+	aa := []Event{}
+	for len(aa) < 2 {
+		if e := next(t, ee); e.Kind == ActiveAccessPointEvent {
+			aa = append(aa, timeless(e))
+		}
+	}
+	t.Eq([]Event{{Kind: ActiveAccessPointEvent}, {
+		Kind: ActiveAccessPointEvent, SSID: "cafe",
+		BSSID: "00:00:00:00:00:03"}}, aa)
+	t.FatalOn(stop())
+}
+
+func (s *AWatch) Reports_appearing_and_disappearing_access_points(
+	t *T,
+) {
+	fake := nmfake.Default()
+	ee, stop := watching(t, fake)
+	lab := fake.Device("wlan0").AddAP(&nmfake.AP{
+		SSID: "lab", BSSID: "00:00:00:00:00:10"})
+	t.Eq(Event{Kind: AccessPointAddedEvent, SSID: "lab",
+		BSSID: "00:00:00:00:00:10"}, timeless(next(t, ee)))
+	fake.Device("wlan0").RemoveAP(lab)
+	t.Eq(Event{Kind: AccessPointRemovedEvent, SSID: "lab",
+		BSSID: "00:00:00:00:00:10"}, timeless(next(t, ee)))
+	t.FatalOn(stop())
+}
+
+// timeless returns given event e without its time and adapter.
+func timeless(e Event) Event {
+	e.Time, e.Adapter = time.Time{}, ""
+	return e
+}
+
+func (s *AWatch) Reports_connectivity_changes(t *T) {
+	fake := nmfake.Default()
+	ee, stop := watching(t, fake)
+	fake.SetConnectivity(nm.NmConnectivityPortal)
+	fake.SetConnectivity(nm.NmConnectivityFull)
+	e := next(t, ee)
+	t.Eq(ConnectivityEvent, e.Kind)
+	t.Eq("portal", e.Connectivity)
+	t.Eq("full", next(t, ee).Connectivity)
+	t.FatalOn(stop())
+}
+
+func (s *AWatch) Ends_without_error_if_its_context_is_done(t *T) {
+	ctx, cancel := context.WithCancel(bg)
+	cancel()
+	t.FatalOn(fakeAdapter(t, "").Watch(ctx, func(Event) {
+		t.Fatal("unexpected event")
+	}))
+}
+
+func (s *AWatch) Fails_on_system_bus_connection_failure(t *T) {
+	err := mckAdapterBusFailure(t).Watch(bg, func(Event) {})
+	t.ErrIs(err, ErrAdapterWatch)
+	t.ErrIs(err, ErrMckAdapterBusConnectionFailure)
+}
+
+func TestAWatch(t *testing.T) {
+	t.Parallel()
+	Run(&AWatch{}, t)
+}